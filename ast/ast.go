@@ -41,6 +41,26 @@ type Expression interface {
 	expressionNode()
 }
 
+// Line returns the source line a statement starts on, or 0 if it was not
+// parsed from source.
+func Line(stmt Statement) int {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		return stmt.Token.Line
+	case *AssignmentStatement:
+		return stmt.Name.Token.Line
+	case *ClassStatement:
+		return stmt.Token.Line
+	case *ReturnStatement:
+		return stmt.Token.Line
+	case *ExpressionStatement:
+		return stmt.Token.Line
+	case *BlockStatement:
+		return stmt.Token.Line
+	}
+	return 0
+}
+
 type PrefixExpression struct {
 	Token    token.Token // The prefix token, e.g. !
 	Operator string
@@ -173,6 +193,24 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// SpawnExpression runs a call on its own goroutine: spawn f(x), spawn(f, x)
+// or spawn fn() { ... }.
+type SpawnExpression struct {
+	Token     token.Token // the token.SPAWN token
+	Function  Expression
+	Arguments []Expression
+}
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpawnExpression) String() string {
+	args := []string{}
+	for _, a := range se.Arguments {
+		args = append(args, a.String())
+	}
+	return se.TokenLiteral() + " " + se.Function.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
 type NewExpression struct {
 	Token token.Token // the token.NEW token
 	Name  *Identifier
@@ -330,6 +368,57 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+type SliceExpression struct {
+	Token token.Token // The [ token
+	Left  Expression
+	Start Expression // nil when omitted, e.g. s[:3]
+	End   Expression // nil when omitted, e.g. s[1:]
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
+type SliceAssignmentExpression struct {
+	Token      token.Token // The [ token
+	Left       Expression
+	Start      Expression
+	End        Expression
+	Assignment Expression
+}
+
+func (sae *SliceAssignmentExpression) expressionNode()      {}
+func (sae *SliceAssignmentExpression) TokenLiteral() string { return sae.Token.Literal }
+func (sae *SliceAssignmentExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(sae.Left.String())
+	out.WriteString("[")
+	if sae.Start != nil {
+		out.WriteString(sae.Start.String())
+	}
+	out.WriteString(":")
+	if sae.End != nil {
+		out.WriteString(sae.End.String())
+	}
+	out.WriteString("]=")
+	out.WriteString(sae.Assignment.String())
+	return out.String()
+}
+
 type IndexAssignmentExpression struct {
 	Token      token.Token // The [ token
 	Left       Expression
@@ -392,6 +481,7 @@ type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Generator  bool // declared with fn*
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -403,6 +493,9 @@ func (fl *FunctionLiteral) String() string {
 		params = append(params, p.String())
 	}
 	out.WriteString(fl.TokenLiteral())
+	if fl.Generator {
+		out.WriteString("*")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
@@ -410,6 +503,22 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// YieldExpression suspends the running coroutine: yield value. Value is nil
+// for a bare yield.
+type YieldExpression struct {
+	Token token.Token // the token.YIELD token
+	Value Expression
+}
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	if ye.Value == nil {
+		return ye.TokenLiteral()
+	}
+	return ye.TokenLiteral() + " " + ye.Value.String()
+}
+
 func newProgramASTNode() *Program {
 	p := Program{}
 	return &p
@@ -424,6 +533,14 @@ func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (n *NullLiteral) expressionNode()      {}
+func (n *NullLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *NullLiteral) String() string       { return n.Token.Literal }
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
@@ -446,6 +563,20 @@ func (al *ArrayLiteral) String() string {
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order
+}
+
+// OrderedKeys returns the keys of Pairs in source order when Keys records
+// them, and in map order otherwise.
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	return keys
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -453,8 +584,8 @@ func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.OrderedKeys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -0,0 +1,337 @@
+package builtins
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// binaryWorldMagic starts every world saved in the binary format. It is
+// followed by one byte of worldFileVersion.
+const binaryWorldMagic = "ECSW"
+
+// Tags for the values stored in the binary format.
+const (
+	tagNull byte = iota
+	tagFalse
+	tagTrue
+	tagInteger
+	tagFloat
+	tagString
+	tagArray
+	tagHash
+)
+
+type binaryWriter struct {
+	bytes.Buffer
+}
+
+func (w *binaryWriter) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutVarint(buf[:], v)])
+}
+
+func (w *binaryWriter) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (w *binaryWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.WriteString(s)
+}
+
+// value writes obj. Hash pairs are written in key order so the same world
+// always encodes to the same bytes.
+func (w *binaryWriter) value(obj object.Object, visiting map[object.Object]bool) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		w.WriteByte(tagNull)
+	case *object.Boolean:
+		if obj.Value {
+			w.WriteByte(tagTrue)
+		} else {
+			w.WriteByte(tagFalse)
+		}
+	case *object.Integer:
+		w.WriteByte(tagInteger)
+		w.varint(obj.Value)
+	case *object.Float:
+		w.WriteByte(tagFloat)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(obj.Value))
+		w.Write(buf[:])
+	case *object.String:
+		w.WriteByte(tagString)
+		w.string(obj.Value)
+	case *object.Array:
+		if visiting[obj] {
+			return newError("cannot save cyclic ARRAY")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+		w.WriteByte(tagArray)
+		w.uvarint(uint64(len(obj.Elements)))
+		for _, element := range obj.Elements {
+			if err := w.value(element, visiting); err != nil {
+				return err
+			}
+		}
+	case *object.Hash:
+		if visiting[obj] {
+			return newError("cannot save cyclic HASH")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+		w.WriteByte(tagHash)
+		w.uvarint(uint64(len(obj.Pairs)))
+		for _, pair := range SortedPairs(obj) {
+			if err := w.value(pair.Key, visiting); err != nil {
+				return err
+			}
+			if err := w.value(pair.Value, visiting); err != nil {
+				return err
+			}
+		}
+	default:
+		return newError("cannot save %s", obj.Type())
+	}
+	return nil
+}
+
+func (snap *worldSnapshot) encodeBinary() ([]byte, *object.Error) {
+	var w binaryWriter
+	w.WriteString(binaryWorldMagic)
+	w.WriteByte(worldFileVersion)
+	w.varint(int64(snap.clock))
+	w.varint(snap.frame)
+	w.varint(snap.nextID)
+	if err := w.value(snap.state, map[object.Object]bool{}); err != nil {
+		return nil, err
+	}
+	w.uvarint(uint64(len(snap.versions)))
+	for _, name := range sortedKeys(snap.versions) {
+		w.string(name)
+		w.varint(snap.versions[name])
+	}
+	w.uvarint(uint64(len(snap.entities)))
+	for _, entity := range snap.entities {
+		w.varint(entity.id)
+		w.varint(entity.parent)
+		w.uvarint(uint64(len(entity.components)))
+		for _, component := range entity.components {
+			w.string(component.name)
+			if err := w.value(component.data, map[object.Object]bool{}); err != nil {
+				return nil, newError("cannot save component %s: %s", component.name, err.Message)
+			}
+		}
+	}
+	return w.Bytes(), nil
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// errCorrupt is returned by binaryReader for malformed input.
+var errCorrupt = errors.New("malformed data")
+
+type binaryReader struct {
+	*bytes.Reader
+}
+
+func (r binaryReader) varint() (int64, error) {
+	return binary.ReadVarint(r)
+}
+
+// count reads a length and checks it against the bytes left, so corrupt
+// input cannot make the decoder allocate huge slices.
+func (r binaryReader) count() (int, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(r.Len()) {
+		return 0, errCorrupt
+	}
+	return int(n), nil
+}
+
+func (r binaryReader) string() (string, error) {
+	n, err := r.count()
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (r binaryReader) value() (object.Object, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case tagNull:
+		return NULL, nil
+	case tagFalse:
+		return FALSE, nil
+	case tagTrue:
+		return TRUE, nil
+	case tagInteger:
+		v, err := r.varint()
+		return &object.Integer{Value: v}, err
+	case tagFloat:
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, err
+		}
+		return &object.Float{Value: math.Float64frombits(binary.LittleEndian.Uint64(buf[:]))}, nil
+	case tagString:
+		s, err := r.string()
+		return &object.String{Value: s}, err
+	case tagArray:
+		n, err := r.count()
+		if err != nil {
+			return nil, err
+		}
+		elements := make([]object.Object, n)
+		for i := range elements {
+			if elements[i], err = r.value(); err != nil {
+				return nil, err
+			}
+		}
+		return &object.Array{Elements: elements}, nil
+	case tagHash:
+		n, err := r.count()
+		if err != nil {
+			return nil, err
+		}
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, n)}
+		for i := 0; i < n; i++ {
+			key, err := r.value()
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, errCorrupt
+			}
+			value, err := r.value()
+			if err != nil {
+				return nil, err
+			}
+			hash.Pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return hash, nil
+	}
+	return nil, fmt.Errorf("unknown value tag %d", tag)
+}
+
+func (r binaryReader) hash() (*object.Hash, error) {
+	value, err := r.value()
+	if err != nil {
+		return nil, err
+	}
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return nil, fmt.Errorf("expected HASH, got %s", value.Type())
+	}
+	return hash, nil
+}
+
+func decodeBinaryWorld(data []byte) (*worldSnapshot, *object.Error) {
+	data = data[len(binaryWorldMagic):]
+	if len(data) == 0 {
+		return nil, newError("corrupt world file: %s", errCorrupt)
+	}
+	version := data[0]
+	if version < 1 || version > worldFileVersion {
+		return nil, newError("unsupported world file version %d", version)
+	}
+	snap, err := readBinaryWorld(binaryReader{bytes.NewReader(data[1:])}, version)
+	if err != nil {
+		return nil, newError("corrupt world file: %s", err)
+	}
+	return snap, nil
+}
+
+func readBinaryWorld(r binaryReader, version byte) (*worldSnapshot, error) {
+	snap := &worldSnapshot{versions: map[string]int64{}}
+	clock, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	snap.clock = time.Duration(clock)
+	if snap.frame, err = r.varint(); err != nil {
+		return nil, err
+	}
+	if snap.nextID, err = r.varint(); err != nil {
+		return nil, err
+	}
+	if snap.state, err = r.hash(); err != nil {
+		return nil, err
+	}
+	schemas, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < schemas; i++ {
+		name, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		if snap.versions[name], err = r.varint(); err != nil {
+			return nil, err
+		}
+	}
+	entities, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < entities; i++ {
+		var entity entitySnapshot
+		if entity.id, err = r.varint(); err != nil {
+			return nil, err
+		}
+		if version >= 2 {
+			if entity.parent, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+		components, err := r.count()
+		if err != nil {
+			return nil, err
+		}
+		for j := 0; j < components; j++ {
+			var component componentSnapshot
+			if component.name, err = r.string(); err != nil {
+				return nil, err
+			}
+			if component.data, err = r.hash(); err != nil {
+				return nil, err
+			}
+			entity.components = append(entity.components, component)
+		}
+		snap.entities = append(snap.entities, entity)
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("unexpected data after world")
+	}
+	return snap, nil
+}
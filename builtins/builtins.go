@@ -3,7 +3,7 @@ package builtins
 import (
 	"fmt"
 	"strings"
-	"time"
+	"unicode/utf8"
 
 	"github.com/SpaceHexagon/ecs/object"
 )
@@ -13,152 +13,198 @@ func newError(format string, a ...interface{}) *object.Error {
 }
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
-var ECSBuiltins = map[string]object.Object{
-	"Math": maths(),
-	"time": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
+// ECSBuiltins is the builtin set used by environments that were not given
+// one of their own.
+var ECSBuiltins = New()
 
-			return &object.Integer{Value: time.Now().Unix()}
+// New returns a fresh set of builtins. Modules such as Math are hashes that
+// scripts can modify, so every interpreter gets its own copy. Builtins that
+// need a capability are included but fail until it is granted.
+func New() map[string]object.Object {
+	builtins := map[string]object.Object{
+		"Math":       maths(),
+		"regex":      regex(),
+		"json":       jsonModule(),
+		"split":      &object.Builtin{Fn: split},
+		"replace":    &object.Builtin{Fn: replace},
+		"trim":       &object.Builtin{Fn: trim},
+		"upper":      &object.Builtin{Fn: upper},
+		"lower":      &object.Builtin{Fn: lower},
+		"startsWith": &object.Builtin{Fn: startsWith},
+		"endsWith":   &object.Builtin{Fn: endsWith},
+		"contains":   &object.Builtin{Fn: contains},
+		"indexOf":    &object.Builtin{Fn: indexOf},
+		"repeat":     &object.Builtin{Fn: repeat},
+		"format":     &object.Builtin{Fn: format},
+		"eprint":     &object.Builtin{Fn: eprint},
+		"printf":     &object.Builtin{Fn: printf},
+		"readLine":   &object.Builtin{Fn: readLine},
+		"await":      &object.Builtin{Fn: await},
+		"chan":       &object.Builtin{Fn: makeChannel},
+		"send":       &object.Builtin{Fn: send},
+		"recv":       &object.Builtin{Fn: recv},
+		"close":      &object.Builtin{Fn: closeChannel},
+		"select":     &object.Builtin{Fn: selectChannels},
+		"coroutine":  &object.Builtin{Fn: coroutine},
+		"wait":       &object.Builtin{Fn: wait},
+		"float": &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != object.INTEGER_OBJ {
+					return newError("argument to `float` must be INTEGER, got %s", args[0].Type())
+				}
+				return &object.Float{Value: float64(args[0].(*object.Integer).Value)}
+			},
 		},
-	},
-	"float": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			if args[0].Type() != object.INTEGER_OBJ {
-				return newError("argument to `float` must be INTEGER, got %s", args[0].Type())
-			}
-			return &object.Float{Value: float64(args[0].(*object.Integer).Value)}
+		"print": &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Fprintln(ctx.Out, arg.Inspect())
+				}
+				return NULL
+			},
 		},
-	},
-	"print": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
-			}
-			return NULL
-		},
-	},
-	"len": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-			switch arg := args[0].(type) {
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+		"len": &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				switch arg := args[0].(type) {
+				case *object.Array:
+					return &object.Integer{Value: int64(len(arg.Elements))}
+				case *object.String:
+					return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 
-			default:
-				return newError("argument to `len` not supported, got %s",
-					args[0].Type())
-			}
+				default:
+					return newError("argument to `len` not supported, got %s",
+						args[0].Type())
+				}
+			},
 		},
-	},
-	"first": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s",
-					args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
-			}
-			return NULL
+		"first": &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `first` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				if len(arr.Elements) > 0 {
+					return arr.Elements[0]
+				}
+				return NULL
+			},
 		},
-	},
-	"last": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` must be ARRAY, got %s",
-					args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
-			}
-			return NULL
+		"last": &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `last` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					return arr.Elements[length-1]
+				}
+				return NULL
+			},
 		},
-	},
-	"rest": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s",
-					args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
-			}
-			return NULL
+		"rest": &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `rest` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					newElements := make([]object.Object, length-1, length-1)
+					copy(newElements, arr.Elements[1:length])
+					return &object.Array{Elements: newElements}
+				}
+				return NULL
+			},
 		},
-	},
-	"push": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s",
-					args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length+1, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-			return &object.Array{Elements: newElements}
+		"push": &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `push` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				newElements := make([]object.Object, length+1, length+1)
+				copy(newElements, arr.Elements)
+				newElements[length] = args[1]
+				return &object.Array{Elements: newElements}
+			},
 		},
-	},
-	"join": &object.Builtin{
-		Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("first argument to `join` must be ARRAY, got %s",
-					args[0].Type())
-			}
-			if args[1].Type() != object.STRING_OBJ {
-				return newError("second argument to `join` must be STRING, got %s",
-					args[0].Type())
-			}
-			strArray := []string{}
-			arr := args[0].(*object.Array)
-			for _, element := range arr.Elements {
-				s := ""
-				s = element.Inspect()
-				strArray = append(strArray, s)
-			}
-			outStr := strings.Join(strArray, args[1].(*object.String).Value)
-			return &object.String{Value: outStr}
+		"join": &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("first argument to `join` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				if args[1].Type() != object.STRING_OBJ {
+					return newError("second argument to `join` must be STRING, got %s",
+						args[0].Type())
+				}
+				strArray := []string{}
+				arr := args[0].(*object.Array)
+				for _, element := range arr.Elements {
+					s := ""
+					s = element.Inspect()
+					strArray = append(strArray, s)
+				}
+				outStr := strings.Join(strArray, args[1].(*object.String).Value)
+				return &object.String{Value: outStr}
+			},
 		},
-	},
+	}
+	components := newComponentRegistry()
+	builtins["Component"] = componentModule(components)
+	builtins["World"] = worldNamespace(components)
+	world := newWorld(components)
+	builtins["world"] = world
+	world.input = newInput()
+	builtins["input"] = &object.Builtin{Fn: input, Fields: inputFields(world.input, world)}
+	builtins["Prefab"] = prefabModule(components, world)
+	builtins["physics"] = physicsModule(world)
+	builtins["Grid"] = gridModule()
+	for name, timer := range timerBuiltins(world) {
+		builtins[name] = timer
+	}
+	for capability, group := range capabilityGroups() {
+		for name, obj := range group {
+			builtins[name] = requireCapability(capability, obj)
+		}
+	}
+	return builtins
 }
@@ -0,0 +1,61 @@
+package builtins
+
+import (
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// started is the reference point for monotonic, which measures elapsed
+// milliseconds that are unaffected by changes to the wall clock.
+var started = time.Now()
+
+// capabilityGroups returns the builtins that reach outside the interpreter,
+// grouped by the capability a program must be granted to call them.
+func capabilityGroups() map[object.Capability]map[string]object.Object {
+	return map[object.Capability]map[string]object.Object{
+		object.FS_CAPABILITY: {
+			"fs": fsModule(),
+		},
+		object.TERMINAL_CAPABILITY: {
+			"terminal": terminalModule(),
+		},
+		object.TIME_CAPABILITY: {
+			"time": &object.Builtin{
+				Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+					return journaled(ctx, "time", func() object.Object {
+						return &object.Integer{Value: time.Now().Unix()}
+					})
+				},
+			},
+			"timeMs": &object.Builtin{
+				Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+					return journaled(ctx, "timeMs", func() object.Object {
+						return &object.Integer{Value: time.Now().UnixMilli()}
+					})
+				},
+			},
+			"monotonic": &object.Builtin{
+				Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+					return journaled(ctx, "monotonic", func() object.Object {
+						return &object.Float{Value: float64(time.Since(started)) / float64(time.Millisecond)}
+					})
+				},
+			},
+		},
+	}
+}
+
+// requireCapability marks obj, or every builtin in a module hash, as needing
+// capability.
+func requireCapability(capability object.Capability, obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Builtin:
+		obj.Capability = capability
+	case *object.Hash:
+		for _, pair := range obj.Pairs {
+			requireCapability(capability, pair.Value)
+		}
+	}
+	return obj
+}
@@ -1,3 +1,170 @@
 package builtins
 
-// TODO: implement based on TS version of this api
+import (
+	"sort"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+// componentRegistry holds the components declared with Component.define
+// and the prefabs declared with Prefab.define. Every world created by one
+// builtin set shares a registry.
+type componentRegistry struct {
+	defaults   map[string]*object.Hash
+	versions   map[string]int64
+	migrations map[string]object.Object
+	prefabs    map[string]*prefab
+}
+
+func newComponentRegistry() *componentRegistry {
+	return &componentRegistry{
+		defaults: map[string]*object.Hash{
+			TRANSFORM_COMPONENT: transformDefaults(),
+			RIGIDBODY_COMPONENT: rigidBodyDefaults(),
+			COLLIDER_COMPONENT:  colliderDefaults(),
+		},
+		versions:   map[string]int64{},
+		migrations: map[string]object.Object{},
+		prefabs:    map[string]*prefab{},
+	}
+}
+
+// version returns the schema version of a component. Components that were
+// never given one are at version 1.
+func (r *componentRegistry) version(name string) int64 {
+	if version, ok := r.versions[name]; ok {
+		return version
+	}
+	return 1
+}
+
+// instantiate returns a new component: a copy of the declared defaults, if
+// any, with the fields of data copied over them.
+func (r *componentRegistry) instantiate(name string, data *object.Hash) *object.Hash {
+	component := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	if defaults, ok := r.defaults[name]; ok {
+		// Component.define stores a copy of the defaults, so they are
+		// known not to be cyclic.
+		copied, _ := deepCopy(defaults, map[object.Object]bool{})
+		component.Pairs = copied.(*object.Hash).Pairs
+	}
+	if data != nil {
+		for key, pair := range data.Pairs {
+			component.Pairs[key] = pair
+		}
+	}
+	return component
+}
+
+func (r *componentRegistry) names() []string {
+	names := make([]string, 0, len(r.defaults))
+	for name := range r.defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func componentModule(registry *componentRegistry) *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "define", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) < 1 || len(args) > 3 {
+					return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+				}
+				name, ok := args[0].(*object.String)
+				if !ok {
+					return newError("first argument to `Component.define` must be STRING, got %s", args[0].Type())
+				}
+				defaults := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+				if len(args) >= 2 {
+					if defaults, ok = args[1].(*object.Hash); !ok {
+						return newError("second argument to `Component.define` must be HASH, got %s", args[1].Type())
+					}
+				}
+				version := int64(1)
+				if len(args) == 3 {
+					v, ok := args[2].(*object.Integer)
+					if !ok || v.Value < 1 {
+						return newError("third argument to `Component.define` must be a positive INTEGER, got %s", args[2].Inspect())
+					}
+					version = v.Value
+				}
+				copied, err := deepCopy(defaults, map[object.Object]bool{})
+				if err != nil {
+					return newError("defaults of %s: %s", name.Value, err.Message)
+				}
+				registry.defaults[name.Value] = copied.(*object.Hash)
+				registry.versions[name.Value] = version
+				return NULL
+			},
+		}},
+		// migrate registers fn(data, version) to upgrade a component loaded
+		// from a world saved with an older version of its schema.
+		{Name: "migrate", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				name, ok := args[0].(*object.String)
+				if !ok {
+					return newError("first argument to `Component.migrate` must be STRING, got %s", args[0].Type())
+				}
+				if !isCallable(args[1]) {
+					return newError("second argument to `Component.migrate` must be FUNCTION, got %s", args[1].Type())
+				}
+				registry.migrations[name.Value] = args[1]
+				return NULL
+			},
+		}},
+		{Name: "names", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+				return stringArray(registry.names())
+			},
+		}},
+	})
+}
+
+// deepCopy copies arrays and hashes recursively. Other values are immutable
+// or shared by reference, so they are returned as they are. Arrays and
+// hashes that contain themselves cannot be copied.
+func deepCopy(obj object.Object, visiting map[object.Object]bool) (object.Object, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if visiting[obj] {
+			return nil, newError("cannot copy cyclic ARRAY")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+		elements := make([]object.Object, len(obj.Elements))
+		for i, element := range obj.Elements {
+			copied, err := deepCopy(element, visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = copied
+		}
+		return &object.Array{Elements: elements}, nil
+	case *object.Hash:
+		if visiting[obj] {
+			return nil, newError("cannot copy cyclic HASH")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+		pairs := make(map[object.HashKey]object.HashPair, len(obj.Pairs))
+		for key, pair := range obj.Pairs {
+			copied, err := deepCopy(pair.Value, visiting)
+			if err != nil {
+				return nil, err
+			}
+			pairs[key] = object.HashPair{Key: pair.Key, Value: copied}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	default:
+		return obj, nil
+	}
+}
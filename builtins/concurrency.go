@@ -0,0 +1,165 @@
+package builtins
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// await blocks until a spawned task finishes and returns its result. An
+// error raised by the task is returned as the result.
+func await(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	task, ok := args[0].(*object.Task)
+	if !ok {
+		return newError("argument to `await` must be TASK, got %s", args[0].Type())
+	}
+	select {
+	case <-task.Done():
+		return task.Result()
+	case <-ctx.Context.Done():
+		return ctx.Env.Runtime().ContextError(ctx.Context)
+	}
+}
+
+// maxChannelCapacity is the largest buffer a channel may have.
+const maxChannelCapacity = 1 << 20
+
+// makeChannel creates a channel, unbuffered unless a capacity is given. The
+// buffer counts against the run's allocation limit, one object per slot.
+func makeChannel(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+	capacity := int64(0)
+	if len(args) == 1 {
+		size, ok := args[0].(*object.Integer)
+		if !ok || size.Value < 0 {
+			return newError("argument to `chan` must be a non-negative INTEGER, got %s", args[0].Inspect())
+		}
+		if size.Value > maxChannelCapacity {
+			return newError("channel capacity must be at most %d, got %d", maxChannelCapacity, size.Value)
+		}
+		capacity = size.Value
+	}
+	if err := ctx.Env.Runtime().Allocate(capacity); err != nil {
+		return err
+	}
+	return object.NewChannel(int(capacity))
+}
+
+func channelArg(name string, args []object.Object, want int) (*object.Channel, *object.Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+	ch, ok := args[0].(*object.Channel)
+	if !ok {
+		return nil, newError("first argument to `%s` must be CHANNEL, got %s", name, args[0].Type())
+	}
+	return ch, nil
+}
+
+func send(ctx *object.CallContext, args ...object.Object) object.Object {
+	ch, err := channelArg("send", args, 2)
+	if err != nil {
+		return err
+	}
+	return sendValue(ctx, ch, args[1])
+}
+
+func sendValue(ctx *object.CallContext, ch *object.Channel, value object.Object) (result object.Object) {
+	// Close may race with a blocked send, which panics in Go.
+	defer func() {
+		if recover() != nil {
+			result = newError("send on closed channel")
+		}
+	}()
+	if ch.Closed() {
+		return newError("send on closed channel")
+	}
+	select {
+	case ch.C <- value:
+		return NULL
+	case <-ctx.Context.Done():
+		return ctx.Env.Runtime().ContextError(ctx.Context)
+	}
+}
+
+// recv returns the next value sent on a channel, or null once the channel
+// is closed and drained.
+func recv(ctx *object.CallContext, args ...object.Object) object.Object {
+	ch, err := channelArg("recv", args, 1)
+	if err != nil {
+		return err
+	}
+	select {
+	case value, ok := <-ch.C:
+		if !ok {
+			return NULL
+		}
+		return value
+	case <-ctx.Context.Done():
+		return ctx.Env.Runtime().ContextError(ctx.Context)
+	}
+}
+
+func closeChannel(ctx *object.CallContext, args ...object.Object) object.Object {
+	ch, err := channelArg("close", args, 1)
+	if err != nil {
+		return err
+	}
+	if !ch.Close() {
+		return newError("close of closed channel")
+	}
+	return NULL
+}
+
+// selectChannels waits until one of an array of channels can be received
+// from and returns [index, value]. With a timeout in milliseconds it returns
+// [-1, null] if nothing arrives in time; a timeout of 0 never blocks.
+func selectChannels(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("first argument to `select` must be ARRAY, got %s", args[0].Type())
+	}
+	cases := make([]reflect.SelectCase, 0, len(arr.Elements)+2)
+	for _, element := range arr.Elements {
+		ch, ok := element.(*object.Channel)
+		if !ok {
+			return newError("`select` expects an ARRAY of CHANNEL, got %s", element.Type())
+		}
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.C)})
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Context.Done())})
+	if len(args) == 2 {
+		timeout, ok := args[1].(*object.Integer)
+		if !ok || timeout.Value < 0 {
+			return newError("timeout for `select` must be a non-negative INTEGER, got %s", args[1].Inspect())
+		}
+		if timeout.Value == 0 {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+		} else {
+			timer := time.NewTimer(time.Duration(timeout.Value) * time.Millisecond)
+			defer timer.Stop()
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+		}
+	}
+	chosen, value, ok := reflect.Select(cases)
+	switch {
+	case chosen == len(arr.Elements):
+		return ctx.Env.Runtime().ContextError(ctx.Context)
+	case chosen > len(arr.Elements):
+		return &object.Array{Elements: []object.Object{&object.Integer{Value: -1}, NULL}}
+	}
+	var received object.Object = NULL
+	if ok {
+		received = value.Interface().(object.Object)
+	}
+	return &object.Array{Elements: []object.Object{&object.Integer{Value: int64(chosen)}, received}}
+}
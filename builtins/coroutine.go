@@ -0,0 +1,82 @@
+package builtins
+
+import (
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// coroutine wraps a call of fn in a suspended coroutine, so ordinary
+// functions can use yield and wait too.
+func coroutine(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+	if !isCallable(args[0]) {
+		return newError("first argument to `coroutine` must be FUNCTION, got %s", args[0].Type())
+	}
+	return ctx.StartCoroutine(args[0], args[1:]...)
+}
+
+// wait suspends the running coroutine until the given number of seconds of
+// game time have passed. The world's tick resumes it.
+func wait(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	seconds, ok := numberArg(args[0])
+	if !ok || seconds < 0 {
+		return newError("argument to `wait` must be a non-negative number, got %s", args[0].Inspect())
+	}
+	co := ctx.Env.Coroutine()
+	if co == nil {
+		return newError("wait outside of a coroutine")
+	}
+	if _, ok := co.Yield(&object.Wait{Seconds: seconds}); !ok {
+		return newError("coroutine cancelled")
+	}
+	return NULL
+}
+
+func coroutineResume(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+	var value object.Object = NULL
+	if len(args) == 1 {
+		value = args[0]
+	}
+	return receiver.(*object.Coroutine).Resume(ctx, value)
+}
+
+func coroutineDone(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return nativeBool(receiver.(*object.Coroutine).Done())
+}
+
+func coroutineCancel(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	receiver.(*object.Coroutine).Cancel()
+	return NULL
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	}
+	return false
+}
+
+// numberArg returns the value of an INTEGER or FLOAT argument.
+func numberArg(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	}
+	return 0, false
+}
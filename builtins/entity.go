@@ -1,3 +1,298 @@
 package builtins
 
-// TODO: implement based on TS version of this api
+import (
+	"fmt"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+const ENTITY_OBJ = "ENTITY"
+
+// Entity is an identity in a World with a set of named components. Each
+// component is a hash that scripts read and modify directly.
+type Entity struct {
+	ID         int64
+	world      *World
+	components map[string]*object.Hash
+	order      []string
+	alive      bool
+	parent     *Entity
+	children   []*Entity
+}
+
+func (e *Entity) Type() object.ObjectType { return ENTITY_OBJ }
+func (e *Entity) Inspect() string {
+	if !e.alive {
+		return fmt.Sprintf("entity(%d, destroyed)", e.ID)
+	}
+	return fmt.Sprintf("entity(%d)", e.ID)
+}
+
+// World returns the world the entity belongs to.
+func (e *Entity) World() *World { return e.world }
+
+// Parent returns the entity's parent, or nil.
+func (e *Entity) Parent() *Entity { return e.parent }
+
+// Children returns the entity's children in the order they were added.
+func (e *Entity) Children() []*Entity {
+	return append([]*Entity(nil), e.children...)
+}
+
+// Alive reports whether the entity has not been destroyed.
+func (e *Entity) Alive() bool { return e.alive }
+
+// Component returns the component called name, or nil.
+func (e *Entity) Component(name string) *object.Hash {
+	return e.components[name]
+}
+
+// ComponentNames returns the names of the entity's components in the order
+// they were added.
+func (e *Entity) ComponentNames() []string {
+	return append([]string(nil), e.order...)
+}
+
+// AddComponent attaches a component built from the registered defaults and
+// data, replacing any component with the same name.
+func (e *Entity) AddComponent(name string, data *object.Hash) *object.Hash {
+	component := e.world.components.instantiate(name, data)
+	if _, exists := e.components[name]; !exists {
+		e.order = append(e.order, name)
+	}
+	e.components[name] = component
+	return component
+}
+
+// RemoveComponent detaches the component called name and reports whether it
+// was present.
+func (e *Entity) RemoveComponent(name string) bool {
+	if _, exists := e.components[name]; !exists {
+		return false
+	}
+	delete(e.components, name)
+	for i, n := range e.order {
+		if n == name {
+			e.order = append(e.order[:i], e.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// SetParent moves the entity under parent, or makes it a root if parent is
+// nil. Its Transform stays relative to its new parent.
+func (e *Entity) SetParent(parent *Entity) *object.Error {
+	if !e.alive {
+		return newError("entity %d has been destroyed", e.ID)
+	}
+	if parent != nil {
+		if !parent.alive {
+			return newError("entity %d has been destroyed", parent.ID)
+		}
+		if parent.world != e.world {
+			return newError("entity %d belongs to another world", parent.ID)
+		}
+		for ancestor := parent; ancestor != nil; ancestor = ancestor.parent {
+			if ancestor == e {
+				return newError("cannot make entity %d a child of its descendant %d", e.ID, parent.ID)
+			}
+		}
+	}
+	e.detach()
+	if parent != nil {
+		e.attach(parent)
+	}
+	return nil
+}
+
+func (e *Entity) attach(parent *Entity) {
+	e.parent = parent
+	parent.children = append(parent.children, e)
+}
+
+func (e *Entity) detach() {
+	parent := e.parent
+	if parent == nil {
+		return
+	}
+	for i, child := range parent.children {
+		if child == e {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			break
+		}
+	}
+	e.parent = nil
+}
+
+// HasComponents reports whether the entity has every named component.
+func (e *Entity) HasComponents(names []string) bool {
+	for _, name := range names {
+		if _, ok := e.components[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+var entityMethods = map[string]Method{
+	"id":         entityID,
+	"add":        entityAdd,
+	"get":        entityGet,
+	"has":        entityHas,
+	"remove":     entityRemove,
+	"components": entityComponents,
+	"destroy":    entityDestroy,
+	"alive":      entityAlive,
+	"start":      entityStart,
+	"world":      entityWorld,
+	"parent":     entityParent,
+	"setParent":  entitySetParent,
+	"children":   entityChildren,
+	"on":         entityOn,
+	"once":       entityOnce,
+	"off":        entityOff,
+	"emit":       entityEmit,
+}
+
+func entityID(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Integer{Value: receiver.(*Entity).ID}
+}
+
+// entityAdd attaches a component: entity.add("Health", {"hp": 10}).
+func entityAdd(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	entity := receiver.(*Entity)
+	if !entity.alive {
+		return newError("entity %d has been destroyed", entity.ID)
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `add` must be STRING, got %s", args[0].Type())
+	}
+	var data *object.Hash
+	if len(args) == 2 {
+		if data, ok = args[1].(*object.Hash); !ok {
+			return newError("second argument to `add` must be HASH, got %s", args[1].Type())
+		}
+	}
+	return entity.AddComponent(name.Value, data)
+}
+
+func entityGet(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	names, err := stringArgs("get", args, 1)
+	if err != nil {
+		return err
+	}
+	if component := receiver.(*Entity).Component(names[0]); component != nil {
+		return component
+	}
+	return NULL
+}
+
+// entityHas reports whether the entity has all of the named components.
+func entityHas(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	names, err := stringArgs("has", args, len(args))
+	if err != nil {
+		return err
+	}
+	return nativeBool(receiver.(*Entity).HasComponents(names))
+}
+
+func entityRemove(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	names, err := stringArgs("remove", args, 1)
+	if err != nil {
+		return err
+	}
+	return nativeBool(receiver.(*Entity).RemoveComponent(names[0]))
+}
+
+func entityComponents(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return stringArray(receiver.(*Entity).order)
+}
+
+func entityDestroy(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	entity := receiver.(*Entity)
+	entity.world.Destroy(entity)
+	return NULL
+}
+
+func entityAlive(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return nativeBool(receiver.(*Entity).alive)
+}
+
+// entityStart runs fn(entity, args...) as a coroutine owned by the entity.
+// It runs until its first yield or wait immediately and is then resumed by
+// world.tick; destroying the entity cancels it.
+func entityStart(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+	entity := receiver.(*Entity)
+	if !entity.alive {
+		return newError("entity %d has been destroyed", entity.ID)
+	}
+	if !isCallable(args[0]) {
+		return newError("first argument to `start` must be FUNCTION, got %s", args[0].Type())
+	}
+	fnArgs := append([]object.Object{entity}, args[1:]...)
+	return entity.world.StartCoroutine(ctx, ctx.StartCoroutine(args[0], fnArgs...), entity)
+}
+
+func entityWorld(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return receiver.(*Entity).world
+}
+
+func entityParent(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	if parent := receiver.(*Entity).parent; parent != nil {
+		return parent
+	}
+	return NULL
+}
+
+func entityChildren(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return entityArray(receiver.(*Entity).children)
+}
+
+// entitySetParent implements entity.setParent(parent) and
+// entity.setParent(null).
+func entitySetParent(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	var parent *Entity
+	switch arg := args[0].(type) {
+	case *Entity:
+		parent = arg
+	case *object.Null:
+	default:
+		return newError("argument to `setParent` must be ENTITY or NULL, got %s", args[0].Type())
+	}
+	if err := receiver.(*Entity).SetParent(parent); err != nil {
+		return err
+	}
+	return NULL
+}
@@ -0,0 +1,236 @@
+package builtins
+
+import (
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// eventBus queues events emitted in a world and delivers them to handlers
+// during World.Tick. Events are delivered in the order they were emitted,
+// and each event goes to its handlers in the order they were registered.
+type eventBus struct {
+	handlers map[string][]*eventHandler
+	queue    []*event
+	nextID   int64
+}
+
+type eventHandler struct {
+	id      int64
+	name    string
+	fn      object.Object
+	once    bool
+	entity  *Entity
+	removed bool
+}
+
+type event struct {
+	name   string
+	source *Entity
+	data   *object.Hash
+}
+
+func (b *eventBus) on(name string, fn object.Object, once bool, entity *Entity) int64 {
+	if b.handlers == nil {
+		b.handlers = map[string][]*eventHandler{}
+	}
+	b.nextID++
+	b.handlers[name] = append(b.handlers[name], &eventHandler{
+		id: b.nextID, name: name, fn: fn, once: once, entity: entity,
+	})
+	return b.nextID
+}
+
+// off removes the handlers for which match returns true and reports how
+// many were removed.
+func (b *eventBus) off(match func(h *eventHandler) bool) int {
+	removed := 0
+	for name, handlers := range b.handlers {
+		kept := handlers[:0]
+		for _, h := range handlers {
+			if match(h) {
+				h.removed = true
+				removed++
+			} else {
+				kept = append(kept, h)
+			}
+		}
+		if len(kept) == 0 {
+			delete(b.handlers, name)
+		} else {
+			b.handlers[name] = kept
+		}
+	}
+	return removed
+}
+
+func (b *eventBus) emit(name string, source *Entity, data *object.Hash) {
+	b.queue = append(b.queue, &event{name: name, source: source, data: data})
+}
+
+// dispatch delivers the events queued so far. Events emitted by handlers
+// are delivered on the next dispatch, so a handler that emits the event it
+// handles cannot loop forever within one tick. A handler that fails does
+// not keep the event from its other handlers: the first error is returned
+// once they have all run, and the events after it are left queued for the
+// next dispatch.
+func (b *eventBus) dispatch(ctx *object.CallContext) object.Object {
+	queue := b.queue
+	b.queue = nil
+	for i, e := range queue {
+		var failed object.Object
+		handlers := append([]*eventHandler(nil), b.handlers[e.name]...)
+		for _, h := range handlers {
+			if h.removed || (h.entity != nil && h.entity != e.source) {
+				continue
+			}
+			if h.once {
+				b.off(func(other *eventHandler) bool { return other == h })
+			}
+			if result := ctx.Call(h.fn, e.object()); isError(result) && failed == nil {
+				failed = result
+			}
+		}
+		if failed != nil {
+			b.queue = append(queue[i+1:], b.queue...)
+			return failed
+		}
+	}
+	return NULL
+}
+
+// object returns the value passed to handlers: a copy of the event data
+// with "type" set to the event name and "source" to the emitting entity,
+// or null for events emitted by the world.
+func (e *event) object() *object.Hash {
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	if e.data != nil {
+		for key, pair := range e.data.Pairs {
+			hash.Pairs[key] = pair
+		}
+	}
+	var source object.Object = NULL
+	if e.source != nil {
+		source = e.source
+	}
+	setField(hash, "type", &object.String{Value: e.name})
+	setField(hash, "source", source)
+	return hash
+}
+
+func setField(hash *object.Hash, name string, value object.Object) {
+	key := &object.String{Value: name}
+	hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+}
+
+// Emit queues an event to be delivered on the next tick. source may be nil
+// for events that do not come from an entity.
+func (w *World) Emit(name string, source *Entity, data *object.Hash) {
+	w.events.emit(name, source, data)
+}
+
+// eventListener registers fn for the named event: on("damaged", fn) or
+// once("damaged", fn). Handlers registered through an entity only receive
+// events that entity emits. It returns the handler id for off.
+func eventListener(method string, once bool, args []object.Object, events *eventBus, entity *Entity) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `%s` must be STRING, got %s", method, args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return newError("second argument to `%s` must be FUNCTION, got %s", method, args[1].Type())
+	}
+	return &object.Integer{Value: events.on(name.Value, args[1], once, entity)}
+}
+
+// removeListeners implements off: off(id) removes one handler, off(name)
+// every handler for an event and off(name, fn) the handlers for that event
+// that call fn. It returns the number of handlers removed.
+func removeListeners(args []object.Object, events *eventBus, entity *Entity) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	var match func(h *eventHandler) bool
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		match = func(h *eventHandler) bool { return h.id == arg.Value }
+	case *object.String:
+		match = func(h *eventHandler) bool {
+			return h.name == arg.Value && (len(args) == 1 || h.fn == args[1])
+		}
+	default:
+		return newError("first argument to `off` must be INTEGER or STRING, got %s", args[0].Type())
+	}
+	removed := events.off(func(h *eventHandler) bool {
+		return (entity == nil || h.entity == entity) && match(h)
+	})
+	return &object.Integer{Value: int64(removed)}
+}
+
+// emitEvent queues an event: emit("damaged", {"amount": 10}).
+func emitEvent(args []object.Object, events *eventBus, source *Entity) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `emit` must be STRING, got %s", args[0].Type())
+	}
+	var data *object.Hash
+	if len(args) == 2 {
+		if data, ok = args[1].(*object.Hash); !ok {
+			return newError("second argument to `emit` must be HASH, got %s", args[1].Type())
+		}
+	}
+	events.emit(name.Value, source, data)
+	return NULL
+}
+
+func worldOn(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	return eventListener("on", false, args, &receiver.(*World).events, nil)
+}
+
+func worldOnce(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	return eventListener("once", true, args, &receiver.(*World).events, nil)
+}
+
+func worldOff(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	return removeListeners(args, &receiver.(*World).events, nil)
+}
+
+func worldEmit(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	return emitEvent(args, &receiver.(*World).events, nil)
+}
+
+func entityOn(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	entity := receiver.(*Entity)
+	if !entity.alive {
+		return newError("entity %d has been destroyed", entity.ID)
+	}
+	return eventListener("on", false, args, &entity.world.events, entity)
+}
+
+func entityOnce(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	entity := receiver.(*Entity)
+	if !entity.alive {
+		return newError("entity %d has been destroyed", entity.ID)
+	}
+	return eventListener("once", true, args, &entity.world.events, entity)
+}
+
+func entityOff(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	entity := receiver.(*Entity)
+	return removeListeners(args, &entity.world.events, entity)
+}
+
+func entityEmit(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	entity := receiver.(*Entity)
+	if !entity.alive {
+		return newError("entity %d has been destroyed", entity.ID)
+	}
+	return emitEvent(args, &entity.world.events, entity)
+}
@@ -0,0 +1,179 @@
+package builtins
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+func fsModule() *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "read", Obj: &object.Builtin{Fn: fsRead}},
+		{Name: "write", Obj: &object.Builtin{Fn: fsWrite}},
+		{Name: "append", Obj: &object.Builtin{Fn: fsAppend}},
+		{Name: "exists", Obj: &object.Builtin{Fn: fsExists}},
+		{Name: "list", Obj: &object.Builtin{Fn: fsList}},
+		{Name: "remove", Obj: &object.Builtin{Fn: fsRemove}},
+		{Name: "mkdir", Obj: &object.Builtin{Fn: fsMkdir}},
+	})
+}
+
+// resolvePath maps a script path onto the host filesystem. Relative paths
+// are resolved against the first root in Runtime.FSRoots, and the result,
+// after following symlinks, must lie inside one of the roots.
+func resolvePath(ctx *object.CallContext, path string) (string, *object.Error) {
+	roots := ctx.Env.Runtime().FSRoots
+	if len(roots) == 0 {
+		return "", &object.Error{Message: "permission denied: no directories granted to fs", Kind: object.PERMISSION_ERROR}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(roots[0], path)
+	}
+	resolved, err := evalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", newError("cannot resolve %s: %s", path, err)
+	}
+	for _, root := range roots {
+		root, err := evalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", &object.Error{Message: "permission denied: " + path + " is outside the granted directories", Kind: object.PERMISSION_ERROR}
+}
+
+// evalSymlinks resolves symlinks in the longest existing prefix of path, so
+// paths of files that do not exist yet can be checked too.
+func evalSymlinks(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	dir, file := filepath.Split(path)
+	if dir == path || file == "" {
+		return path, nil
+	}
+	parent, err := evalSymlinks(filepath.Clean(dir))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, file), nil
+}
+
+func pathArgs(ctx *object.CallContext, name string, args []object.Object, want int) ([]string, *object.Error) {
+	values, err := stringArgs(name, args, want)
+	if err != nil {
+		return nil, err
+	}
+	path, err := resolvePath(ctx, values[0])
+	if err != nil {
+		return nil, err
+	}
+	values[0] = path
+	return values, nil
+}
+
+func fsRead(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := pathArgs(ctx, "fs.read", args, 1)
+	if err != nil {
+		return err
+	}
+	data, err := readFile(ctx, values[0])
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: data}
+}
+
+func fsWrite(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := pathArgs(ctx, "fs.write", args, 2)
+	if err != nil {
+		return err
+	}
+	if writeErr := os.WriteFile(values[0], []byte(values[1]), 0644); writeErr != nil {
+		return newError("cannot write file: %s", writeErr)
+	}
+	return NULL
+}
+
+func fsAppend(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := pathArgs(ctx, "fs.append", args, 2)
+	if err != nil {
+		return err
+	}
+	file, openErr := os.OpenFile(values[0], os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return newError("cannot write file: %s", openErr)
+	}
+	defer file.Close()
+	if _, writeErr := file.WriteString(values[1]); writeErr != nil {
+		return newError("cannot write file: %s", writeErr)
+	}
+	return NULL
+}
+
+func fsExists(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := pathArgs(ctx, "fs.exists", args, 1)
+	if err != nil {
+		return err
+	}
+	return journaled(ctx, "fs.exists", func() object.Object {
+		_, statErr := os.Stat(values[0])
+		return nativeBool(statErr == nil)
+	})
+}
+
+// fsList returns the sorted names of the entries in a directory.
+func fsList(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := pathArgs(ctx, "fs.list", args, 1)
+	if err != nil {
+		return err
+	}
+	return journaled(ctx, "fs.list", func() object.Object {
+		entries, readErr := os.ReadDir(values[0])
+		if readErr != nil {
+			return newError("cannot list directory: %s", readErr)
+		}
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		return stringArray(names)
+	})
+}
+
+func fsRemove(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := pathArgs(ctx, "fs.remove", args, 1)
+	if err != nil {
+		return err
+	}
+	if removeErr := os.Remove(values[0]); removeErr != nil {
+		return newError("cannot remove: %s", removeErr)
+	}
+	return NULL
+}
+
+func fsMkdir(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := pathArgs(ctx, "fs.mkdir", args, 1)
+	if err != nil {
+		return err
+	}
+	if mkdirErr := os.MkdirAll(values[0], 0755); mkdirErr != nil {
+		return newError("cannot create directory: %s", mkdirErr)
+	}
+	return NULL
+}
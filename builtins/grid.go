@@ -0,0 +1,712 @@
+package builtins
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+const GRID_OBJ = "GRID"
+
+// maxGridCells is the largest number of cells a grid may have.
+const maxGridCells = 1 << 24
+
+// Grid is a rectangular map of cells addressed by integer x and y, with
+// (0, 0) in the top-left corner. Methods that return points return them as
+// [x, y] arrays.
+type Grid struct {
+	Width, Height int
+	cells         []object.Object
+}
+
+// NewGrid returns a width by height grid with every cell set to fill. The
+// size must be one that checkGridSize accepts.
+func NewGrid(width, height int, fill object.Object) *Grid {
+	g := &Grid{Width: width, Height: height, cells: make([]object.Object, width*height)}
+	for i := range g.cells {
+		g.cells[i] = fill
+	}
+	return g
+}
+
+func (g *Grid) Type() object.ObjectType { return GRID_OBJ }
+func (g *Grid) Inspect() string         { return fmt.Sprintf("grid(%dx%d)", g.Width, g.Height) }
+
+// InBounds reports whether (x, y) is a cell of the grid.
+func (g *Grid) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.Width && y < g.Height
+}
+
+// At returns the value of a cell, or nil outside the grid.
+func (g *Grid) At(x, y int) object.Object {
+	if !g.InBounds(x, y) {
+		return nil
+	}
+	return g.cells[y*g.Width+x]
+}
+
+// Set changes the value of a cell inside the grid.
+func (g *Grid) Set(x, y int, value object.Object) {
+	g.cells[y*g.Width+x] = value
+}
+
+// checkGridSize returns an error if a width by height grid would be
+// negative or have more than maxGridCells cells.
+func checkGridSize(width, height int) *object.Error {
+	if width < 0 || height < 0 {
+		return newError("grid size must not be negative, got %dx%d", width, height)
+	}
+	if width > 0 && height > maxGridCells/width {
+		return newError("grid of %dx%d cells is larger than the maximum of %d cells", width, height, maxGridCells)
+	}
+	return nil
+}
+
+func (g *Grid) outside(x, y int) *object.Error {
+	return newError("cell (%d, %d) is outside the %dx%d grid", x, y, g.Width, g.Height)
+}
+
+func point(x, y int) *object.Array {
+	return &object.Array{Elements: []object.Object{&object.Integer{Value: int64(x)}, &object.Integer{Value: int64(y)}}}
+}
+
+// isWall is the default test for cells that block movement and sight:
+// "#" in grids parsed from text and true in boolean grids.
+func isWall(value object.Object) bool {
+	switch value := value.(type) {
+	case *object.String:
+		return value.Value == "#"
+	case *object.Boolean:
+		return value.Value
+	}
+	return false
+}
+
+func truthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return false
+	case *object.Boolean:
+		return obj.Value
+	}
+	return true
+}
+
+// sameValue compares cells by value when they are hashable and by identity
+// otherwise.
+func sameValue(a, b object.Object) bool {
+	ha, okA := a.(object.Hashable)
+	hb, okB := b.(object.Hashable)
+	if okA && okB {
+		return ha.HashKey() == hb.HashKey()
+	}
+	return a == b
+}
+
+// ParseGrid makes a grid from text with one row per line and one cell, a
+// one-character STRING, per character.
+func ParseGrid(text string) (*Grid, *object.Error) {
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil, newError("grid text is empty")
+	}
+	lines := strings.Split(text, "\n")
+	width := utf8.RuneCountInString(lines[0])
+	g := NewGrid(width, len(lines), NULL)
+	for y, line := range lines {
+		if n := utf8.RuneCountInString(line); n != width {
+			return nil, newError("line %d has %d cells, want %d", y+1, n, width)
+		}
+		x := 0
+		for _, r := range line {
+			g.Set(x, y, &object.String{Value: string(r)})
+			x++
+		}
+	}
+	return g, nil
+}
+
+// ParseGridCSV makes a grid from CSV text with one row per record. Fields
+// that are whole numbers become INTEGER cells, other numbers FLOAT and
+// everything else STRING.
+func ParseGridCSV(text string) (*Grid, *object.Error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, newError("invalid CSV: %s", err)
+	}
+	if len(records) == 0 {
+		return nil, newError("grid text is empty")
+	}
+	g := NewGrid(len(records[0]), len(records), NULL)
+	for y, record := range records {
+		for x, field := range record {
+			g.Set(x, y, parseCell(strings.TrimSpace(field)))
+		}
+	}
+	return g, nil
+}
+
+func parseCell(field string) object.Object {
+	if i, err := strconv.ParseInt(field, 10, 64); err == nil {
+		return &object.Integer{Value: i}
+	}
+	if f, err := strconv.ParseFloat(field, 64); err == nil {
+		return &object.Float{Value: f}
+	}
+	return &object.String{Value: field}
+}
+
+// allocateGrid counts a width by height grid against the run's allocation
+// limit, one object per cell.
+func allocateGrid(ctx *object.CallContext, width, height int) *object.Error {
+	return ctx.Env.Runtime().Allocate(int64(width)*int64(height) + 1)
+}
+
+func gridResult(ctx *object.CallContext, g *Grid, err *object.Error) object.Object {
+	if err != nil {
+		return err
+	}
+	if err := allocateGrid(ctx, g.Width, g.Height); err != nil {
+		return err
+	}
+	return g
+}
+
+func gridModule() *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "create", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 2 && len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+				}
+				size, err := intArgs("Grid.create", args[:2])
+				if err != nil {
+					return err
+				}
+				if err := checkGridSize(size[0], size[1]); err != nil {
+					return err
+				}
+				if err := allocateGrid(ctx, size[0], size[1]); err != nil {
+					return err
+				}
+				var fill object.Object = &object.Integer{Value: 0}
+				if len(args) == 3 {
+					fill = args[2]
+				}
+				return NewGrid(size[0], size[1], fill)
+			},
+		}},
+		{Name: "parse", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				values, err := stringArgs("Grid.parse", args, 1)
+				if err != nil {
+					return err
+				}
+				g, err := ParseGrid(values[0])
+				return gridResult(ctx, g, err)
+			},
+		}},
+		{Name: "parseCSV", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				values, err := stringArgs("Grid.parseCSV", args, 1)
+				if err != nil {
+					return err
+				}
+				g, err := ParseGridCSV(values[0])
+				return gridResult(ctx, g, err)
+			},
+		}},
+		// load reads a grid from a file, as CSV if its name ends in .csv
+		// and as text otherwise.
+		{Name: "load", Obj: &object.Builtin{
+			Capability: object.FS_CAPABILITY,
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				values, err := pathArgs(ctx, "Grid.load", args, 1)
+				if err != nil {
+					return err
+				}
+				data, err := readFile(ctx, values[0])
+				if err != nil {
+					return err
+				}
+				if strings.EqualFold(filepath.Ext(values[0]), ".csv") {
+					g, err := ParseGridCSV(data)
+					return gridResult(ctx, g, err)
+				}
+				g, err := ParseGrid(string(data))
+				return gridResult(ctx, g, err)
+			},
+		}},
+	})
+}
+
+// intArgs returns the values of INTEGER arguments.
+func intArgs(name string, args []object.Object) ([]int, *object.Error) {
+	values := make([]int, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return nil, newError("argument to `%s` must be INTEGER, got %s", name, arg.Type())
+		}
+		values[i] = int(integer.Value)
+	}
+	return values, nil
+}
+
+// cellArgs reads want coordinates followed by up to optional extra
+// arguments, and checks that the coordinates are inside the grid.
+func cellArgs(g *Grid, name string, args []object.Object, want, optional int) ([]int, *object.Error) {
+	if len(args) < want || len(args) > want+optional {
+		if optional == 0 {
+			return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+		}
+		return nil, newError("wrong number of arguments. got=%d, want=%d to %d", len(args), want, want+optional)
+	}
+	values, err := intArgs(name, args[:want])
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < want; i += 2 {
+		if !g.InBounds(values[i], values[i+1]) {
+			return nil, g.outside(values[i], values[i+1])
+		}
+	}
+	return values, nil
+}
+
+var gridMethods = map[string]Method{
+	"width": func(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+		return &object.Integer{Value: int64(receiver.(*Grid).Width)}
+	},
+	"height": func(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+		return &object.Integer{Value: int64(receiver.(*Grid).Height)}
+	},
+	"get":         gridGet,
+	"set":         gridSet,
+	"inBounds":    gridInBounds,
+	"fill":        gridFill,
+	"toArray":     gridToArray,
+	"neighbors":   gridNeighbors,
+	"floodFill":   gridFloodFill,
+	"lineOfSight": gridLineOfSight,
+	"astar": func(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+		return gridPath(ctx, receiver.(*Grid), "astar", true, args)
+	},
+	"dijkstra": func(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+		return gridPath(ctx, receiver.(*Grid), "dijkstra", false, args)
+	},
+}
+
+// gridGet returns the value of a cell, or null outside the grid.
+func gridGet(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	xy, err := intArgs("get", args)
+	if err != nil {
+		return err
+	}
+	if value := receiver.(*Grid).At(xy[0], xy[1]); value != nil {
+		return value
+	}
+	return NULL
+}
+
+func gridSet(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	g := receiver.(*Grid)
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+	xy, err := cellArgs(g, "set", args[:2], 2, 0)
+	if err != nil {
+		return err
+	}
+	g.Set(xy[0], xy[1], args[2])
+	return NULL
+}
+
+func gridInBounds(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	xy, err := intArgs("inBounds", args)
+	if err != nil {
+		return err
+	}
+	return nativeBool(receiver.(*Grid).InBounds(xy[0], xy[1]))
+}
+
+func gridFill(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	g := receiver.(*Grid)
+	for i := range g.cells {
+		g.cells[i] = args[0]
+	}
+	return NULL
+}
+
+// gridToArray returns the cells as an array of rows.
+func gridToArray(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	g := receiver.(*Grid)
+	rows := make([]object.Object, g.Height)
+	for y := range rows {
+		rows[y] = &object.Array{Elements: append([]object.Object(nil), g.cells[y*g.Width:(y+1)*g.Width]...)}
+	}
+	return &object.Array{Elements: rows}
+}
+
+// orthogonal and diagonal are the neighbour offsets in the order they are
+// visited: up, right, down, left, then clockwise from the top right.
+var (
+	orthogonal = [][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+	diagonal   = [][2]int{{1, -1}, {1, 1}, {-1, 1}, {-1, -1}}
+)
+
+// gridNeighbors returns the points next to (x, y) that are inside the grid,
+// including diagonal ones if the third argument is true.
+func gridNeighbors(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	g := receiver.(*Grid)
+	xy, err := cellArgs(g, "neighbors", args, 2, 1)
+	if err != nil {
+		return err
+	}
+	offsets := orthogonal
+	if len(args) == 3 {
+		withDiagonals, ok := args[2].(*object.Boolean)
+		if !ok {
+			return newError("third argument to `neighbors` must be BOOLEAN, got %s", args[2].Type())
+		}
+		if withDiagonals.Value {
+			offsets = append(append([][2]int(nil), orthogonal...), diagonal...)
+		}
+	}
+	points := []object.Object{}
+	for _, offset := range offsets {
+		x, y := xy[0]+offset[0], xy[1]+offset[1]
+		if g.InBounds(x, y) {
+			points = append(points, point(x, y))
+		}
+	}
+	return &object.Array{Elements: points}
+}
+
+// gridFloodFill returns the points of the region of equal cells connected
+// to (x, y) through their sides, in the order they are reached. With a
+// third argument the cells of the region are also set to it.
+func gridFloodFill(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	g := receiver.(*Grid)
+	xy, err := cellArgs(g, "floodFill", args, 2, 1)
+	if err != nil {
+		return err
+	}
+	target := g.At(xy[0], xy[1])
+	visited := make([]bool, len(g.cells))
+	visited[xy[1]*g.Width+xy[0]] = true
+	queue := [][2]int{{xy[0], xy[1]}}
+	points := []object.Object{}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		points = append(points, point(cell[0], cell[1]))
+		for _, offset := range orthogonal {
+			x, y := cell[0]+offset[0], cell[1]+offset[1]
+			if !g.InBounds(x, y) || visited[y*g.Width+x] || !sameValue(g.At(x, y), target) {
+				continue
+			}
+			visited[y*g.Width+x] = true
+			queue = append(queue, [2]int{x, y})
+		}
+	}
+	if len(args) == 3 {
+		for _, p := range points {
+			elements := p.(*object.Array).Elements
+			g.Set(int(elements[0].(*object.Integer).Value), int(elements[1].(*object.Integer).Value), args[2])
+		}
+	}
+	return &object.Array{Elements: points}
+}
+
+// gridLineOfSight reports whether no cell strictly between two points
+// blocks the straight line joining them. Cells block if blocked(value, x,
+// y) is truthy, or by default if they are walls.
+func gridLineOfSight(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	g := receiver.(*Grid)
+	xy, err := cellArgs(g, "lineOfSight", args, 4, 1)
+	if err != nil {
+		return err
+	}
+	var blocked object.Object
+	if len(args) == 5 {
+		if !isCallable(args[4]) {
+			return newError("fifth argument to `lineOfSight` must be FUNCTION, got %s", args[4].Type())
+		}
+		blocked = args[4]
+	}
+	x, y := xy[0], xy[1]
+	nx, ny := abs64(xy[2]-x), abs64(xy[3]-y)
+	sx, sy := 1, 1
+	if xy[2] < x {
+		sx = -1
+	}
+	if xy[3] < y {
+		sy = -1
+	}
+	// Walk the cells the line passes through one side at a time, so a
+	// diagonal line cannot slip between two walls that touch at a corner.
+	for ix, iy := 0, 0; ix < nx || iy < ny; {
+		if (1+2*ix)*ny < (1+2*iy)*nx {
+			x += sx
+			ix++
+		} else {
+			y += sy
+			iy++
+		}
+		if ix == nx && iy == ny {
+			break
+		}
+		if blocked == nil {
+			if isWall(g.At(x, y)) {
+				return FALSE
+			}
+			continue
+		}
+		result := ctx.Call(blocked, g.At(x, y), &object.Integer{Value: int64(x)}, &object.Integer{Value: int64(y)})
+		if isError(result) {
+			return result
+		}
+		if truthy(result) {
+			return FALSE
+		}
+	}
+	return TRUE
+}
+
+func abs64(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// pathNode is an entry in the open set of a path search.
+type pathNode struct {
+	cell     int
+	priority float64
+	seq      int
+}
+
+// pathQueue orders nodes by priority, breaking ties by insertion order so
+// searches are deterministic.
+type pathQueue []pathNode
+
+func (q pathQueue) Len() int { return len(q) }
+func (q pathQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathNode)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// cellCosts calls a script cost function at most once per cell. A cost of
+// null or false makes the cell impassable.
+type cellCosts struct {
+	ctx   *object.CallContext
+	grid  *Grid
+	fn    object.Object
+	known map[int]float64
+}
+
+func (c *cellCosts) cost(x, y int) (float64, *object.Error) {
+	index := y*c.grid.Width + x
+	if cost, ok := c.known[index]; ok {
+		return cost, nil
+	}
+	value := c.grid.At(x, y)
+	cost := 1.0
+	if c.fn == nil {
+		if isWall(value) {
+			cost = math.Inf(1)
+		}
+	} else {
+		result := c.ctx.Call(c.fn, value, &object.Integer{Value: int64(x)}, &object.Integer{Value: int64(y)})
+		if err, ok := result.(*object.Error); ok {
+			return 0, err
+		}
+		switch result := result.(type) {
+		case *object.Null:
+			cost = math.Inf(1)
+		case *object.Boolean:
+			if result.Value {
+				return 0, newError("cost of cell (%d, %d) must be a number, null or false, got true", x, y)
+			}
+			cost = math.Inf(1)
+		default:
+			number, ok := numberArg(result)
+			if !ok {
+				return 0, newError("cost of cell (%d, %d) must be a number, null or false, got %s", x, y, result.Type())
+			}
+			if number < 0 {
+				return 0, newError("cost of cell (%d, %d) must not be negative, got %s", x, y, result.Inspect())
+			}
+			cost = number
+		}
+	}
+	c.known[index] = cost
+	return cost, nil
+}
+
+// gridPath implements astar and dijkstra: (fromX, fromY, toX, toY,
+// options?) returns the cheapest path as points from start to goal, or null
+// if there is none. options is a cost function(value, x, y) or a hash with
+// "cost" and "diagonal". A* estimates the remaining cost as one per step,
+// so it finds the cheapest path only when no cell costs less than 1.
+func gridPath(ctx *object.CallContext, g *Grid, name string, estimate bool, args []object.Object) object.Object {
+	xy, err := cellArgs(g, name, args, 4, 1)
+	if err != nil {
+		return err
+	}
+	costs := &cellCosts{ctx: ctx, grid: g, known: map[int]float64{}}
+	diagonals := false
+	if len(args) == 5 {
+		switch options := args[4].(type) {
+		case *object.Hash:
+			for _, pair := range options.Pairs {
+				switch key := pair.Key.Inspect(); key {
+				case "cost":
+					if !isCallable(pair.Value) {
+						return newError("cost option of `%s` must be FUNCTION, got %s", name, pair.Value.Type())
+					}
+					costs.fn = pair.Value
+				case "diagonal":
+					value, ok := pair.Value.(*object.Boolean)
+					if !ok {
+						return newError("diagonal option of `%s` must be BOOLEAN, got %s", name, pair.Value.Type())
+					}
+					diagonals = value.Value
+				default:
+					return newError("unknown option %s for `%s`", key, name)
+				}
+			}
+		default:
+			if !isCallable(options) {
+				return newError("fifth argument to `%s` must be FUNCTION or HASH, got %s", name, options.Type())
+			}
+			costs.fn = options
+		}
+	}
+
+	start, goal := xy[1]*g.Width+xy[0], xy[3]*g.Width+xy[2]
+	goalX, goalY := xy[2], xy[3]
+	heuristic := func(x, y int) float64 {
+		if !estimate {
+			return 0
+		}
+		dx, dy := float64(abs64(x-goalX)), float64(abs64(y-goalY))
+		if diagonals {
+			return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+		}
+		return dx + dy
+	}
+	spent := make([]float64, len(g.cells))
+	for i := range spent {
+		spent[i] = math.Inf(1)
+	}
+	from := make([]int, len(g.cells))
+	closed := make([]bool, len(g.cells))
+	spent[start] = 0
+	open := &pathQueue{{cell: start, priority: heuristic(xy[0], xy[1])}}
+	seq := 0
+	for open.Len() > 0 {
+		current := heap.Pop(open).(pathNode).cell
+		if closed[current] {
+			continue
+		}
+		if current == goal {
+			return pathPoints(g, from, start, goal)
+		}
+		closed[current] = true
+		cx, cy := current%g.Width, current/g.Width
+		offsets := orthogonal
+		if diagonals {
+			offsets = append(append([][2]int(nil), orthogonal...), diagonal...)
+		}
+		for _, offset := range offsets {
+			x, y := cx+offset[0], cy+offset[1]
+			if !g.InBounds(x, y) || closed[y*g.Width+x] {
+				continue
+			}
+			cost, err := costs.cost(x, y)
+			if err != nil {
+				return err
+			}
+			if offset[0] != 0 && offset[1] != 0 {
+				// Moving diagonally must not cut the corner of a blocked cell.
+				sideX, err := costs.cost(cx+offset[0], cy)
+				if err != nil {
+					return err
+				}
+				sideY, err := costs.cost(cx, cy+offset[1])
+				if err != nil {
+					return err
+				}
+				if math.IsInf(sideX, 1) || math.IsInf(sideY, 1) {
+					continue
+				}
+				cost *= math.Sqrt2
+			}
+			if math.IsInf(cost, 1) {
+				continue
+			}
+			next := y*g.Width + x
+			if total := spent[current] + cost; total < spent[next] {
+				spent[next] = total
+				from[next] = current
+				seq++
+				heap.Push(open, pathNode{cell: next, priority: total + heuristic(x, y), seq: seq})
+			}
+		}
+	}
+	return NULL
+}
+
+func pathPoints(g *Grid, from []int, start, goal int) *object.Array {
+	var cells []int
+	for cell := goal; cell != start; cell = from[cell] {
+		cells = append(cells, cell)
+	}
+	cells = append(cells, start)
+	points := make([]object.Object, len(cells))
+	for i, cell := range cells {
+		points[len(cells)-1-i] = point(cell%g.Width, cell/g.Width)
+	}
+	return &object.Array{Elements: points}
+}
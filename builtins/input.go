@@ -0,0 +1,309 @@
+package builtins
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// InputEvent is a key going down or up. Tick counts the ticks of the
+// default world from when a replay starts, with 0 being the next tick.
+type InputEvent struct {
+	Tick int64
+	Key  string
+	Down bool
+}
+
+// Input maps keys to named actions and keeps the state of the keys as of
+// the current tick. Key events from the host, the script or a replay are
+// queued and take effect together at the start of the next tick of the
+// default world, so the state cannot change while systems are running.
+type Input struct {
+	mu       sync.Mutex
+	pending  []InputEvent
+	replay   []InputEvent
+	bindings map[string][]string
+
+	down     map[string]bool
+	was      map[string]bool
+	pressed  map[string]bool
+	released map[string]bool
+}
+
+func newInput() *Input {
+	return &Input{
+		bindings: map[string][]string{},
+		down:     map[string]bool{},
+		was:      map[string]bool{},
+		pressed:  map[string]bool{},
+		released: map[string]bool{},
+	}
+}
+
+// Press queues a key press for the next tick. It is safe to call from any
+// goroutine.
+func (in *Input) Press(key string) { in.queue(InputEvent{Key: key, Down: true}) }
+
+// Release queues a key release for the next tick. It is safe to call from
+// any goroutine.
+func (in *Input) Release(key string) { in.queue(InputEvent{Key: key}) }
+
+func (in *Input) queue(e InputEvent) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.pending = append(in.pending, e)
+}
+
+// Replay schedules recorded events relative to frame, the default world's
+// current frame, replacing any replay in progress.
+func (in *Input) Replay(frame int64, events []InputEvent) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.replay = make([]InputEvent, len(events))
+	for i, e := range events {
+		e.Tick += frame + 1
+		in.replay[i] = e
+	}
+	sort.SliceStable(in.replay, func(i, j int) bool { return in.replay[i].Tick < in.replay[j].Tick })
+}
+
+// update applies the events queued for frame. It is called at the start
+// of every tick of the default world. The events of each tick pass through
+// the journal, so a replayed run sees the keys of the recorded one.
+func (in *Input) update(ctx *object.CallContext, frame int64) *object.Error {
+	in.mu.Lock()
+	events := in.pending
+	in.pending = nil
+	due := 0
+	for due < len(in.replay) && in.replay[due].Tick <= frame {
+		due++
+	}
+	events = append(in.replay[:due:due], events...)
+	in.replay = in.replay[due:]
+	in.mu.Unlock()
+
+	events, err := decodeKeyEvents(journaled(ctx, "input", func() object.Object {
+		return encodeKeyEvents(events)
+	}))
+	if err != nil {
+		return err
+	}
+
+	in.was = copyKeys(in.down)
+	in.pressed = map[string]bool{}
+	in.released = map[string]bool{}
+	for _, e := range events {
+		if e.Down {
+			if !in.down[e.Key] {
+				in.pressed[e.Key] = true
+			}
+			in.down[e.Key] = true
+		} else if in.down[e.Key] {
+			in.released[e.Key] = true
+			delete(in.down, e.Key)
+		}
+	}
+	return nil
+}
+
+// encodeKeyEvents writes the events of one tick as "+key" for a press and
+// "-key" for a release.
+func encodeKeyEvents(events []InputEvent) *object.Array {
+	elements := make([]object.Object, len(events))
+	for i, e := range events {
+		sign := "-"
+		if e.Down {
+			sign = "+"
+		}
+		elements[i] = &object.String{Value: sign + e.Key}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func decodeKeyEvents(obj object.Object) ([]InputEvent, *object.Error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, err
+	}
+	arr, ok := obj.(*object.Array)
+	if !ok {
+		return nil, newError("journal returned %s for input events", obj.Type())
+	}
+	events := make([]InputEvent, len(arr.Elements))
+	for i, element := range arr.Elements {
+		s, ok := element.(*object.String)
+		if !ok || len(s.Value) < 2 || (s.Value[0] != '+' && s.Value[0] != '-') {
+			return nil, newError("invalid input event in journal: %s", element.Inspect())
+		}
+		events[i] = InputEvent{Key: s.Value[1:], Down: s.Value[0] == '+'}
+	}
+	return events, nil
+}
+
+func copyKeys(keys map[string]bool) map[string]bool {
+	copied := make(map[string]bool, len(keys))
+	for key, down := range keys {
+		copied[key] = down
+	}
+	return copied
+}
+
+func anyKey(keys []string, set map[string]bool) bool {
+	for _, key := range keys {
+		if set[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadInputEvents parses a recorded input stream: one event per line
+// written as "<tick> press <key>" or "<tick> release <key>". Blank lines
+// and lines starting with # are ignored.
+func ReadInputEvents(r io.Reader) ([]InputEvent, error) {
+	var events []InputEvent
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want \"<tick> press|release <key>\", got %q", line, text)
+		}
+		tick, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil || tick < 0 {
+			return nil, fmt.Errorf("line %d: invalid tick %q", line, fields[0])
+		}
+		var down bool
+		switch fields[1] {
+		case "press":
+			down = true
+		case "release":
+		default:
+			return nil, fmt.Errorf("line %d: unknown event %q", line, fields[1])
+		}
+		events = append(events, InputEvent{Tick: tick, Key: fields[2], Down: down})
+	}
+	return events, scanner.Err()
+}
+
+// WriteInputEvents writes events in the format ReadInputEvents reads.
+func WriteInputEvents(w io.Writer, events []InputEvent) error {
+	for _, e := range events {
+		kind := "release"
+		if e.Down {
+			kind = "press"
+		}
+		if _, err := fmt.Fprintf(w, "%d %s %s\n", e.Tick, kind, e.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputFields are the functions of the input module. input itself still
+// reads a line when called.
+func inputFields(in *Input, world *World) map[string]object.Object {
+	action := func(name string, test func(keys []string) bool) *object.Builtin {
+		return &object.Builtin{Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			values, err := stringArgs("input."+name, args, 1)
+			if err != nil {
+				return err
+			}
+			keys, ok := in.bindings[values[0]]
+			if !ok {
+				return newError("unknown input action %s", values[0])
+			}
+			return nativeBool(test(keys))
+		}}
+	}
+	key := func(name string, queue func(key string)) *object.Builtin {
+		return &object.Builtin{Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			values, err := stringArgs("input."+name, args, 1)
+			if err != nil {
+				return err
+			}
+			queue(values[0])
+			return NULL
+		}}
+	}
+	return map[string]object.Object{
+		"bind":   &object.Builtin{Fn: func(ctx *object.CallContext, args ...object.Object) object.Object { return inputBind(in, args) }},
+		"unbind": &object.Builtin{Fn: func(ctx *object.CallContext, args ...object.Object) object.Object { return inputUnbind(in, args) }},
+		"isDown": action("isDown", func(keys []string) bool { return anyKey(keys, in.down) }),
+		"justPressed": action("justPressed", func(keys []string) bool {
+			return !anyKey(keys, in.was) && (anyKey(keys, in.down) || anyKey(keys, in.pressed))
+		}),
+		"justReleased": action("justReleased", func(keys []string) bool {
+			return (anyKey(keys, in.was) || anyKey(keys, in.pressed)) && !anyKey(keys, in.down)
+		}),
+		"press":   key("press", in.Press),
+		"release": key("release", in.Release),
+		// replay feeds a recorded input stream from a file into the
+		// following ticks.
+		"replay": &object.Builtin{
+			Capability: object.FS_CAPABILITY,
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				values, err := pathArgs(ctx, "input.replay", args, 1)
+				if err != nil {
+					return err
+				}
+				data, err := readFile(ctx, values[0])
+				if err != nil {
+					return err
+				}
+				events, readErr := ReadInputEvents(strings.NewReader(data))
+				if readErr != nil {
+					return newError("invalid input stream: %s", readErr)
+				}
+				in.Replay(world.frame, events)
+				return NULL
+			},
+		},
+	}
+}
+
+// inputBind implements input.bind(action, keys), where keys is a key name
+// or an array of them. Binding an action again replaces its keys.
+func inputBind(in *Input, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	action, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `input.bind` must be STRING, got %s", args[0].Type())
+	}
+	var keys []string
+	switch arg := args[1].(type) {
+	case *object.String:
+		keys = []string{arg.Value}
+	case *object.Array:
+		for _, element := range arg.Elements {
+			key, ok := element.(*object.String)
+			if !ok {
+				return newError("keys for `input.bind` must be STRING, got %s", element.Type())
+			}
+			keys = append(keys, key.Value)
+		}
+	default:
+		return newError("second argument to `input.bind` must be STRING or ARRAY, got %s", args[1].Type())
+	}
+	in.bindings[action.Value] = keys
+	return NULL
+}
+
+func inputUnbind(in *Input, args []object.Object) object.Object {
+	values, err := stringArgs("input.unbind", args, 1)
+	if err != nil {
+		return err
+	}
+	delete(in.bindings, values[0])
+	return NULL
+}
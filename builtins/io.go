@@ -1,3 +1,93 @@
 package builtins
 
-// TODO: implement based on TS version of this api
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// eprint writes each argument on its own line to the error stream.
+func eprint(ctx *object.CallContext, args ...object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Fprintln(ctx.Err, arg.Inspect())
+	}
+	return NULL
+}
+
+// printf formats its arguments with Go's fmt verbs, e.g.
+// printf("%s has %d hp", name, hp). No newline is added.
+func printf(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+	layout, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `printf` must be STRING, got %s", args[0].Type())
+	}
+	values := make([]interface{}, len(args)-1)
+	for i, arg := range args[1:] {
+		values[i] = formatValue(arg)
+	}
+	fmt.Fprintf(ctx.Out, layout.Value, values...)
+	return NULL
+}
+
+// formatValue converts primitives to Go values so verbs such as %d and %.2f
+// work, and everything else to its inspected form.
+func formatValue(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	default:
+		return obj.Inspect()
+	}
+}
+
+// readLine reads one line from the input stream without its line ending.
+// It returns null once the input is exhausted.
+func readLine(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return readInputLine(ctx)
+}
+
+// input writes an optional prompt to the output stream and reads a line.
+func input(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+	if len(args) == 1 {
+		prompt, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument to `input` must be STRING, got %s", args[0].Type())
+		}
+		io.WriteString(ctx.Out, prompt.Value)
+	}
+	return readInputLine(ctx)
+}
+
+func readInputLine(ctx *object.CallContext) object.Object {
+	return journaled(ctx, "stdin", func() object.Object {
+		in := ctx.In()
+		if in == nil {
+			return NULL
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			if err == io.EOF {
+				return NULL
+			}
+			return newError("cannot read input: %s", err)
+		}
+		return &object.String{Value: strings.TrimRight(line, "\r\n")}
+	})
+}
@@ -0,0 +1,50 @@
+package builtins
+
+import (
+	"os"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// journaled returns the value of kind through the journal of the call, or
+// produce() when there is none.
+func journaled(ctx *object.CallContext, kind string, produce func() object.Object) object.Object {
+	if ctx == nil || ctx.Journal == nil {
+		return produce()
+	}
+	return ctx.Journal.Entry(kind, produce)
+}
+
+// readFile reads a whole file through the journal, so the contents are
+// recorded and replayed along with the rest of the run.
+func readFile(ctx *object.CallContext, path string) (string, *object.Error) {
+	data := journaled(ctx, "file", func() object.Object {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return newError("cannot read file: %s", err)
+		}
+		return &object.String{Value: string(data)}
+	})
+	switch data := data.(type) {
+	case *object.String:
+		return data.Value, nil
+	case *object.Error:
+		return "", data
+	}
+	return "", newError("journal returned %s for file %s", data.Type(), path)
+}
+
+// randomSeed returns a seed for a generator that was not given one.
+func randomSeed(ctx *object.CallContext) (int64, *object.Error) {
+	seed := journaled(ctx, "seed", func() object.Object {
+		return &object.Integer{Value: time.Now().UnixNano()}
+	})
+	switch seed := seed.(type) {
+	case *object.Integer:
+		return seed.Value, nil
+	case *object.Error:
+		return 0, seed
+	}
+	return 0, newError("journal returned %s for a random seed", seed.Type())
+}
@@ -0,0 +1,148 @@
+package builtins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+func jsonModule() *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "parse", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				values, err := stringArgs("json.parse", args, 1)
+				if err != nil {
+					return err
+				}
+				return ParseJSON(values[0])
+			},
+		}},
+		{Name: "stringify", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+				indent := ""
+				if len(args) == 2 {
+					switch arg := args[1].(type) {
+					case *object.Integer:
+						if arg.Value < 0 || arg.Value > maxJSONIndent {
+							return newError("indent for `json.stringify` must be between 0 and %d, got %d", maxJSONIndent, arg.Value)
+						}
+						indent = strings.Repeat(" ", int(arg.Value))
+					case *object.String:
+						indent = arg.Value
+					default:
+						return newError("indent for `json.stringify` must be INTEGER or STRING, got %s", args[1].Type())
+					}
+				}
+				return StringifyJSON(args[0], indent)
+			},
+		}},
+	})
+}
+
+// maxJSONIndent is the most spaces json.stringify indents by.
+const maxJSONIndent = 10
+
+// ParseJSON decodes a JSON document into objects. Numbers written without a
+// fraction or exponent become integers, and all others, such as 1.0 and
+// 1e3, become floats.
+func ParseJSON(input string) object.Object {
+	decoder := json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return newError("invalid JSON: %s", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return newError("invalid JSON: unexpected data after top-level value")
+	}
+	return util.ToObject(value)
+}
+
+// StringifyJSON encodes obj as JSON, pretty-printed when indent is not
+// empty. Hash keys are written in sorted order so output is stable.
+func StringifyJSON(obj object.Object, indent string) object.Object {
+	value, err := toJSONValue(obj, map[object.Object]bool{})
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(value); err != nil {
+		return newError("cannot encode JSON: %s", err)
+	}
+	return &object.String{Value: strings.TrimSuffix(out.String(), "\n")}
+}
+
+// toJSONValue converts obj into values encoding/json can marshal. Floats
+// are always written with a fractional part so they decode as floats again.
+// Hash keys must be strings, as any other key would be written as a string
+// and could collide with a string key of the same text.
+func toJSONValue(obj object.Object, visiting map[object.Object]bool) (interface{}, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return json.Number(strconv.FormatInt(obj.Value, 10)), nil
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return nil, newError("cannot encode %s as JSON", obj.Inspect())
+		}
+		str := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(str, ".eE") {
+			str += ".0"
+		}
+		return json.Number(str), nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		if visiting[obj] {
+			return nil, newError("cannot encode cyclic ARRAY as JSON")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+		values := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := toJSONValue(element, visiting)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case *object.Hash:
+		if visiting[obj] {
+			return nil, newError("cannot encode cyclic HASH as JSON")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+		values := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return nil, newError("cannot encode HASH with %s key %s as JSON", pair.Key.Type(), pair.Key.Inspect())
+			}
+			value, err := toJSONValue(pair.Value, visiting)
+			if err != nil {
+				return nil, err
+			}
+			values[key.Value] = value
+		}
+		return values, nil
+	default:
+		return nil, newError("cannot encode %s as JSON", obj.Type())
+	}
+}
@@ -0,0 +1,352 @@
+package builtins
+
+import (
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+// linalgFunctions are the vector, matrix and quaternion members of Math.
+func linalgFunctions() []util.StringObjectPair {
+	return []util.StringObjectPair{
+		{Name: "Vec2", Obj: &object.Builtin{Fn: vectorConstructor("Vec2", 2)}},
+		{Name: "Vec3", Obj: &object.Builtin{Fn: vectorConstructor("Vec3", 3)}},
+		{Name: "Vec4", Obj: &object.Builtin{Fn: vectorConstructor("Vec4", 4)}},
+		{Name: "Mat3", Obj: &object.Builtin{Fn: matrixConstructor("Mat3", 3)}},
+		{Name: "Mat4", Obj: &object.Builtin{Fn: matrixConstructor("Mat4", 4)}},
+		{Name: "Quat", Obj: &object.Builtin{Fn: quatConstructor}},
+		{Name: "axisAngle", Obj: &object.Builtin{Fn: axisAngle}},
+		{Name: "dot", Obj: &object.Builtin{Fn: dot}},
+		{Name: "cross", Obj: &object.Builtin{Fn: cross}},
+		{Name: "length", Obj: &object.Builtin{Fn: length}},
+		{Name: "normalize", Obj: &object.Builtin{Fn: normalize}},
+		{Name: "lerp", Obj: &object.Builtin{Fn: lerp}},
+		{Name: "slerp", Obj: &object.Builtin{Fn: slerp}},
+		{Name: "transpose", Obj: &object.Builtin{Fn: transpose}},
+		{Name: "lookAt", Obj: &object.Builtin{Fn: lookAt}},
+		{Name: "perspective", Obj: &object.Builtin{Fn: perspective}},
+	}
+}
+
+// numberArgs converts args to floats, reporting the first that is not an
+// INTEGER or FLOAT.
+func numberArgs(name string, args []object.Object) ([]float64, *object.Error) {
+	values := make([]float64, len(args))
+	for i, arg := range args {
+		value, ok := numberArg(arg)
+		if !ok {
+			return nil, newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// vectorConstructor returns Math.Vec2, Vec3 or Vec4, which take all of the
+// components or none for the zero vector.
+func vectorConstructor(name string, size int) object.BuiltinFunction {
+	return func(ctx *object.CallContext, args ...object.Object) object.Object {
+		if len(args) == 0 {
+			return &object.Vector{Values: make([]float64, size)}
+		}
+		if len(args) != size {
+			return newError("wrong number of arguments. got=%d, want=0 or %d", len(args), size)
+		}
+		values, err := numberArgs(name, args)
+		if err != nil {
+			return err
+		}
+		return &object.Vector{Values: values}
+	}
+}
+
+// matrixConstructor returns Math.Mat3 or Mat4, which make the identity
+// matrix, or a matrix from its elements in column-major order given as
+// arguments or as an array.
+func matrixConstructor(name string, size int) object.BuiltinFunction {
+	return func(ctx *object.CallContext, args ...object.Object) object.Object {
+		if len(args) == 0 {
+			return object.IdentityMatrix(size)
+		}
+		if len(args) == 1 {
+			if arr, ok := args[0].(*object.Array); ok {
+				args = arr.Elements
+			}
+		}
+		if len(args) != size*size {
+			return newError("`%s` takes 0 or %d elements, got %d", name, size*size, len(args))
+		}
+		values, err := numberArgs(name, args)
+		if err != nil {
+			return err
+		}
+		return &object.Matrix{Size: size, Values: values}
+	}
+}
+
+// quatConstructor implements Math.Quat(x, y, z, w), or Math.Quat() for the
+// identity rotation.
+func quatConstructor(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return &object.Quat{W: 1}
+	}
+	if len(args) != 4 {
+		return newError("wrong number of arguments. got=%d, want=0 or 4", len(args))
+	}
+	values, err := numberArgs("Quat", args)
+	if err != nil {
+		return err
+	}
+	return &object.Quat{X: values[0], Y: values[1], Z: values[2], W: values[3]}
+}
+
+func vectorArg(name string, obj object.Object, size int) (*object.Vector, *object.Error) {
+	v, ok := obj.(*object.Vector)
+	if !ok || (size != 0 && len(v.Values) != size) {
+		want := "VEC2, VEC3 or VEC4"
+		if size != 0 {
+			want = string((&object.Vector{Values: make([]float64, size)}).Type())
+		}
+		return nil, newError("argument to `%s` must be %s, got %s", name, want, obj.Type())
+	}
+	return v, nil
+}
+
+// axisAngle implements Math.axisAngle(axis, radians).
+func axisAngle(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	axis, err := vectorArg("axisAngle", args[0], 3)
+	if err != nil {
+		return err
+	}
+	angle, ok := numberArg(args[1])
+	if !ok {
+		return newError("argument to `axisAngle` must be INTEGER or FLOAT, got %s", args[1].Type())
+	}
+	return object.AxisAngle(axis, angle)
+}
+
+func dot(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	switch a := args[0].(type) {
+	case *object.Vector:
+		if b, ok := args[1].(*object.Vector); ok && len(a.Values) == len(b.Values) {
+			return &object.Float{Value: a.Dot(b)}
+		}
+	case *object.Quat:
+		if b, ok := args[1].(*object.Quat); ok {
+			return &object.Float{Value: a.Dot(b)}
+		}
+	}
+	return newError("arguments to `dot` must be vectors of the same size or QUATs, got %s and %s", args[0].Type(), args[1].Type())
+}
+
+func cross(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	a, err := vectorArg("cross", args[0], 3)
+	if err != nil {
+		return err
+	}
+	b, err := vectorArg("cross", args[1], 3)
+	if err != nil {
+		return err
+	}
+	return a.Cross(b)
+}
+
+func length(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch v := args[0].(type) {
+	case *object.Vector:
+		return &object.Float{Value: v.Length()}
+	case *object.Quat:
+		return &object.Float{Value: v.Length()}
+	}
+	return newError("argument to `length` must be a vector or QUAT, got %s", args[0].Type())
+}
+
+func normalize(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch v := args[0].(type) {
+	case *object.Vector:
+		return v.Normalize()
+	case *object.Quat:
+		return v.Normalize()
+	}
+	return newError("argument to `normalize` must be a vector or QUAT, got %s", args[0].Type())
+}
+
+// lerp interpolates numbers, vectors of the same size or quaternions:
+// lerp(a, b, t) is a at t = 0 and b at t = 1.
+func lerp(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+	t, ok := numberArg(args[2])
+	if !ok {
+		return newError("third argument to `lerp` must be INTEGER or FLOAT, got %s", args[2].Type())
+	}
+	switch a := args[0].(type) {
+	case *object.Vector:
+		if b, ok := args[1].(*object.Vector); ok && len(a.Values) == len(b.Values) {
+			return a.Lerp(b, t)
+		}
+	case *object.Quat:
+		if b, ok := args[1].(*object.Quat); ok {
+			return a.Add(b.Sub(a).Scale(t))
+		}
+	default:
+		from, okFrom := numberArg(args[0])
+		to, okTo := numberArg(args[1])
+		if okFrom && okTo {
+			return &object.Float{Value: from + (to-from)*t}
+		}
+	}
+	return newError("arguments to `lerp` must be two numbers, vectors of the same size or QUATs, got %s and %s", args[0].Type(), args[1].Type())
+}
+
+func slerp(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+	a, okA := args[0].(*object.Quat)
+	b, okB := args[1].(*object.Quat)
+	if !okA || !okB {
+		return newError("arguments to `slerp` must be QUAT, got %s and %s", args[0].Type(), args[1].Type())
+	}
+	t, ok := numberArg(args[2])
+	if !ok {
+		return newError("third argument to `slerp` must be INTEGER or FLOAT, got %s", args[2].Type())
+	}
+	return a.Slerp(b, t)
+}
+
+func transpose(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	m, ok := args[0].(*object.Matrix)
+	if !ok {
+		return newError("argument to `transpose` must be MAT3 or MAT4, got %s", args[0].Type())
+	}
+	return m.Transpose()
+}
+
+// lookAt implements Math.lookAt(eye, target, up), which returns a view
+// matrix.
+func lookAt(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+	vectors := make([]*object.Vector, 3)
+	for i, arg := range args {
+		v, err := vectorArg("lookAt", arg, 3)
+		if err != nil {
+			return err
+		}
+		vectors[i] = v
+	}
+	return object.LookAt(vectors[0], vectors[1], vectors[2])
+}
+
+// perspective implements Math.perspective(fovy, aspect, near, far), with
+// fovy in radians.
+func perspective(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 4 {
+		return newError("wrong number of arguments. got=%d, want=4", len(args))
+	}
+	values, err := numberArgs("perspective", args)
+	if err != nil {
+		return err
+	}
+	if values[1] == 0 || values[2] == values[3] {
+		return newError("`perspective` needs a non-zero aspect and near != far")
+	}
+	return object.Perspective(values[0], values[1], values[2], values[3])
+}
+
+func linalgToArray(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	var values []float64
+	switch receiver := receiver.(type) {
+	case *object.Vector:
+		values = receiver.Values
+	case *object.Matrix:
+		values = receiver.Values
+	case *object.Quat:
+		values = []float64{receiver.X, receiver.Y, receiver.Z, receiver.W}
+	}
+	elements := make([]object.Object, len(values))
+	for i, value := range values {
+		elements[i] = &object.Float{Value: value}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// matrixGet implements m.get(row, col).
+func matrixGet(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	m := receiver.(*object.Matrix)
+	row, okRow := args[0].(*object.Integer)
+	col, okCol := args[1].(*object.Integer)
+	if !okRow || !okCol {
+		return newError("arguments to `get` must be INTEGER, got %s and %s", args[0].Type(), args[1].Type())
+	}
+	if row.Value < 0 || row.Value >= int64(m.Size) || col.Value < 0 || col.Value >= int64(m.Size) {
+		return newError("index out of range: (%d, %d) in %s", row.Value, col.Value, m.Type())
+	}
+	return &object.Float{Value: m.At(int(row.Value), int(col.Value))}
+}
+
+func quatToMat4(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return receiver.(*object.Quat).Matrix()
+}
+
+var vectorMethods = map[string]Method{
+	"dot":       withReceiver(&object.Builtin{Fn: dot}),
+	"length":    withReceiver(&object.Builtin{Fn: length}),
+	"normalize": withReceiver(&object.Builtin{Fn: normalize}),
+	"lerp":      withReceiver(&object.Builtin{Fn: lerp}),
+	"toArray":   linalgToArray,
+}
+
+func init() {
+	for _, objectType := range []object.ObjectType{object.VEC2_OBJ, object.VEC3_OBJ, object.VEC4_OBJ} {
+		for name, method := range vectorMethods {
+			addMethod(methods, objectType, name, method)
+		}
+	}
+	addMethod(methods, object.VEC3_OBJ, "cross", withReceiver(&object.Builtin{Fn: cross}))
+	for _, objectType := range []object.ObjectType{object.MAT3_OBJ, object.MAT4_OBJ} {
+		addMethod(methods, objectType, "transpose", withReceiver(&object.Builtin{Fn: transpose}))
+		addMethod(methods, objectType, "get", matrixGet)
+		addMethod(methods, objectType, "toArray", linalgToArray)
+	}
+	for name, method := range map[string]Method{
+		"dot":       withReceiver(&object.Builtin{Fn: dot}),
+		"length":    withReceiver(&object.Builtin{Fn: length}),
+		"normalize": withReceiver(&object.Builtin{Fn: normalize}),
+		"lerp":      withReceiver(&object.Builtin{Fn: lerp}),
+		"slerp":     withReceiver(&object.Builtin{Fn: slerp}),
+		"toMat4":    quatToMat4,
+		"toArray":   linalgToArray,
+	} {
+		addMethod(methods, object.QUAT_OBJ, name, method)
+	}
+}
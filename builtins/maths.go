@@ -2,117 +2,197 @@ package builtins
 
 import (
 	"math"
+	"strconv"
 
 	"github.com/SpaceHexagon/ecs/object"
 	"github.com/SpaceHexagon/ecs/util"
 )
 
+// maths returns the Math module. Functions accept INTEGER and FLOAT
+// arguments alike. Rounding functions return INTEGER; abs, sign, min, max
+// and clamp keep integers as integers; everything else returns FLOAT.
 func maths() *object.Hash {
-	return util.MakeBuiltinInterface([]util.StringObjectPair{
-		util.StringObjectPair{Name: "PI", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				return &object.Float{Value: math.Pi}
-			},
-		}},
-		util.StringObjectPair{Name: "sin", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ {
-					return newError("argument to `sin` must be FLOAT_OBJ, got %s", args[0].Type())
-				}
+	rng := &sharedRandom{}
+	return util.MakeBuiltinInterface(append([]util.StringObjectPair{
+		{Name: "PI", Obj: &object.Float{Value: math.Pi}},
+		{Name: "E", Obj: &object.Float{Value: math.E}},
+		{Name: "sin", Obj: floatFunction("sin", math.Sin)},
+		{Name: "cos", Obj: floatFunction("cos", math.Cos)},
+		{Name: "tan", Obj: floatFunction("tan", math.Tan)},
+		{Name: "asin", Obj: floatFunction("asin", math.Asin)},
+		{Name: "acos", Obj: floatFunction("acos", math.Acos)},
+		{Name: "atan", Obj: floatFunction("atan", math.Atan)},
+		{Name: "atan2", Obj: floatFunction2("atan2", math.Atan2)},
+		{Name: "sqrt", Obj: floatFunction("sqrt", math.Sqrt)},
+		{Name: "exp", Obj: floatFunction("exp", math.Exp)},
+		{Name: "log", Obj: floatFunction("log", math.Log)},
+		{Name: "pow", Obj: floatFunction2("pow", math.Pow)},
+		{Name: "hypot", Obj: floatFunction2("hypot", math.Hypot)},
+		{Name: "fract", Obj: floatFunction("fract", func(x float64) float64 { return x - math.Floor(x) })},
+		{Name: "floor", Obj: roundingFunction("floor", math.Floor)},
+		{Name: "ceil", Obj: roundingFunction("ceil", math.Ceil)},
+		{Name: "round", Obj: roundingFunction("round", math.Round)},
+		{Name: "abs", Obj: &object.Builtin{Fn: abs}},
+		{Name: "sign", Obj: &object.Builtin{Fn: sign}},
+		{Name: "min", Obj: &object.Builtin{Fn: extremum("min", func(a, b float64) bool { return a < b })}},
+		{Name: "max", Obj: &object.Builtin{Fn: extremum("max", func(a, b float64) bool { return a > b })}},
+		{Name: "clamp", Obj: &object.Builtin{Fn: clamp}},
+		{Name: "random", Obj: &object.Builtin{Fn: withRandom(rng, randomFloat)}},
+		{Name: "randomInt", Obj: &object.Builtin{Fn: withRandom(rng, randomInt)}},
+		{Name: "Random", Obj: &object.Builtin{Fn: newRandomObject}},
+	}, linalgFunctions()...))
+}
+
+// floatFunction wraps a one-argument float function as a builtin.
+func floatFunction(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			values, err := numberArgs(name, args)
+			if err != nil {
+				return err
+			}
+			return &object.Float{Value: fn(values[0])}
+		},
+	}
+}
+
+// floatFunction2 wraps a two-argument float function as a builtin.
+func floatFunction2(name string, fn func(float64, float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			values, err := numberArgs(name, args)
+			if err != nil {
+				return err
+			}
+			return &object.Float{Value: fn(values[0], values[1])}
+		},
+	}
+}
+
+// roundedInteger converts x, already rounded by the builtin name, to an
+// INTEGER. NaN, the infinities and values outside the range of INTEGER
+// are errors.
+func roundedInteger(name string, x float64) object.Object {
+	if !(x >= math.MinInt64 && x < math.MaxInt64) {
+		return newError("result of `%s` does not fit in an INTEGER: %s", name, strconv.FormatFloat(x, 'g', -1, 64))
+	}
+	return &object.Integer{Value: int64(x)}
+}
+
+// roundingFunction wraps fn as a builtin that returns an INTEGER.
+func roundingFunction(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				return roundedInteger(name, fn(arg.Value))
+			}
+			return newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, args[0].Type())
+		},
+	}
+}
+
+func abs(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		if arg.Value < 0 {
+			return &object.Integer{Value: -arg.Value}
+		}
+		return arg
+	case *object.Float:
+		return &object.Float{Value: math.Abs(arg.Value)}
+	}
+	return newError("argument to `abs` must be INTEGER or FLOAT, got %s", args[0].Type())
+}
+
+func sign(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		switch {
+		case arg.Value > 0:
+			return &object.Integer{Value: 1}
+		case arg.Value < 0:
+			return &object.Integer{Value: -1}
+		}
+		return &object.Integer{Value: 0}
+	case *object.Float:
+		switch {
+		case arg.Value > 0:
+			return &object.Float{Value: 1}
+		case arg.Value < 0:
+			return &object.Float{Value: -1}
+		}
+		return arg
+	}
+	return newError("argument to `sign` must be INTEGER or FLOAT, got %s", args[0].Type())
+}
+
+// extremum returns min or max. They take numbers or a single array of
+// numbers and return the winning argument itself, so integers stay
+// integers.
+func extremum(name string, better func(a, b float64) bool) object.BuiltinFunction {
+	return func(ctx *object.CallContext, args ...object.Object) object.Object {
+		if len(args) == 1 {
+			if arr, ok := args[0].(*object.Array); ok {
+				args = arr.Elements
+			}
+		}
+		if len(args) == 0 {
+			return newError("`%s` needs at least one number", name)
+		}
+		values, err := numberArgs(name, args)
+		if err != nil {
+			return err
+		}
+		best := 0
+		for i, value := range values {
+			if better(value, values[best]) {
+				best = i
+			}
+		}
+		return args[best]
+	}
+}
 
-				return &object.Float{Value: math.Sin(args[0].(*object.Float).Value)}
-			},
-		}},
-		util.StringObjectPair{Name: "cos", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ {
-					return newError("argument to `cos` must be FLOAT, got %s", args[0].Type())
-				}
-				return &object.Float{Value: math.Cos(args[0].(*object.Float).Value)}
-			},
-		}},
-		util.StringObjectPair{Name: "tan", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ {
-					return newError("argument to `cos` must be FLOAT, got %s", args[0].Type())
-				}
-				return &object.Float{Value: math.Tan(args[0].(*object.Float).Value)}
-			},
-		}},
-		util.StringObjectPair{Name: "atan2", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 2 {
-					return newError("wrong number of arguments. got=%d, want=2", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ || args[1].Type() != object.FLOAT_OBJ {
-					return newError("argument to `atan2` must be FLOAT, got %s %s", args[0].Type(), args[1].Type())
-				}
-				return &object.Float{Value: math.Atan2(args[0].(*object.Float).Value, args[1].(*object.Float).Value)}
-			},
-		}},
-		util.StringObjectPair{Name: "sqrt", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ && args[0].Type() != object.INTEGER_OBJ {
-					return newError("argument to `cos` must be INTEGER or FLOAT, got %s", args[0].Type())
-				}
-				return &object.Float{Value: math.Sqrt(float64(args[0].(*object.Integer).Value))}
-			},
-		}},
-		util.StringObjectPair{Name: "abs", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ && args[0].Type() != object.INTEGER_OBJ {
-					return newError("argument to `abs` must be INTEGER, got %s", args[0].Type())
-				}
-				return &object.Float{Value: math.Abs(float64(args[0].(*object.Integer).Value))}
-			},
-		}},
-		util.StringObjectPair{Name: "floor", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ {
-					return newError("argument to `abs` must be FLOAT_OBJ, got %s", args[0].Type())
-				}
-				return &object.Integer{Value: int64(math.Floor(args[0].(*object.Float).Value))}
-			},
-		}},
-		util.StringObjectPair{Name: "ceil", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ {
-					return newError("argument to `abs` must be FLOAT_OBJ, got %s", args[0].Type())
-				}
-				return &object.Float{Value: math.Ceil(args[0].(*object.Float).Value)}
-			},
-		}},
-		util.StringObjectPair{Name: "fract", Obj: &object.Builtin{
-			Fn: func(context interface{}, scope interface{}, args ...object.Object) object.Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != object.FLOAT_OBJ {
-					return newError("argument to `abs` must be FLOAT_OBJ, got %s", args[0].Type())
-				}
-				return &object.Float{Value: args[0].(*object.Float).Value - math.Floor(args[0].(*object.Float).Value)}
-			},
-		}},
-	})
+func clamp(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+	values, err := numberArgs("clamp", args)
+	if err != nil {
+		return err
+	}
+	if values[1] > values[2] {
+		return newError("`clamp` minimum %s is greater than maximum %s", args[1].Inspect(), args[2].Inspect())
+	}
+	x, xInt := args[0].(*object.Integer)
+	lo, loInt := args[1].(*object.Integer)
+	hi, hiInt := args[2].(*object.Integer)
+	if xInt && loInt && hiInt {
+		switch {
+		case x.Value < lo.Value:
+			return lo
+		case x.Value > hi.Value:
+			return hi
+		}
+		return x
+	}
+	return &object.Float{Value: math.Max(values[1], math.Min(values[0], values[2]))}
 }
@@ -0,0 +1,381 @@
+package builtins
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// Method is a builtin that operates on the value it was accessed from, e.g.
+// the array in arr.push(x).
+type Method = object.Method
+
+// methods are the default method tables, used by runtimes without tables
+// of their own. They are not changed after package initialisation.
+var methods = map[object.ObjectType]map[string]Method{
+	object.ARRAY_OBJ: {
+		"len":      arrayLen,
+		"push":     arrayPush,
+		"pop":      arrayPop,
+		"insert":   arrayInsert,
+		"removeAt": arrayRemoveAt,
+		"first":    withReceiver(ECSBuiltins["first"]),
+		"last":     withReceiver(ECSBuiltins["last"]),
+		"rest":     withReceiver(ECSBuiltins["rest"]),
+		"join":     withReceiver(ECSBuiltins["join"]),
+		"indexOf":  arrayIndexOf,
+		"contains": arrayContains,
+		"reverse":  arrayReverse,
+		"copy":     arrayCopy,
+	},
+	object.STRING_OBJ: {
+		"len":        withReceiver(ECSBuiltins["len"]),
+		"split":      withReceiver(ECSBuiltins["split"]),
+		"replace":    withReceiver(ECSBuiltins["replace"]),
+		"trim":       withReceiver(ECSBuiltins["trim"]),
+		"upper":      withReceiver(ECSBuiltins["upper"]),
+		"lower":      withReceiver(ECSBuiltins["lower"]),
+		"startsWith": withReceiver(ECSBuiltins["startsWith"]),
+		"endsWith":   withReceiver(ECSBuiltins["endsWith"]),
+		"contains":   withReceiver(ECSBuiltins["contains"]),
+		"indexOf":    withReceiver(ECSBuiltins["indexOf"]),
+		"repeat":     withReceiver(ECSBuiltins["repeat"]),
+		"format":     withReceiver(ECSBuiltins["format"]),
+	},
+	object.INTEGER_OBJ: {
+		"toFixed":  numberToFixed,
+		"toString": numberToString,
+		"toFloat":  numberToFloat,
+		"toInt":    numberToInt,
+	},
+	object.FLOAT_OBJ: {
+		"toFixed":  numberToFixed,
+		"toString": numberToString,
+		"toFloat":  numberToFloat,
+		"toInt":    numberToInt,
+		"floor":    floatRounding("floor", math.Floor),
+		"ceil":     floatRounding("ceil", math.Ceil),
+		"round":    floatRounding("round", math.Round),
+	},
+	object.FUNCTION_OBJ: {
+		"call":  functionCall,
+		"apply": functionApply,
+		"arity": functionArity,
+	},
+	object.TASK_OBJ: {
+		"await": withReceiver(&object.Builtin{Fn: await}),
+		"done":  taskDone,
+	},
+	object.COROUTINE_OBJ: {
+		"resume": coroutineResume,
+		"done":   coroutineDone,
+		"cancel": coroutineCancel,
+	},
+	WORLD_OBJ:  worldMethods,
+	ENTITY_OBJ: entityMethods,
+	RANDOM_OBJ: randomMethods,
+	GRID_OBJ:   gridMethods,
+	object.CHANNEL_OBJ: {
+		"send":  withReceiver(&object.Builtin{Fn: send}),
+		"recv":  withReceiver(&object.Builtin{Fn: recv}),
+		"close": withReceiver(&object.Builtin{Fn: closeChannel}),
+		"len":   channelLen,
+	},
+}
+
+// Methods returns a copy of the default method tables, so each runtime can
+// be given tables of its own.
+func Methods() map[object.ObjectType]map[string]Method {
+	copied := make(map[object.ObjectType]map[string]Method, len(methods))
+	for objectType, table := range methods {
+		copied[objectType] = make(map[string]Method, len(table))
+		for name, method := range table {
+			copied[objectType][name] = method
+		}
+	}
+	return copied
+}
+
+// RegisterMethod adds a method to the method table of the given type in
+// runtime, so hosts can extend the built-in types. A runtime still using
+// the default tables is given a copy of them first, so other runtimes are
+// not affected.
+func RegisterMethod(runtime *object.Runtime, objectType object.ObjectType, name string, method Method) {
+	if runtime.Methods == nil {
+		runtime.Methods = Methods()
+	}
+	addMethod(runtime.Methods, objectType, name, method)
+}
+
+func addMethod(tables map[object.ObjectType]map[string]Method, objectType object.ObjectType, name string, method Method) {
+	table, ok := tables[objectType]
+	if !ok {
+		table = map[string]Method{}
+		tables[objectType] = table
+	}
+	table[name] = method
+}
+
+// LookupMethod resolves receiver.name in the method tables of runtime to a
+// builtin bound to receiver.
+func LookupMethod(runtime *object.Runtime, receiver object.Object, name string) (*object.Builtin, bool) {
+	tables := runtime.Methods
+	if tables == nil {
+		tables = methods
+	}
+	method, ok := tables[receiver.Type()][name]
+	if !ok {
+		return nil, false
+	}
+	return &object.Builtin{
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			return method(ctx, receiver, args...)
+		},
+	}, true
+}
+
+// withReceiver turns a builtin whose first argument is the receiver into a
+// method, so len(s) and s.len() share one implementation.
+func withReceiver(builtin object.Object) Method {
+	fn := builtin.(*object.Builtin).Fn
+	return func(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+		return fn(ctx, append([]object.Object{receiver}, args...)...)
+	}
+}
+
+func arrayLen(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Integer{Value: int64(len(receiver.(*object.Array).Elements))}
+}
+
+// arrayPush appends its arguments in place and returns the new length.
+func arrayPush(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	arr := receiver.(*object.Array)
+	arr.Elements = append(arr.Elements, args...)
+	return &object.Integer{Value: int64(len(arr.Elements))}
+}
+
+// arrayPop removes and returns the last element, or null if the array is
+// empty.
+func arrayPop(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	arr := receiver.(*object.Array)
+	length := len(arr.Elements)
+	if length == 0 {
+		return NULL
+	}
+	last := arr.Elements[length-1]
+	arr.Elements[length-1] = nil
+	arr.Elements = arr.Elements[:length-1]
+	return last
+}
+
+func arrayInsert(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	index, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("first argument to `insert` must be INTEGER, got %s", args[0].Type())
+	}
+	arr := receiver.(*object.Array)
+	idx := index.Value
+	if idx < 0 || idx > int64(len(arr.Elements)) {
+		return newError("insert index out of range: %d with length %d", idx, len(arr.Elements))
+	}
+	arr.Elements = append(arr.Elements, nil)
+	copy(arr.Elements[idx+1:], arr.Elements[idx:])
+	arr.Elements[idx] = args[1]
+	return &object.Integer{Value: int64(len(arr.Elements))}
+}
+
+// arrayRemoveAt removes the element at the given index and returns it.
+func arrayRemoveAt(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	index, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to `removeAt` must be INTEGER, got %s", args[0].Type())
+	}
+	arr := receiver.(*object.Array)
+	idx := index.Value
+	length := int64(len(arr.Elements))
+	if idx < 0 || idx >= length {
+		return newError("removeAt index out of range: %d with length %d", idx, length)
+	}
+	removed := arr.Elements[idx]
+	copy(arr.Elements[idx:], arr.Elements[idx+1:])
+	arr.Elements[length-1] = nil
+	arr.Elements = arr.Elements[:length-1]
+	return removed
+}
+
+func arrayIndexOf(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	for i, element := range receiver.(*object.Array).Elements {
+		if objectsEqual(element, args[0]) {
+			return &object.Integer{Value: int64(i)}
+		}
+	}
+	return &object.Integer{Value: -1}
+}
+
+func arrayContains(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	idx := arrayIndexOf(ctx, receiver, args...)
+	if result, ok := idx.(*object.Integer); ok {
+		return nativeBool(result.Value >= 0)
+	}
+	return idx
+}
+
+// arrayReverse reverses the array in place and returns it.
+func arrayReverse(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	elements := receiver.(*object.Array).Elements
+	for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+		elements[i], elements[j] = elements[j], elements[i]
+	}
+	return receiver
+}
+
+func arrayCopy(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	elements := receiver.(*object.Array).Elements
+	newElements := make([]object.Object, len(elements))
+	copy(newElements, elements)
+	return &object.Array{Elements: newElements}
+}
+
+// objectsEqual compares primitives by value and everything else by identity,
+// matching the semantics of the == operator.
+func objectsEqual(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer:
+		other, ok := b.(*object.Integer)
+		return ok && a.Value == other.Value
+	case *object.Float:
+		other, ok := b.(*object.Float)
+		return ok && a.Value == other.Value
+	case *object.String:
+		other, ok := b.(*object.String)
+		return ok && a.Value == other.Value
+	case *object.Boolean:
+		other, ok := b.(*object.Boolean)
+		return ok && a.Value == other.Value
+	case *object.Null:
+		return b.Type() == object.NULL_OBJ
+	default:
+		return a == b
+	}
+}
+
+func toFloat64(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+// maxFixedDigits is the most digits toFixed writes after the point.
+const maxFixedDigits = 100
+
+// numberToFixed formats a number with 0 to maxFixedDigits digits after the
+// point.
+func numberToFixed(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	digits, ok := args[0].(*object.Integer)
+	if !ok || digits.Value < 0 || digits.Value > maxFixedDigits {
+		return newError("argument to `toFixed` must be an INTEGER from 0 to %d, got %s", maxFixedDigits, args[0].Inspect())
+	}
+	return &object.String{Value: strconv.FormatFloat(toFloat64(receiver), 'f', int(digits.Value), 64)}
+}
+
+func numberToString(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	if i, ok := receiver.(*object.Integer); ok {
+		return &object.String{Value: strconv.FormatInt(i.Value, 10)}
+	}
+	return &object.String{Value: strconv.FormatFloat(toFloat64(receiver), 'g', -1, 64)}
+}
+
+func numberToFloat(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Float{Value: toFloat64(receiver)}
+}
+
+func numberToInt(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	if i, ok := receiver.(*object.Integer); ok {
+		return i
+	}
+	return roundedInteger("toInt", math.Trunc(toFloat64(receiver)))
+}
+
+func floatRounding(name string, round func(float64) float64) Method {
+	return func(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments to `%s`. got=%d, want=0", name, len(args))
+		}
+		return roundedInteger(name, round(receiver.(*object.Float).Value))
+	}
+}
+
+func functionCall(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	return ctx.Call(receiver, args...)
+}
+
+func functionApply(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `apply` must be ARRAY, got %s", args[0].Type())
+	}
+	return ctx.Call(receiver, arr.Elements...)
+}
+
+func functionArity(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Integer{Value: int64(len(receiver.(*object.Function).Parameters))}
+}
+
+func taskDone(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return nativeBool(receiver.(*object.Task).Result() != nil)
+}
+
+// channelLen returns the number of values buffered in the channel.
+func channelLen(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Integer{Value: int64(len(receiver.(*object.Channel).C))}
+}
@@ -0,0 +1,705 @@
+package builtins
+
+import (
+	"math"
+	"sort"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+// RIGIDBODY_COMPONENT and COLLIDER_COMPONENT are the built-in components
+// the physics step works on, together with Transform. Entities with a
+// RigidBody move by their velocity every tick; entities with a Collider
+// collide. A Collider without a RigidBody, or with one marked static, is
+// an immovable obstacle.
+const (
+	RIGIDBODY_COMPONENT = "RigidBody"
+	COLLIDER_COMPONENT  = "Collider"
+)
+
+func rigidBodyDefaults() *object.Hash {
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for _, field := range []struct {
+		name  string
+		value float64
+	}{
+		{"vx", 0}, {"vy", 0}, {"mass", 1}, {"restitution", 0}, {"friction", 0}, {"gravityScale", 1},
+	} {
+		setField(hash, field.name, &object.Float{Value: field.value})
+	}
+	setField(hash, "static", FALSE)
+	return hash
+}
+
+func colliderDefaults() *object.Hash {
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	setField(hash, "shape", &object.String{Value: "box"})
+	for _, field := range []struct {
+		name  string
+		value float64
+	}{
+		{"width", 1}, {"height", 1}, {"radius", 0.5}, {"offsetX", 0}, {"offsetY", 0},
+	} {
+		setField(hash, field.name, &object.Float{Value: field.value})
+	}
+	setField(hash, "trigger", FALSE)
+	return hash
+}
+
+// physicsSettings are the per-world options set through the physics
+// module. A cellSize of 0 sizes the spatial hash from the colliders.
+type physicsSettings struct {
+	gravityX, gravityY float64
+	cellSize           float64
+}
+
+// body is an entity's physics state for the duration of one step or query.
+// Positions are the entity's Transform x and y, so bodies are expected to
+// be root entities; rotation and scale are ignored.
+type body struct {
+	entity    *Entity
+	transform *object.Hash
+	rigid     *object.Hash
+	x, y      float64
+	vx, vy    float64
+	invMass   float64
+	gravity   float64
+
+	restitution, friction float64
+
+	collider         bool
+	circle           bool
+	trigger          bool
+	offsetX, offsetY float64
+	width, height    float64
+	radius           float64
+}
+
+func (b *body) dynamic() bool { return b.invMass > 0 }
+
+func (b *body) center() (float64, float64) {
+	return b.x + b.offsetX, b.y + b.offsetY
+}
+
+func (b *body) halfExtents() (float64, float64) {
+	if b.circle {
+		return b.radius, b.radius
+	}
+	return b.width / 2, b.height / 2
+}
+
+func (b *body) bounds() (minX, minY, maxX, maxY float64) {
+	cx, cy := b.center()
+	hw, hh := b.halfExtents()
+	return cx - hw, cy - hh, cx + hw, cy + hh
+}
+
+// physicsBodies returns the bodies of the world's entities in creation
+// order. Entities need a Transform and a RigidBody or Collider.
+func (w *World) physicsBodies() ([]*body, *object.Error) {
+	var bodies []*body
+	for _, entity := range w.entities {
+		transform := entity.Component(TRANSFORM_COMPONENT)
+		rigid := entity.Component(RIGIDBODY_COMPONENT)
+		collider := entity.Component(COLLIDER_COMPONENT)
+		if transform == nil || (rigid == nil && collider == nil) {
+			continue
+		}
+		b := &body{entity: entity, transform: transform, rigid: rigid}
+		reader := fieldReader{entity: entity}
+		reader.component, reader.hash = TRANSFORM_COMPONENT, transform
+		b.x = reader.number("x", 0)
+		b.y = reader.number("y", 0)
+		if rigid != nil {
+			reader.component, reader.hash = RIGIDBODY_COMPONENT, rigid
+			b.vx = reader.number("vx", 0)
+			b.vy = reader.number("vy", 0)
+			b.restitution = reader.number("restitution", 0)
+			b.friction = reader.number("friction", 0)
+			b.gravity = reader.number("gravityScale", 1)
+			mass := reader.number("mass", 1)
+			if !reader.boolean("static") && reader.err == nil {
+				if mass <= 0 {
+					return nil, newError("RigidBody.mass of entity %d must be positive, got %g", entity.ID, mass)
+				}
+				b.invMass = 1 / mass
+			}
+		}
+		if collider != nil {
+			reader.component, reader.hash = COLLIDER_COMPONENT, collider
+			b.collider = true
+			b.trigger = reader.boolean("trigger")
+			b.offsetX = reader.number("offsetX", 0)
+			b.offsetY = reader.number("offsetY", 0)
+			b.width = reader.number("width", 1)
+			b.height = reader.number("height", 1)
+			b.radius = reader.number("radius", 0.5)
+			switch shape := reader.str("shape", "box"); shape {
+			case "box":
+			case "circle":
+				b.circle = true
+			default:
+				if reader.err == nil {
+					return nil, newError("Collider.shape of entity %d must be \"box\" or \"circle\", got %q", entity.ID, shape)
+				}
+			}
+		}
+		if reader.err != nil {
+			return nil, reader.err
+		}
+		bodies = append(bodies, b)
+	}
+	return bodies, nil
+}
+
+// fieldReader reads component fields, remembering the first error so a run
+// of reads can be checked once.
+type fieldReader struct {
+	entity    *Entity
+	component string
+	hash      *object.Hash
+	err       *object.Error
+}
+
+func (r *fieldReader) field(name string) (object.Object, bool) {
+	pair, ok := r.hash.Pairs[(&object.String{Value: name}).HashKey()]
+	return pair.Value, ok
+}
+
+func (r *fieldReader) fail(name, want string, got object.Object) {
+	if r.err == nil {
+		r.err = newError("%s.%s of entity %d must be %s, got %s", r.component, name, r.entity.ID, want, got.Type())
+	}
+}
+
+func (r *fieldReader) number(name string, fallback float64) float64 {
+	value, ok := r.field(name)
+	if !ok {
+		return fallback
+	}
+	number, ok := numberArg(value)
+	if !ok {
+		r.fail(name, "a number", value)
+		return fallback
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		if r.err == nil {
+			r.err = newError("%s.%s of entity %d must be finite, got %s", r.component, name, r.entity.ID, value.Inspect())
+		}
+		return fallback
+	}
+	return number
+}
+
+func (r *fieldReader) boolean(name string) bool {
+	value, ok := r.field(name)
+	if !ok {
+		return false
+	}
+	b, ok := value.(*object.Boolean)
+	if !ok {
+		r.fail(name, "a BOOLEAN", value)
+		return false
+	}
+	return b.Value
+}
+
+func (r *fieldReader) str(name, fallback string) string {
+	value, ok := r.field(name)
+	if !ok {
+		return fallback
+	}
+	s, ok := value.(*object.String)
+	if !ok {
+		r.fail(name, "a STRING", value)
+		return fallback
+	}
+	return s.Value
+}
+
+// StepPhysics advances the world's bodies by dt seconds: velocities are
+// integrated, overlapping colliders are pushed apart and bounced, and a
+// "collision" or "trigger" event is emitted for each entity of every
+// touching pair. Pairs are handled in creation order, so a step is
+// deterministic. The step stops with an error once ctx's run is done.
+func (w *World) StepPhysics(ctx *object.CallContext, dt float64) *object.Error {
+	bodies, err := w.physicsBodies()
+	if err != nil || len(bodies) == 0 {
+		return err
+	}
+	for _, b := range bodies {
+		if !b.dynamic() {
+			continue
+		}
+		b.vx += w.physics.gravityX * b.gravity * dt
+		b.vy += w.physics.gravityY * b.gravity * dt
+		b.x += b.vx * dt
+		b.y += b.vy * dt
+	}
+	pairs, err := w.physics.broadphase(ctx, bodies)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		a, b := bodies[pair[0]], bodies[pair[1]]
+		nx, ny, depth, ok := collide(a, b)
+		if !ok {
+			continue
+		}
+		name := "collision"
+		if a.trigger || b.trigger {
+			name = "trigger"
+		} else {
+			resolve(a, b, nx, ny, depth)
+		}
+		w.Emit(name, a.entity, contactData(b.entity, nx, ny, depth))
+		w.Emit(name, b.entity, contactData(a.entity, -nx, -ny, depth))
+	}
+	for _, b := range bodies {
+		if !b.dynamic() {
+			continue
+		}
+		setField(b.transform, "x", &object.Float{Value: b.x})
+		setField(b.transform, "y", &object.Float{Value: b.y})
+		setField(b.rigid, "vx", &object.Float{Value: b.vx})
+		setField(b.rigid, "vy", &object.Float{Value: b.vy})
+	}
+	return nil
+}
+
+func contactData(other *Entity, nx, ny, depth float64) *object.Hash {
+	data := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	setField(data, "other", other)
+	setField(data, "normalX", &object.Float{Value: nx})
+	setField(data, "normalY", &object.Float{Value: ny})
+	setField(data, "depth", &object.Float{Value: depth})
+	return data
+}
+
+type cell struct{ x, y int64 }
+
+// maxCellsPerCollider bounds the cells of the spatial hash a collider is
+// entered in. A collider that would cover more, because it is large or the
+// cells are small, is paired with every other collider instead.
+const maxCellsPerCollider = 64
+
+// broadphase returns the index pairs of colliders that share a cell of the
+// spatial hash and may therefore overlap, sorted so that they are handled
+// in the same order on every run. Pairs of two immovable bodies are left
+// out.
+func (s physicsSettings) broadphase(ctx *object.CallContext, bodies []*body) ([][2]int, *object.Error) {
+	size := s.cellSize
+	if size <= 0 {
+		size = autoCellSize(bodies)
+	}
+	runtime := ctx.Env.Runtime()
+	grid := map[cell][]int{}
+	var colliders, large []int
+	for i, b := range bodies {
+		if !b.collider {
+			continue
+		}
+		if err := runtime.CheckContext(); err != nil {
+			return nil, err
+		}
+		minX, minY, maxX, maxY := b.bounds()
+		for _, bound := range []float64{minX, minY, maxX, maxY} {
+			if math.IsNaN(bound) || math.IsInf(bound, 0) {
+				return nil, newError("collider of entity %d has moved out of bounds", b.entity.ID)
+			}
+		}
+		colliders = append(colliders, i)
+		x0, x1 := math.Floor(minX/size), math.Floor(maxX/size)
+		y0, y1 := math.Floor(minY/size), math.Floor(maxY/size)
+		inRange := math.Max(math.Abs(x0), math.Abs(x1)) < 1<<62 && math.Max(math.Abs(y0), math.Abs(y1)) < 1<<62
+		if !inRange || (x1-x0+1)*(y1-y0+1) > maxCellsPerCollider {
+			large = append(large, i)
+			continue
+		}
+		for x := int64(x0); x <= int64(x1); x++ {
+			for y := int64(y0); y <= int64(y1); y++ {
+				grid[cell{x, y}] = append(grid[cell{x, y}], i)
+			}
+		}
+	}
+	seen := map[[2]int]bool{}
+	var pairs [][2]int
+	add := func(a, b int) {
+		if a > b {
+			a, b = b, a
+		}
+		pair := [2]int{a, b}
+		if a == b || seen[pair] || (!bodies[a].dynamic() && !bodies[b].dynamic()) {
+			return
+		}
+		seen[pair] = true
+		pairs = append(pairs, pair)
+	}
+	for _, members := range grid {
+		for i, a := range members {
+			for _, b := range members[i+1:] {
+				add(a, b)
+			}
+		}
+	}
+	for _, a := range large {
+		if err := runtime.CheckContext(); err != nil {
+			return nil, err
+		}
+		for _, b := range colliders {
+			add(a, b)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	return pairs, nil
+}
+
+// autoCellSize is twice the average collider extent, so that a typical
+// collider covers a few cells.
+func autoCellSize(bodies []*body) float64 {
+	total, count := 0.0, 0
+	for _, b := range bodies {
+		if b.collider {
+			hw, hh := b.halfExtents()
+			total += hw + hh
+			count++
+		}
+	}
+	if count == 0 || total <= 0 {
+		return 1
+	}
+	return 2 * total / float64(count)
+}
+
+// collide reports whether a and b overlap, with the normal pointing from a
+// to b and the penetration depth along it. Touching is not overlapping.
+func collide(a, b *body) (nx, ny, depth float64, ok bool) {
+	switch {
+	case a.circle && b.circle:
+		ax, ay := a.center()
+		bx, by := b.center()
+		dx, dy := bx-ax, by-ay
+		distance := math.Hypot(dx, dy)
+		radii := a.radius + b.radius
+		if distance >= radii {
+			return 0, 0, 0, false
+		}
+		if distance == 0 {
+			return 1, 0, radii, true
+		}
+		return dx / distance, dy / distance, radii - distance, true
+	case a.circle:
+		nx, ny, depth, ok = collideBoxCircle(b, a)
+		return -nx, -ny, depth, ok
+	case b.circle:
+		return collideBoxCircle(a, b)
+	}
+	ax, ay := a.center()
+	bx, by := b.center()
+	dx, dy := bx-ax, by-ay
+	overlapX := a.width/2 + b.width/2 - math.Abs(dx)
+	overlapY := a.height/2 + b.height/2 - math.Abs(dy)
+	if overlapX <= 0 || overlapY <= 0 {
+		return 0, 0, 0, false
+	}
+	if overlapX < overlapY {
+		return direction(dx), 0, overlapX, true
+	}
+	return 0, direction(dy), overlapY, true
+}
+
+// collideBoxCircle is collide for a box a and a circle b.
+func collideBoxCircle(box, circle *body) (nx, ny, depth float64, ok bool) {
+	bx, by := box.center()
+	cx, cy := circle.center()
+	hw, hh := box.width/2, box.height/2
+	dx, dy := cx-bx, cy-by
+	if math.Abs(dx) <= hw && math.Abs(dy) <= hh {
+		// The centre is inside the box or on its edge: push out through the
+		// nearest side.
+		if hw-math.Abs(dx) < hh-math.Abs(dy) {
+			return direction(dx), 0, hw - math.Abs(dx) + circle.radius, true
+		}
+		return 0, direction(dy), hh - math.Abs(dy) + circle.radius, true
+	}
+	closestX := math.Max(-hw, math.Min(dx, hw))
+	closestY := math.Max(-hh, math.Min(dy, hh))
+	ox, oy := dx-closestX, dy-closestY
+	distance := math.Hypot(ox, oy)
+	if distance >= circle.radius {
+		return 0, 0, 0, false
+	}
+	return ox / distance, oy / distance, circle.radius - distance, true
+}
+
+func direction(d float64) float64 {
+	if d < 0 {
+		return -1
+	}
+	return 1
+}
+
+// resolve separates two overlapping bodies in proportion to their inverse
+// masses and applies the bounce and friction impulses.
+func resolve(a, b *body, nx, ny, depth float64) {
+	inverseMass := a.invMass + b.invMass
+	if inverseMass == 0 {
+		return
+	}
+	correction := depth / inverseMass
+	a.x -= nx * correction * a.invMass
+	a.y -= ny * correction * a.invMass
+	b.x += nx * correction * b.invMass
+	b.y += ny * correction * b.invMass
+
+	rvx, rvy := b.vx-a.vx, b.vy-a.vy
+	normalSpeed := rvx*nx + rvy*ny
+	if normalSpeed > 0 {
+		return
+	}
+	restitution := math.Max(a.restitution, b.restitution)
+	j := -(1 + restitution) * normalSpeed / inverseMass
+	applyImpulse(a, b, nx*j, ny*j)
+
+	rvx, rvy = b.vx-a.vx, b.vy-a.vy
+	normalSpeed = rvx*nx + rvy*ny
+	tx, ty := rvx-normalSpeed*nx, rvy-normalSpeed*ny
+	tangentSpeed := math.Hypot(tx, ty)
+	if tangentSpeed < 1e-12 {
+		return
+	}
+	tx, ty = tx/tangentSpeed, ty/tangentSpeed
+	limit := j * math.Sqrt(a.friction*b.friction)
+	jt := math.Max(-limit, math.Min(-tangentSpeed/inverseMass, limit))
+	applyImpulse(a, b, tx*jt, ty*jt)
+}
+
+func applyImpulse(a, b *body, ix, iy float64) {
+	a.vx -= ix * a.invMass
+	a.vy -= iy * a.invMass
+	b.vx += ix * b.invMass
+	b.vy += iy * b.invMass
+}
+
+// raycast returns the nearest non-trigger collider hit by the ray from
+// (x, y) along the unit direction (dx, dy) within maxDistance. Colliders
+// that contain the origin are ignored, so an entity can cast from its own
+// centre.
+func raycast(bodies []*body, x, y, dx, dy, maxDistance float64) (hit *body, distance, nx, ny float64) {
+	distance = maxDistance
+	for _, b := range bodies {
+		if !b.collider || b.trigger {
+			continue
+		}
+		var t, hx, hy float64
+		var ok bool
+		if b.circle {
+			t, hx, hy, ok = rayCircle(b, x, y, dx, dy)
+		} else {
+			t, hx, hy, ok = rayBox(b, x, y, dx, dy)
+		}
+		if ok && t <= distance {
+			hit, distance, nx, ny = b, t, hx, hy
+		}
+	}
+	return hit, distance, nx, ny
+}
+
+func rayCircle(b *body, x, y, dx, dy float64) (t, nx, ny float64, ok bool) {
+	cx, cy := b.center()
+	ox, oy := x-cx, y-cy
+	c := ox*ox + oy*oy - b.radius*b.radius
+	if c <= 0 {
+		return 0, 0, 0, false
+	}
+	half := ox*dx + oy*dy
+	discriminant := half*half - c
+	if half > 0 || discriminant < 0 {
+		return 0, 0, 0, false
+	}
+	t = -half - math.Sqrt(discriminant)
+	return t, (ox + dx*t) / b.radius, (oy + dy*t) / b.radius, true
+}
+
+func rayBox(b *body, x, y, dx, dy float64) (t, nx, ny float64, ok bool) {
+	minX, minY, maxX, maxY := b.bounds()
+	if minX < x && x < maxX && minY < y && y < maxY {
+		return 0, 0, 0, false
+	}
+	near, far := math.Inf(-1), math.Inf(1)
+	for _, axis := range []struct {
+		origin, dir, min, max float64
+		nx, ny                float64
+	}{
+		{x, dx, minX, maxX, 1, 0},
+		{y, dy, minY, maxY, 0, 1},
+	} {
+		if axis.dir == 0 {
+			if axis.origin < axis.min || axis.origin > axis.max {
+				return 0, 0, 0, false
+			}
+			continue
+		}
+		t1 := (axis.min - axis.origin) / axis.dir
+		t2 := (axis.max - axis.origin) / axis.dir
+		sign := -1.0
+		if t1 > t2 {
+			t1, t2 = t2, t1
+			sign = 1
+		}
+		if t1 > near {
+			near, nx, ny = t1, axis.nx*sign, axis.ny*sign
+		}
+		far = math.Min(far, t2)
+	}
+	if near > far || near < 0 {
+		return 0, 0, 0, false
+	}
+	return near, nx, ny, true
+}
+
+// overlapping returns the entities whose colliders overlap query.
+func overlapping(bodies []*body, query *body) *object.Array {
+	result := &object.Array{Elements: []object.Object{}}
+	for _, b := range bodies {
+		if !b.collider {
+			continue
+		}
+		if _, _, _, ok := collide(query, b); ok {
+			result.Elements = append(result.Elements, b.entity)
+		}
+	}
+	return result
+}
+
+// physicsModule returns the physics functions for world. The global
+// physics module works on the default world and world.physics() on others.
+func physicsModule(world *World) *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "gravity", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+				return object.NewVector(world.physics.gravityX, world.physics.gravityY)
+			},
+		}},
+		{Name: "setGravity", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				values, err := numberArgs("setGravity", args)
+				if err != nil {
+					return err
+				}
+				world.physics.gravityX, world.physics.gravityY = values[0], values[1]
+				return NULL
+			},
+		}},
+		{Name: "setCellSize", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				values, err := numberArgs("setCellSize", args)
+				if err != nil {
+					return err
+				}
+				if !(values[0] >= 0) || math.IsInf(values[0], 1) {
+					return newError("cell size must be a finite number that is not negative, got %s", args[0].Inspect())
+				}
+				world.physics.cellSize = values[0]
+				return NULL
+			},
+		}},
+		{Name: "raycast", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				return physicsRaycast(world, args)
+			},
+		}},
+		{Name: "overlapBox", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 4 {
+					return newError("wrong number of arguments. got=%d, want=4", len(args))
+				}
+				values, err := numberArgs("overlapBox", args)
+				if err != nil {
+					return err
+				}
+				bodies, err := world.physicsBodies()
+				if err != nil {
+					return err
+				}
+				return overlapping(bodies, &body{x: values[0], y: values[1], width: values[2], height: values[3]})
+			},
+		}},
+		{Name: "overlapCircle", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=3", len(args))
+				}
+				values, err := numberArgs("overlapCircle", args)
+				if err != nil {
+					return err
+				}
+				bodies, err := world.physicsBodies()
+				if err != nil {
+					return err
+				}
+				return overlapping(bodies, &body{x: values[0], y: values[1], circle: true, radius: values[2]})
+			},
+		}},
+	})
+}
+
+// physicsRaycast implements raycast(x, y, dx, dy, maxDistance?). It returns
+// a hash describing the nearest hit, or null.
+func physicsRaycast(world *World, args []object.Object) object.Object {
+	if len(args) != 4 && len(args) != 5 {
+		return newError("wrong number of arguments. got=%d, want=4 or 5", len(args))
+	}
+	values, err := numberArgs("raycast", args)
+	if err != nil {
+		return err
+	}
+	x, y, dx, dy := values[0], values[1], values[2], values[3]
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return newError("raycast direction must not be zero")
+	}
+	dx, dy = dx/length, dy/length
+	maxDistance := math.Inf(1)
+	if len(values) == 5 {
+		maxDistance = values[4]
+	}
+	bodies, err := world.physicsBodies()
+	if err != nil {
+		return err
+	}
+	hit, distance, nx, ny := raycast(bodies, x, y, dx, dy, maxDistance)
+	if hit == nil {
+		return NULL
+	}
+	result := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	setField(result, "entity", hit.entity)
+	setField(result, "x", &object.Float{Value: x + dx*distance})
+	setField(result, "y", &object.Float{Value: y + dy*distance})
+	setField(result, "distance", &object.Float{Value: distance})
+	setField(result, "normalX", &object.Float{Value: nx})
+	setField(result, "normalY", &object.Float{Value: ny})
+	return result
+}
+
+func worldPhysics(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return physicsModule(receiver.(*World))
+}
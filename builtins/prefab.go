@@ -0,0 +1,274 @@
+package builtins
+
+import (
+	"sort"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+// prefab is an entity template declared with Prefab.define. A prefab that
+// names a base, as children that refer to other prefabs do, is that prefab
+// with components used as overrides.
+type prefab struct {
+	base       string
+	components *object.Hash
+	children   []*prefab
+}
+
+// parsePrefab validates a prefab declaration:
+//
+//	{"components": {"Health": {"hp": 30}}, "children": ["Sword", {"prefab": "Shield", "components": {...}}]}
+//
+// Children are prefab names, hashes naming a prefab with overrides, or
+// inline declarations.
+func parsePrefab(obj object.Object) (*prefab, *object.Error) {
+	if name, ok := obj.(*object.String); ok {
+		return &prefab{base: name.Value}, nil
+	}
+	def, ok := obj.(*object.Hash)
+	if !ok {
+		return nil, newError("prefab must be HASH or STRING, got %s", obj.Type())
+	}
+	p := &prefab{}
+	for _, pair := range def.Pairs {
+		switch pair.Key.Inspect() {
+		case "prefab":
+			base, ok := pair.Value.(*object.String)
+			if !ok {
+				return nil, newError("prefab name must be STRING, got %s", pair.Value.Type())
+			}
+			p.base = base.Value
+		case "components":
+			components, err := componentsArg(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			p.components = components
+		case "children":
+			children, ok := pair.Value.(*object.Array)
+			if !ok {
+				return nil, newError("prefab children must be ARRAY, got %s", pair.Value.Type())
+			}
+			for _, element := range children.Elements {
+				child, err := parsePrefab(element)
+				if err != nil {
+					return nil, err
+				}
+				p.children = append(p.children, child)
+			}
+		default:
+			return nil, newError("unknown prefab field %s", pair.Key.Inspect())
+		}
+	}
+	return p, nil
+}
+
+// componentsArg checks that obj is a hash of component hashes.
+func componentsArg(obj object.Object) (*object.Hash, *object.Error) {
+	components, ok := obj.(*object.Hash)
+	if !ok {
+		return nil, newError("components must be HASH, got %s", obj.Type())
+	}
+	for _, pair := range components.Pairs {
+		if pair.Value.Type() != object.HASH_OBJ {
+			return nil, newError("component %s must be HASH, got %s", pair.Key.Inspect(), pair.Value.Type())
+		}
+	}
+	return components, nil
+}
+
+// mergeComponents returns copies of the components in base with the fields
+// of the matching overrides copied over them. Overrides for components base
+// does not have are added.
+func mergeComponents(base, overrides *object.Hash) (*object.Hash, *object.Error) {
+	merged := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for _, components := range []*object.Hash{base, overrides} {
+		if components == nil {
+			continue
+		}
+		for key, pair := range components.Pairs {
+			component, ok := merged.Pairs[key]
+			if !ok {
+				copied, err := deepCopy(pair.Value, map[object.Object]bool{})
+				if err != nil {
+					return nil, newError("component %s: %s", pair.Key.Inspect(), err.Message)
+				}
+				merged.Pairs[key] = object.HashPair{Key: pair.Key, Value: copied}
+				continue
+			}
+			fields := component.Value.(*object.Hash)
+			for fieldKey, field := range pair.Value.(*object.Hash).Pairs {
+				copied, err := deepCopy(field.Value, map[object.Object]bool{})
+				if err != nil {
+					return nil, newError("component %s: %s", pair.Key.Inspect(), err.Message)
+				}
+				fields.Pairs[fieldKey] = object.HashPair{Key: field.Key, Value: copied}
+			}
+		}
+	}
+	return merged, nil
+}
+
+// SpawnPrefab creates an entity, and entities for its children, from the
+// prefab called name. overrides are merged over the prefab's components,
+// which are in turn merged over the component defaults.
+func (w *World) SpawnPrefab(name string, overrides *object.Hash) (*Entity, *object.Error) {
+	return w.spawnPrefab(&prefab{base: name, components: overrides}, map[string]bool{})
+}
+
+func (w *World) spawnPrefab(p *prefab, visiting map[string]bool) (*Entity, *object.Error) {
+	components := p.components
+	children := p.children
+	if p.base != "" {
+		base, ok := w.components.prefabs[p.base]
+		if !ok {
+			return nil, newError("unknown prefab %s", p.base)
+		}
+		if visiting[p.base] {
+			return nil, newError("prefab %s contains itself", p.base)
+		}
+		visiting[p.base] = true
+		defer delete(visiting, p.base)
+		var err *object.Error
+		if components, err = mergeComponents(base.components, p.components); err != nil {
+			return nil, err
+		}
+		children = append(append([]*prefab(nil), base.children...), p.children...)
+	}
+	components, err := mergeComponents(components, nil)
+	if err != nil {
+		return nil, err
+	}
+	entity := w.Spawn(components)
+	for _, child := range children {
+		childEntity, err := w.spawnPrefab(child, visiting)
+		if err != nil {
+			w.Destroy(entity)
+			return nil, err
+		}
+		childEntity.attach(entity)
+	}
+	return entity, nil
+}
+
+func (r *componentRegistry) prefabNames() []string {
+	names := make([]string, 0, len(r.prefabs))
+	for name := range r.prefabs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// spawnPrefab implements spawning from a prefab: spawn("Goblin") or
+// spawn("Goblin", {"Health": {"hp": 50}}).
+func spawnPrefab(world *World, args []object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `spawn` must be STRING, got %s", args[0].Type())
+	}
+	var overrides *object.Hash
+	if len(args) == 2 {
+		var err *object.Error
+		if overrides, err = componentsArg(args[1]); err != nil {
+			return err
+		}
+	}
+	entity, err := world.SpawnPrefab(name.Value, overrides)
+	if err != nil {
+		return err
+	}
+	return entity
+}
+
+// definePrefabs parses every prefab in defs, a hash of names to
+// declarations, and registers them only if all of them are valid.
+func definePrefabs(registry *componentRegistry, defs *object.Hash) object.Object {
+	parsed := map[string]*prefab{}
+	for _, pair := range defs.Pairs {
+		name, ok := pair.Key.(*object.String)
+		if !ok {
+			return newError("prefab name must be STRING, got %s", pair.Key.Type())
+		}
+		p, err := parsePrefab(pair.Value)
+		if err != nil {
+			return newError("prefab %s: %s", name.Value, err.Message)
+		}
+		if p.base != "" {
+			return newError("prefab %s: a prefab cannot be just a name", name.Value)
+		}
+		parsed[name.Value] = p
+	}
+	names := make([]string, 0, len(parsed))
+	for name, p := range parsed {
+		registry.prefabs[name] = p
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return stringArray(names)
+}
+
+// prefabModule is the Prefab global. Prefab.spawn creates entities in
+// world, the default world.
+func prefabModule(registry *componentRegistry, world *World) *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "define", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				name, ok := args[0].(*object.String)
+				if !ok {
+					return newError("first argument to `Prefab.define` must be STRING, got %s", args[0].Type())
+				}
+				defs := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+				defs.Pairs[name.HashKey()] = object.HashPair{Key: name, Value: args[1]}
+				if result := definePrefabs(registry, defs); isError(result) {
+					return result
+				}
+				return NULL
+			},
+		}},
+		{Name: "spawn", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				return spawnPrefab(world, args)
+			},
+		}},
+		{Name: "names", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+				return stringArray(registry.prefabNames())
+			},
+		}},
+		// load defines the prefabs in a JSON file of names to declarations
+		// and returns their names.
+		{Name: "load", Obj: &object.Builtin{
+			Capability: object.FS_CAPABILITY,
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				values, err := pathArgs(ctx, "Prefab.load", args, 1)
+				if err != nil {
+					return err
+				}
+				data, err := readFile(ctx, values[0])
+				if err != nil {
+					return err
+				}
+				parsed := ParseJSON(data)
+				if isError(parsed) {
+					return parsed
+				}
+				defs, ok := parsed.(*object.Hash)
+				if !ok {
+					return newError("prefab file must contain an object, got %s", parsed.Type())
+				}
+				return definePrefabs(registry, defs)
+			},
+		}},
+	})
+}
@@ -0,0 +1,178 @@
+package builtins
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+const RANDOM_OBJ = "RANDOM"
+
+// Random is a pseudo-random number generator. Two generators created with
+// the same seed produce the same sequence, which makes procedural
+// generation reproducible.
+type Random struct {
+	seed int64
+	rng  *rand.Rand
+}
+
+func newRandom(seed int64) *Random {
+	return &Random{seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (r *Random) Type() object.ObjectType { return RANDOM_OBJ }
+func (r *Random) Inspect() string         { return fmt.Sprintf("random(%d)", r.seed) }
+
+// Float returns a number in [0, 1).
+func (r *Random) Float() float64 { return r.rng.Float64() }
+
+// Int returns an integer in [min, max). min must be less than max; the
+// range may be as wide as the whole of int64.
+func (r *Random) Int(min, max int64) int64 {
+	span := uint64(max) - uint64(min)
+	if span <= math.MaxInt64 {
+		return min + r.rng.Int63n(int64(span))
+	}
+	// More than half of all uint64 values fall in a span this wide, so
+	// rejection sampling ends quickly.
+	for {
+		if n := r.rng.Uint64(); n < span {
+			return int64(uint64(min) + n)
+		}
+	}
+}
+
+func newRandomObject(ctx *object.CallContext, args ...object.Object) object.Object {
+	switch len(args) {
+	case 0:
+		seed, err := randomSeed(ctx)
+		if err != nil {
+			return err
+		}
+		return newRandom(seed)
+	case 1:
+		seed, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError("argument to `Random` must be INTEGER, got %s", args[0].Type())
+		}
+		return newRandom(seed.Value)
+	}
+	return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+}
+
+// sharedRandom is the generator behind Math.random and Math.randomInt. It
+// is seeded on first use rather than when the builtins are created, so the
+// seed is taken through the journal of the program that uses it.
+type sharedRandom struct {
+	mu sync.Mutex
+	r  *Random
+}
+
+func (s *sharedRandom) get(ctx *object.CallContext) (*Random, *object.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.r == nil {
+		seed, err := randomSeed(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.r = newRandom(seed)
+	}
+	return s.r, nil
+}
+
+type randomFunction func(r *Random, args []object.Object) object.Object
+
+// withRandom binds fn to a shared generator so it can be used as a plain
+// builtin.
+func withRandom(shared *sharedRandom, fn randomFunction) object.BuiltinFunction {
+	return func(ctx *object.CallContext, args ...object.Object) object.Object {
+		r, err := shared.get(ctx)
+		if err != nil {
+			return err
+		}
+		return fn(r, args)
+	}
+}
+
+func randomMethod(fn randomFunction) Method {
+	return func(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+		return fn(receiver.(*Random), args)
+	}
+}
+
+var randomMethods = map[string]Method{
+	"random":    randomMethod(randomFloat),
+	"randomInt": randomMethod(randomInt),
+	"pick":      randomMethod(randomPick),
+	"shuffle":   randomMethod(randomShuffle),
+	"seed": randomMethod(func(r *Random, args []object.Object) object.Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+		return &object.Integer{Value: r.seed}
+	}),
+}
+
+func randomFloat(r *Random, args []object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Float{Value: r.Float()}
+}
+
+// randomInt returns an integer in [0, n) for randomInt(n) and in
+// [min, max) for randomInt(min, max).
+func randomInt(r *Random, args []object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		value, ok := arg.(*object.Integer)
+		if !ok {
+			return newError("argument to `randomInt` must be INTEGER, got %s", arg.Type())
+		}
+		bounds[i] = value.Value
+	}
+	if len(bounds) == 1 {
+		bounds = []int64{0, bounds[0]}
+	}
+	if bounds[1] <= bounds[0] {
+		return newError("`randomInt` range [%d, %d) is empty", bounds[0], bounds[1])
+	}
+	return &object.Integer{Value: r.Int(bounds[0], bounds[1])}
+}
+
+func randomPick(r *Random, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `pick` must be ARRAY, got %s", args[0].Type())
+	}
+	if len(arr.Elements) == 0 {
+		return NULL
+	}
+	return arr.Elements[r.rng.Intn(len(arr.Elements))]
+}
+
+// randomShuffle returns a shuffled copy of an array.
+func randomShuffle(r *Random, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `shuffle` must be ARRAY, got %s", args[0].Type())
+	}
+	elements := append([]object.Object(nil), arr.Elements...)
+	r.rng.Shuffle(len(elements), func(i, j int) {
+		elements[i], elements[j] = elements[j], elements[i]
+	})
+	return &object.Array{Elements: elements}
+}
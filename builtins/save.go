@@ -0,0 +1,304 @@
+package builtins
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+// worldFileVersion is the version of the layout of saved worlds, in both
+// formats. It is unrelated to the schema versions of components. Version 2
+// added entity parents; version 1 files are still read.
+const worldFileVersion = 2
+
+// worldSnapshot is the saved form of a world: its entities and components,
+// its clock and its state hash. Systems, timers, event handlers and
+// coroutines are code and are not saved.
+type worldSnapshot struct {
+	clock    time.Duration
+	frame    int64
+	nextID   int64
+	state    *object.Hash
+	versions map[string]int64
+	entities []entitySnapshot
+}
+
+type entitySnapshot struct {
+	id         int64
+	parent     int64
+	components []componentSnapshot
+}
+
+type componentSnapshot struct {
+	name string
+	data *object.Hash
+}
+
+func (w *World) snapshot() *worldSnapshot {
+	snap := &worldSnapshot{
+		clock:    w.clock,
+		frame:    w.frame,
+		nextID:   w.nextID,
+		state:    w.state,
+		versions: map[string]int64{},
+	}
+	for _, entity := range w.entities {
+		saved := entitySnapshot{id: entity.ID}
+		if entity.parent != nil {
+			saved.parent = entity.parent.ID
+		}
+		for _, name := range entity.order {
+			saved.components = append(saved.components, componentSnapshot{name: name, data: entity.components[name]})
+			snap.versions[name] = w.components.version(name)
+		}
+		snap.entities = append(snap.entities, saved)
+	}
+	return snap
+}
+
+// restore builds a world from a snapshot. Components saved with an older
+// schema version are passed through the script's migration hook, and every
+// component is then filled in from its current defaults.
+func restore(ctx *object.CallContext, registry *componentRegistry, snap *worldSnapshot) (*World, *object.Error) {
+	world := newWorld(registry)
+	world.clock = snap.clock
+	world.frame = snap.frame
+	world.nextID = snap.nextID
+	if snap.state != nil {
+		world.state = snap.state
+	}
+	for _, saved := range snap.entities {
+		if _, exists := world.byID[saved.id]; exists || saved.id >= world.nextID {
+			return nil, newError("corrupt world file: bad entity id %d", saved.id)
+		}
+		entity := &Entity{ID: saved.id, world: world, components: map[string]*object.Hash{}, alive: true}
+		for _, component := range saved.components {
+			data, err := migrate(ctx, registry, component.name, snap.versions[component.name], component.data)
+			if err != nil {
+				return nil, err
+			}
+			entity.AddComponent(component.name, data)
+		}
+		world.entities = append(world.entities, entity)
+		world.byID[entity.ID] = entity
+	}
+	for _, saved := range snap.entities {
+		if saved.parent == 0 {
+			continue
+		}
+		parent, ok := world.byID[saved.parent]
+		if !ok {
+			return nil, newError("corrupt world file: bad parent id %d", saved.parent)
+		}
+		if err := world.byID[saved.id].SetParent(parent); err != nil {
+			return nil, newError("corrupt world file: %s", err.Message)
+		}
+	}
+	return world, nil
+}
+
+func migrate(ctx *object.CallContext, registry *componentRegistry, name string, saved int64, data *object.Hash) (*object.Hash, *object.Error) {
+	if saved == 0 {
+		saved = 1
+	}
+	current := registry.version(name)
+	if saved > current {
+		return nil, newError("component %s was saved with schema version %d, newer than %d", name, saved, current)
+	}
+	hook, ok := registry.migrations[name]
+	if saved == current || !ok {
+		return data, nil
+	}
+	result := ctx.Call(hook, data, &object.Integer{Value: saved})
+	switch result := result.(type) {
+	case *object.Error:
+		return nil, result
+	case *object.Hash:
+		return result, nil
+	default:
+		return nil, newError("migration for %s must return HASH, got %s", name, result.Type())
+	}
+}
+
+// encodeJSON writes a snapshot as indented JSON with sorted keys, so saved
+// worlds can be diffed. Components are keyed by name, so they are loaded
+// back in name order.
+func (snap *worldSnapshot) encodeJSON() ([]byte, *object.Error) {
+	state, err := toJSONValue(snap.state, map[object.Object]bool{})
+	if err != nil {
+		return nil, err
+	}
+	entities := make([]interface{}, len(snap.entities))
+	for i, entity := range snap.entities {
+		components := map[string]interface{}{}
+		for _, component := range entity.components {
+			value, err := toJSONValue(component.data, map[object.Object]bool{})
+			if err != nil {
+				return nil, newError("cannot save component %s: %s", component.name, err.Message)
+			}
+			components[component.name] = value
+		}
+		saved := map[string]interface{}{"id": entity.id, "components": components}
+		if entity.parent != 0 {
+			saved["parent"] = entity.parent
+		}
+		entities[i] = saved
+	}
+	doc := map[string]interface{}{
+		"format":   "ecs-world",
+		"version":  worldFileVersion,
+		"time":     int64(snap.clock),
+		"frame":    snap.frame,
+		"nextId":   snap.nextID,
+		"state":    state,
+		"schemas":  snap.versions,
+		"entities": entities,
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, newError("cannot encode JSON: %s", err)
+	}
+	return out.Bytes(), nil
+}
+
+// jsonWorld mirrors the JSON layout written by encodeJSON.
+type jsonWorld struct {
+	Format   string           `json:"format"`
+	Version  int              `json:"version"`
+	Time     int64            `json:"time"`
+	Frame    int64            `json:"frame"`
+	NextID   int64            `json:"nextId"`
+	State    interface{}      `json:"state"`
+	Schemas  map[string]int64 `json:"schemas"`
+	Entities []struct {
+		ID         int64                  `json:"id"`
+		Parent     int64                  `json:"parent"`
+		Components map[string]interface{} `json:"components"`
+	} `json:"entities"`
+}
+
+func decodeJSONWorld(data []byte) (*worldSnapshot, *object.Error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc jsonWorld
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, newError("corrupt world file: %s", err)
+	}
+	if doc.Format != "ecs-world" {
+		return nil, newError("not a world file")
+	}
+	if doc.Version < 1 || doc.Version > worldFileVersion {
+		return nil, newError("unsupported world file version %d", doc.Version)
+	}
+	snap := &worldSnapshot{
+		clock:    time.Duration(doc.Time),
+		frame:    doc.Frame,
+		nextID:   doc.NextID,
+		versions: doc.Schemas,
+	}
+	if doc.State != nil {
+		state, ok := util.ToObject(doc.State).(*object.Hash)
+		if !ok {
+			return nil, newError("corrupt world file: state must be an object")
+		}
+		snap.state = state
+	}
+	for _, entity := range doc.Entities {
+		saved := entitySnapshot{id: entity.ID, parent: entity.Parent}
+		names := make([]string, 0, len(entity.Components))
+		for name := range entity.Components {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			data, ok := util.ToObject(entity.Components[name]).(*object.Hash)
+			if !ok {
+				return nil, newError("corrupt world file: component %s must be an object", name)
+			}
+			saved.components = append(saved.components, componentSnapshot{name: name, data: data})
+		}
+		snap.entities = append(snap.entities, saved)
+	}
+	return snap, nil
+}
+
+// worldSave writes the world to a file: world.save(path) or
+// world.save(path, "binary"). The format defaults to "json".
+func worldSave(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if err := ctx.Env.Runtime().Require(object.FS_CAPABILITY); err != nil {
+		return err
+	}
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	values, err := pathArgs(ctx, "save", args, len(args))
+	if err != nil {
+		return err
+	}
+	format := "json"
+	if len(values) == 2 {
+		format = values[1]
+	}
+	snap := receiver.(*World).snapshot()
+	var data []byte
+	switch format {
+	case "json":
+		data, err = snap.encodeJSON()
+	case "binary":
+		data, err = snap.encodeBinary()
+	default:
+		return newError("unknown world format %q, want \"json\" or \"binary\"", format)
+	}
+	if err != nil {
+		return err
+	}
+	if writeErr := os.WriteFile(values[0], data, 0644); writeErr != nil {
+		return newError("cannot write file: %s", writeErr)
+	}
+	return NULL
+}
+
+// loadWorld implements World.load(path). The format is detected from the
+// file contents.
+func loadWorld(registry *componentRegistry) object.BuiltinFunction {
+	return func(ctx *object.CallContext, args ...object.Object) object.Object {
+		values, err := pathArgs(ctx, "World.load", args, 1)
+		if err != nil {
+			return err
+		}
+		data, err := readFile(ctx, values[0])
+		if err != nil {
+			return err
+		}
+		var snap *worldSnapshot
+		if strings.HasPrefix(data, binaryWorldMagic) {
+			snap, err = decodeBinaryWorld([]byte(data))
+		} else {
+			snap, err = decodeJSONWorld([]byte(data))
+		}
+		if err != nil {
+			return err
+		}
+		world, err := restore(ctx, registry, snap)
+		if err != nil {
+			return err
+		}
+		return world
+	}
+}
+
+func worldState(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return receiver.(*World).state
+}
@@ -0,0 +1,326 @@
+package builtins
+
+import (
+	"container/list"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+// stringArgs checks that exactly want arguments were passed to the builtin
+// called name and that all of them are strings.
+func stringArgs(name string, args []object.Object, want int) ([]string, *object.Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+	values := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		values[i] = str.Value
+	}
+	return values, nil
+}
+
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.String{Value: v}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func nativeBool(value bool) *object.Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
+func split(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := stringArgs("split", args, 2)
+	if err != nil {
+		return err
+	}
+	return stringArray(strings.Split(values[0], values[1]))
+}
+
+func replace(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := stringArgs("replace", args, 3)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ReplaceAll(values[0], values[1], values[2])}
+}
+
+func trim(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) == 2 {
+		values, err := stringArgs("trim", args, 2)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: strings.Trim(values[0], values[1])}
+	}
+	values, err := stringArgs("trim", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.TrimSpace(values[0])}
+}
+
+func upper(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := stringArgs("upper", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToUpper(values[0])}
+}
+
+func lower(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := stringArgs("lower", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToLower(values[0])}
+}
+
+func startsWith(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := stringArgs("startsWith", args, 2)
+	if err != nil {
+		return err
+	}
+	return nativeBool(strings.HasPrefix(values[0], values[1]))
+}
+
+func endsWith(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := stringArgs("endsWith", args, 2)
+	if err != nil {
+		return err
+	}
+	return nativeBool(strings.HasSuffix(values[0], values[1]))
+}
+
+func contains(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := stringArgs("contains", args, 2)
+	if err != nil {
+		return err
+	}
+	return nativeBool(strings.Contains(values[0], values[1]))
+}
+
+// indexOf returns the position of the first occurrence of the substring,
+// counted in characters rather than bytes, or -1 if it is not present.
+func indexOf(ctx *object.CallContext, args ...object.Object) object.Object {
+	values, err := stringArgs("indexOf", args, 2)
+	if err != nil {
+		return err
+	}
+	idx := strings.Index(values[0], values[1])
+	if idx < 0 {
+		return &object.Integer{Value: -1}
+	}
+	return &object.Integer{Value: int64(utf8.RuneCountInString(values[0][:idx]))}
+}
+
+// maxRepeatLength is the longest string repeat may return, in bytes.
+const maxRepeatLength = 1 << 28
+
+// repeat returns a string repeated count times. Every copy counts against
+// the run's allocation limit.
+func repeat(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `repeat` must be STRING, got %s", args[0].Type())
+	}
+	count, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError("second argument to `repeat` must be INTEGER, got %s", args[1].Type())
+	}
+	if count.Value < 0 {
+		return newError("negative repeat count: %d", count.Value)
+	}
+	if str.Value == "" {
+		return &object.String{}
+	}
+	if count.Value > maxRepeatLength/int64(len(str.Value)) {
+		return newError("repeated string would be longer than %d bytes", maxRepeatLength)
+	}
+	if err := ctx.Env.Runtime().Allocate(count.Value); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+}
+
+// format substitutes its arguments into a template. "{}" takes the next
+// argument, "{n}" takes argument n and "{{" / "}}" produce literal braces.
+func format(ctx *object.CallContext, args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+	template, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `format` must be STRING, got %s", args[0].Type())
+	}
+	values := args[1:]
+	var out strings.Builder
+	next := 0
+	input := template.Value
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+		switch {
+		case ch == '{' && i+1 < len(input) && input[i+1] == '{':
+			out.WriteByte('{')
+			i++
+		case ch == '}' && i+1 < len(input) && input[i+1] == '}':
+			out.WriteByte('}')
+			i++
+		case ch == '{':
+			end := strings.IndexByte(input[i:], '}')
+			if end < 0 {
+				return newError("unterminated placeholder in format string %q", input)
+			}
+			spec := input[i+1 : i+end]
+			idx := next
+			if spec == "" {
+				next++
+			} else {
+				n, err := strconv.Atoi(spec)
+				if err != nil {
+					return newError("invalid placeholder {%s} in format string", spec)
+				}
+				idx = n
+			}
+			if idx < 0 || idx >= len(values) {
+				return newError("format string references argument %d, but got %d", idx+1, len(values))
+			}
+			out.WriteString(values[idx].Inspect())
+			i += end
+		default:
+			out.WriteByte(ch)
+		}
+	}
+	return &object.String{Value: out.String()}
+}
+
+// regexCacheSize is the number of compiled patterns compileRegex keeps.
+const regexCacheSize = 256
+
+// regexCache holds the most recently used compiled patterns, the most
+// recent at the front of order.
+var regexCache = struct {
+	sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}{order: list.New(), entries: map[string]*list.Element{}}
+
+// compileRegex compiles pattern once and reuses the result for later calls,
+// as long as it is among the last regexCacheSize patterns used.
+func compileRegex(pattern string) (*regexp.Regexp, *object.Error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+	if e, ok := regexCache.entries[pattern]; ok {
+		regexCache.order.MoveToFront(e)
+		return e.Value.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, newError("invalid regular expression: %s", err)
+	}
+	regexCache.entries[pattern] = regexCache.order.PushFront(re)
+	if regexCache.order.Len() > regexCacheSize {
+		oldest := regexCache.order.Remove(regexCache.order.Back()).(*regexp.Regexp)
+		delete(regexCache.entries, oldest.String())
+	}
+	return re, nil
+}
+
+// regexArgs checks the arguments of a regex builtin and compiles the pattern
+// passed as the first argument.
+func regexArgs(name string, args []object.Object, want int) (*regexp.Regexp, []string, *object.Error) {
+	values, err := stringArgs("regex."+name, args, want)
+	if err != nil {
+		return nil, nil, err
+	}
+	re, err := compileRegex(values[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return re, values[1:], nil
+}
+
+func regex() *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "test", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				re, values, err := regexArgs("test", args, 2)
+				if err != nil {
+					return err
+				}
+				return nativeBool(re.MatchString(values[0]))
+			},
+		}},
+		{Name: "find", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				re, values, err := regexArgs("find", args, 2)
+				if err != nil {
+					return err
+				}
+				loc := re.FindStringIndex(values[0])
+				if loc == nil {
+					return NULL
+				}
+				return &object.String{Value: values[0][loc[0]:loc[1]]}
+			},
+		}},
+		{Name: "findAll", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				re, values, err := regexArgs("findAll", args, 2)
+				if err != nil {
+					return err
+				}
+				return stringArray(re.FindAllString(values[0], -1))
+			},
+		}},
+		{Name: "groups", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				re, values, err := regexArgs("groups", args, 2)
+				if err != nil {
+					return err
+				}
+				match := re.FindStringSubmatch(values[0])
+				if match == nil {
+					return NULL
+				}
+				return stringArray(match)
+			},
+		}},
+		{Name: "replace", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				re, values, err := regexArgs("replace", args, 3)
+				if err != nil {
+					return err
+				}
+				return &object.String{Value: re.ReplaceAllString(values[0], values[1])}
+			},
+		}},
+		{Name: "split", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				re, values, err := regexArgs("split", args, 2)
+				if err != nil {
+					return err
+				}
+				return stringArray(re.Split(values[0], -1))
+			},
+		}},
+	})
+}
@@ -1,3 +1,71 @@
 package builtins
 
-// TODO: implement based on TS version of this api
+import (
+	"fmt"
+	"io"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+var terminalColors = map[string]int{
+	"black":   30,
+	"red":     31,
+	"green":   32,
+	"yellow":  33,
+	"blue":    34,
+	"magenta": 35,
+	"cyan":    36,
+	"white":   37,
+}
+
+// terminalModule controls the terminal the program's output is shown on
+// using ANSI escape sequences.
+func terminalModule() *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "clear", Obj: terminalSequence("clear", "\x1b[2J\x1b[H")},
+		{Name: "reset", Obj: terminalSequence("reset", "\x1b[0m")},
+		{Name: "hideCursor", Obj: terminalSequence("hideCursor", "\x1b[?25l")},
+		{Name: "showCursor", Obj: terminalSequence("showCursor", "\x1b[?25h")},
+		{Name: "moveTo", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				x, xOk := args[0].(*object.Integer)
+				y, yOk := args[1].(*object.Integer)
+				if !xOk || !yOk {
+					return newError("arguments to `terminal.moveTo` must be INTEGER, got %s and %s", args[0].Type(), args[1].Type())
+				}
+				fmt.Fprintf(ctx.Out, "\x1b[%d;%dH", y.Value+1, x.Value+1)
+				return NULL
+			},
+		}},
+		{Name: "color", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				values, err := stringArgs("terminal.color", args, 1)
+				if err != nil {
+					return err
+				}
+				code, ok := terminalColors[values[0]]
+				if !ok {
+					return newError("unknown terminal color: %s", values[0])
+				}
+				fmt.Fprintf(ctx.Out, "\x1b[%dm", code)
+				return NULL
+			},
+		}},
+	})
+}
+
+func terminalSequence(name, sequence string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments to `terminal.%s`. got=%d, want=0", name, len(args))
+			}
+			io.WriteString(ctx.Out, sequence)
+			return NULL
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package builtins
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// timer is a callback scheduled on a world's clock. interval is zero for
+// timers that fire once.
+type timer struct {
+	id        int64
+	fn        object.Object
+	at        time.Duration
+	interval  time.Duration
+	index     int
+	cancelled bool
+}
+
+// timerQueue is a heap of timers ordered by when they fire. Timers due at
+// the same time fire in the order they were created.
+type timerQueue []*timer
+
+func (q timerQueue) Len() int { return len(q) }
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].id < q[j].id
+}
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *timerQueue) Push(x interface{}) {
+	t := x.(*timer)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return t
+}
+
+// After schedules fn to be called once delay has passed on the world's
+// clock, and then every interval if interval is positive. It returns
+// the timer id for CancelTimer.
+func (w *World) After(delay, interval time.Duration, fn object.Object) int64 {
+	w.nextTimerID++
+	heap.Push(&w.timers, &timer{id: w.nextTimerID, fn: fn, at: w.clock + delay, interval: interval})
+	return w.nextTimerID
+}
+
+// CancelTimer stops a timer and reports whether it was still scheduled.
+func (w *World) CancelTimer(id int64) bool {
+	for _, t := range w.timers {
+		if t.id == id {
+			t.cancelled = true
+			heap.Remove(&w.timers, t.index)
+			return true
+		}
+	}
+	return false
+}
+
+// runTimers calls the timers that are due, earliest first. A repeating
+// timer that fell behind fires once for every interval that has passed.
+// Timers created by a callback wait for the next tick even if they are
+// already due.
+func (w *World) runTimers(ctx *object.CallContext) object.Object {
+	last := w.nextTimerID
+	for len(w.timers) > 0 && w.timers[0].at <= w.clock && w.timers[0].id <= last {
+		t := w.timers[0]
+		if t.interval > 0 {
+			t.at += t.interval
+			heap.Fix(&w.timers, 0)
+		} else {
+			heap.Pop(&w.timers)
+		}
+		if result := ctx.Call(t.fn); isError(result) {
+			return result
+		}
+	}
+	return NULL
+}
+
+// scheduleTimer implements after(ms, fn) and every(ms, fn).
+func scheduleTimer(name string, repeat bool, world *World, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	ms, ok := numberArg(args[0])
+	if !ok || ms < 0 || (repeat && ms == 0) {
+		if repeat {
+			return newError("first argument to `%s` must be a positive number, got %s", name, args[0].Inspect())
+		}
+		return newError("first argument to `%s` must be a non-negative number, got %s", name, args[0].Inspect())
+	}
+	if !isCallable(args[1]) {
+		return newError("second argument to `%s` must be FUNCTION, got %s", name, args[1].Type())
+	}
+	delay := seconds(ms / 1000)
+	interval := time.Duration(0)
+	if repeat {
+		interval = delay
+	}
+	return &object.Integer{Value: world.After(delay, interval, args[1])}
+}
+
+func cancelTimer(world *World, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	id, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to `cancel` must be INTEGER, got %s", args[0].Type())
+	}
+	return nativeBool(world.CancelTimer(id.Value))
+}
+
+// timerBuiltins returns after, every and cancel bound to world.
+func timerBuiltins(world *World) map[string]object.Object {
+	return map[string]object.Object{
+		"after": &object.Builtin{Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			return scheduleTimer("after", false, world, args)
+		}},
+		"every": &object.Builtin{Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			return scheduleTimer("every", true, world, args)
+		}},
+		"cancel": &object.Builtin{Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			return cancelTimer(world, args)
+		}},
+	}
+}
+
+func worldAfter(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	return scheduleTimer("after", false, receiver.(*World), args)
+}
+
+func worldEvery(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	return scheduleTimer("every", true, receiver.(*World), args)
+}
+
+func worldCancel(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	return cancelTimer(receiver.(*World), args)
+}
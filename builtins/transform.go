@@ -0,0 +1,106 @@
+package builtins
+
+import (
+	"math"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// TRANSFORM_COMPONENT is the built-in component positioning an entity
+// relative to its parent. x, y, rotation (in radians), scaleX and scaleY are
+// set by scripts; worldX, worldY, worldRotation, worldScaleX and worldScaleY
+// are computed from them and the parent's at the end of every tick.
+const TRANSFORM_COMPONENT = "Transform"
+
+// transformDefaults are the Transform fields a new component starts with.
+func transformDefaults() *object.Hash {
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for _, field := range []struct {
+		name  string
+		value float64
+	}{
+		{"x", 0}, {"y", 0}, {"rotation", 0}, {"scaleX", 1}, {"scaleY", 1},
+	} {
+		setField(hash, field.name, &object.Float{Value: field.value})
+	}
+	return hash
+}
+
+// transform2D is a position, rotation and scale in 2D.
+type transform2D struct {
+	x, y, rotation, scaleX, scaleY float64
+}
+
+var identityTransform = transform2D{scaleX: 1, scaleY: 1}
+
+// apply returns local expressed in the space that t is relative to.
+func (t transform2D) apply(local transform2D) transform2D {
+	sin, cos := math.Sincos(t.rotation)
+	x, y := local.x*t.scaleX, local.y*t.scaleY
+	return transform2D{
+		x:        t.x + x*cos - y*sin,
+		y:        t.y + x*sin + y*cos,
+		rotation: t.rotation + local.rotation,
+		scaleX:   t.scaleX * local.scaleX,
+		scaleY:   t.scaleY * local.scaleY,
+	}
+}
+
+// UpdateTransforms computes the world fields of every Transform from the
+// root entities down. Entities without a Transform pass their parent's
+// world transform on to their children unchanged.
+func (w *World) UpdateTransforms() object.Object {
+	for _, entity := range w.entities {
+		if entity.parent != nil {
+			continue
+		}
+		if err := propagateTransform(entity, identityTransform); err != nil {
+			return err
+		}
+	}
+	return NULL
+}
+
+func propagateTransform(entity *Entity, parent transform2D) *object.Error {
+	world := parent
+	if component := entity.Component(TRANSFORM_COMPONENT); component != nil {
+		local, err := readTransform(entity, component)
+		if err != nil {
+			return err
+		}
+		world = parent.apply(local)
+		setField(component, "worldX", &object.Float{Value: world.x})
+		setField(component, "worldY", &object.Float{Value: world.y})
+		setField(component, "worldRotation", &object.Float{Value: world.rotation})
+		setField(component, "worldScaleX", &object.Float{Value: world.scaleX})
+		setField(component, "worldScaleY", &object.Float{Value: world.scaleY})
+	}
+	for _, child := range entity.children {
+		if err := propagateTransform(child, world); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTransform(entity *Entity, component *object.Hash) (transform2D, *object.Error) {
+	t := identityTransform
+	fields := []struct {
+		name  string
+		value *float64
+	}{
+		{"x", &t.x}, {"y", &t.y}, {"rotation", &t.rotation}, {"scaleX", &t.scaleX}, {"scaleY", &t.scaleY},
+	}
+	for _, field := range fields {
+		pair, ok := component.Pairs[(&object.String{Value: field.name}).HashKey()]
+		if !ok {
+			continue
+		}
+		value, ok := numberArg(pair.Value)
+		if !ok {
+			return t, newError("Transform.%s of entity %d must be a number, got %s", field.name, entity.ID, pair.Value.Type())
+		}
+		*field.value = value
+	}
+	return t, nil
+}
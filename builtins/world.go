@@ -1,3 +1,436 @@
 package builtins
 
-// TODO: implement based on TS version of this api
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+const WORLD_OBJ = "WORLD"
+
+// World owns a set of entities together with the systems, timers, event
+// handlers and coroutines that update them. Tick runs everything on the
+// calling goroutine in a fixed order: queued key events on the default
+// world, then systems in registration order, then the physics step, then
+// due timers, then queued events, then coroutines in the order they were
+// started. A world advanced with the same time steps therefore behaves the
+// same way on every run.
+type World struct {
+	components  *componentRegistry
+	entities    []*Entity
+	byID        map[int64]*Entity
+	nextID      int64
+	systems     []object.Object
+	renderers   []object.Object
+	stopped     bool
+	state       *object.Hash
+	coroutines  []*scheduledCoroutine
+	events      eventBus
+	timers      timerQueue
+	nextTimerID int64
+	clock       time.Duration
+	frame       int64
+	physics     physicsSettings
+	input       *Input
+}
+
+type scheduledCoroutine struct {
+	co     *object.Coroutine
+	entity *Entity
+	wakeAt time.Duration
+}
+
+func newWorld(components *componentRegistry) *World {
+	return &World{
+		components: components,
+		byID:       map[int64]*Entity{},
+		nextID:     1,
+		state:      &object.Hash{Pairs: map[object.HashKey]object.HashPair{}},
+	}
+}
+
+func (w *World) Type() object.ObjectType { return WORLD_OBJ }
+func (w *World) Inspect() string {
+	return fmt.Sprintf("world(%d entities)", len(w.entities))
+}
+
+// Time returns the game time that ticks have advanced the world by.
+func (w *World) Time() time.Duration { return w.clock }
+
+// Frame returns the number of ticks so far.
+func (w *World) Frame() int64 { return w.frame }
+
+// Input returns the input module the world updates at the start of every
+// tick. Only the default world has one.
+func (w *World) Input() *Input { return w.input }
+
+// Renderers returns the callbacks registered with world.render. A game loop
+// calls each of them with the interpolation factor after every frame.
+func (w *World) Renderers() []object.Object {
+	return append([]object.Object(nil), w.renderers...)
+}
+
+// Stopped reports whether the script has called world.stop.
+func (w *World) Stopped() bool { return w.stopped }
+
+// Resume clears a call to world.stop, so the world can be driven again.
+func (w *World) Resume() { w.stopped = false }
+
+// Entities returns the live entities in creation order.
+func (w *World) Entities() []*Entity {
+	return append([]*Entity(nil), w.entities...)
+}
+
+// Entity returns the live entity with the given id, or nil.
+func (w *World) Entity(id int64) *Entity {
+	return w.byID[id]
+}
+
+// Spawn creates an entity with the given components.
+func (w *World) Spawn(components *object.Hash) *Entity {
+	entity := &Entity{ID: w.nextID, world: w, components: map[string]*object.Hash{}, alive: true}
+	w.nextID++
+	w.entities = append(w.entities, entity)
+	w.byID[entity.ID] = entity
+	if components != nil {
+		for _, pair := range SortedPairs(components) {
+			data, _ := pair.Value.(*object.Hash)
+			entity.AddComponent(pair.Key.Inspect(), data)
+		}
+	}
+	return entity
+}
+
+// Destroy removes an entity and all of its descendants from the world,
+// cancels their coroutines and removes the event handlers registered
+// through them.
+func (w *World) Destroy(entity *Entity) {
+	if !entity.alive || entity.world != w {
+		return
+	}
+	for _, child := range entity.Children() {
+		w.Destroy(child)
+	}
+	entity.alive = false
+	entity.detach()
+	delete(w.byID, entity.ID)
+	for i, e := range w.entities {
+		if e == entity {
+			w.entities = append(w.entities[:i], w.entities[i+1:]...)
+			break
+		}
+	}
+	for _, scheduled := range w.coroutines {
+		if scheduled.entity == entity {
+			scheduled.co.Cancel()
+		}
+	}
+	w.events.off(func(h *eventHandler) bool { return h.entity == entity })
+}
+
+// Query returns the live entities that have all of the named components, in
+// creation order.
+func (w *World) Query(names []string) []*Entity {
+	var matches []*Entity
+	for _, entity := range w.entities {
+		if entity.HasComponents(names) {
+			matches = append(matches, entity)
+		}
+	}
+	return matches
+}
+
+// StartCoroutine runs co until it first suspends and then schedules it to
+// be resumed by Tick. Coroutines owned by an entity are cancelled when the
+// entity is destroyed. It returns co, or the error co raised.
+func (w *World) StartCoroutine(ctx *object.CallContext, co *object.Coroutine, owner *Entity) object.Object {
+	scheduled := &scheduledCoroutine{co: co, entity: owner}
+	if err := w.resume(ctx, scheduled); err != nil {
+		return err
+	}
+	if !co.Done() {
+		w.coroutines = append(w.coroutines, scheduled)
+	}
+	return co
+}
+
+// resume runs a scheduled coroutine until it suspends and works out when it
+// should run next.
+func (w *World) resume(ctx *object.CallContext, scheduled *scheduledCoroutine) *object.Error {
+	result := scheduled.co.Resume(ctx, NULL)
+	if err, ok := result.(*object.Error); ok {
+		scheduled.co.Cancel()
+		return err
+	}
+	scheduled.wakeAt = w.clock
+	if wait, ok := result.(*object.Wait); ok {
+		scheduled.wakeAt += seconds(wait.Seconds)
+	}
+	return nil
+}
+
+// Tick advances the world's clock by dt seconds: it calls every system with
+// dt, fires the timers that are due, delivers the events queued so far,
+// resumes every coroutine that is due and finally updates the world
+// transforms of the entities with a Transform. It stops at the first error.
+func (w *World) Tick(ctx *object.CallContext, dt float64) object.Object {
+	w.clock += seconds(dt)
+	w.frame++
+	if w.input != nil {
+		if err := w.input.update(ctx, w.frame); err != nil {
+			return err
+		}
+	}
+	dtObj := &object.Float{Value: dt}
+	for _, system := range w.systems {
+		if result := ctx.Call(system, dtObj, w); isError(result) {
+			return result
+		}
+	}
+	if err := w.StepPhysics(ctx, dt); err != nil {
+		return err
+	}
+	if result := w.runTimers(ctx); isError(result) {
+		return result
+	}
+	if result := w.events.dispatch(ctx); isError(result) {
+		return result
+	}
+	due := w.coroutines
+	for _, scheduled := range due {
+		if scheduled.co.Done() || scheduled.wakeAt > w.clock {
+			continue
+		}
+		if err := w.resume(ctx, scheduled); err != nil {
+			w.removeFinishedCoroutines()
+			return err
+		}
+	}
+	w.removeFinishedCoroutines()
+	return w.UpdateTransforms()
+}
+
+func (w *World) removeFinishedCoroutines() {
+	running := w.coroutines[:0]
+	for _, scheduled := range w.coroutines {
+		if !scheduled.co.Done() {
+			running = append(running, scheduled)
+		}
+	}
+	for i := len(running); i < len(w.coroutines); i++ {
+		w.coroutines[i] = nil
+	}
+	w.coroutines = running
+}
+
+// seconds converts script time in seconds to the world's clock. The clock
+// counts whole nanoseconds so that adding up steps such as 0.1 never drifts
+// past a timer or wait.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+// SortedPairs returns the pairs of a hash ordered by key, and by key type
+// for keys that look the same, such as 1 and "1", so that iterating a hash
+// is deterministic.
+func SortedPairs(hash *object.Hash) []object.HashPair {
+	pairs := make([]object.HashPair, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i].Key.Inspect(), pairs[j].Key.Inspect()
+		if a != b {
+			return a < b
+		}
+		return pairs[i].Key.Type() < pairs[j].Key.Type()
+	})
+	return pairs
+}
+
+// worldNamespace is the World global: World.create() makes an empty world
+// and World.load(path) one saved with world.save. Both share the builtin
+// set's component registry.
+func worldNamespace(components *componentRegistry) *object.Hash {
+	return util.MakeBuiltinInterface([]util.StringObjectPair{
+		{Name: "create", Obj: &object.Builtin{
+			Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+				return newWorld(components)
+			},
+		}},
+		{Name: "load", Obj: &object.Builtin{Fn: loadWorld(components), Capability: object.FS_CAPABILITY}},
+	})
+}
+
+var worldMethods = map[string]Method{
+	"spawn":    worldSpawn,
+	"entity":   worldEntity,
+	"entities": worldEntities,
+	"query":    worldQuery,
+	"system":   worldSystem,
+	"start":    worldStart,
+	"tick":     worldTick,
+	"time":     worldTime,
+	"frame":    worldFrame,
+	"physics":  worldPhysics,
+	"on":       worldOn,
+	"once":     worldOnce,
+	"off":      worldOff,
+	"emit":     worldEmit,
+	"after":    worldAfter,
+	"every":    worldEvery,
+	"cancel":   worldCancel,
+	"render":   worldRender,
+	"stop":     worldStop,
+	"save":     worldSave,
+	"state":    worldState,
+}
+
+// worldSpawn creates an entity from components, world.spawn({"Health":
+// {"hp": 10}}), or from a prefab, world.spawn("Goblin", {"Health": {"hp": 50}}).
+func worldSpawn(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	world := receiver.(*World)
+	if len(args) > 0 && args[0].Type() == object.STRING_OBJ {
+		return spawnPrefab(world, args)
+	}
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+	var components *object.Hash
+	if len(args) == 1 {
+		var err *object.Error
+		if components, err = componentsArg(args[0]); err != nil {
+			return err
+		}
+	}
+	return world.Spawn(components)
+}
+
+func worldEntity(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	id, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to `entity` must be INTEGER, got %s", args[0].Type())
+	}
+	if entity := receiver.(*World).Entity(id.Value); entity != nil {
+		return entity
+	}
+	return NULL
+}
+
+func entityArray(entities []*Entity) *object.Array {
+	elements := make([]object.Object, len(entities))
+	for i, entity := range entities {
+		elements[i] = entity
+	}
+	return &object.Array{Elements: elements}
+}
+
+func worldEntities(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return entityArray(receiver.(*World).entities)
+}
+
+// worldQuery returns the entities with all of the named components:
+// world.query("Position", "Velocity").
+func worldQuery(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	names, err := stringArgs("query", args, len(args))
+	if err != nil {
+		return err
+	}
+	return entityArray(receiver.(*World).Query(names))
+}
+
+// worldSystem registers fn(dt, world) to be called on every tick.
+func worldSystem(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if !isCallable(args[0]) {
+		return newError("argument to `system` must be FUNCTION, got %s", args[0].Type())
+	}
+	world := receiver.(*World)
+	world.systems = append(world.systems, args[0])
+	return NULL
+}
+
+// worldStart runs fn(args...), or resumes an existing coroutine, on the
+// world's scheduler.
+func worldStart(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+	world := receiver.(*World)
+	if co, ok := args[0].(*object.Coroutine); ok {
+		return world.StartCoroutine(ctx, co, nil)
+	}
+	if !isCallable(args[0]) {
+		return newError("first argument to `start` must be FUNCTION or COROUTINE, got %s", args[0].Type())
+	}
+	return world.StartCoroutine(ctx, ctx.StartCoroutine(args[0], args[1:]...), nil)
+}
+
+func worldTick(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	dt, ok := numberArg(args[0])
+	if !ok || dt < 0 {
+		return newError("argument to `tick` must be a non-negative number, got %s", args[0].Inspect())
+	}
+	return receiver.(*World).Tick(ctx, dt)
+}
+
+func worldTime(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Float{Value: receiver.(*World).clock.Seconds()}
+}
+
+func worldFrame(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Integer{Value: receiver.(*World).frame}
+}
+
+// worldRender registers fn(alpha) to be called by the game loop once per
+// frame. alpha is how far the loop is between the last tick and the next,
+// from 0 to 1, for interpolating positions.
+func worldRender(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if !isCallable(args[0]) {
+		return newError("argument to `render` must be FUNCTION, got %s", args[0].Type())
+	}
+	world := receiver.(*World)
+	world.renderers = append(world.renderers, args[0])
+	return NULL
+}
+
+// worldStop asks the game loop driving the world to finish after the
+// current frame.
+func worldStop(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	receiver.(*World).stopped = true
+	return NULL
+}
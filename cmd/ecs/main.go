@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/SpaceHexagon/ecs"
+	"github.com/SpaceHexagon/ecs/debugger"
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/repl"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(run(os.Args[2:], nil))
+		case "replay":
+			os.Exit(replay(os.Args[2:]))
+		case "debug":
+			os.Exit(debug(os.Args[2:]))
+		}
+	}
+
+	var opts options
+	opts.register(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ecs [flags] [file.ecs]\n       ecs run [flags] file.ecs\n       ecs replay out.log\n       ecs debug [flags] file.ecs\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	interp, err := opts.interpreter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if flag.NArg() > 0 {
+		if _, err := interp.RunFile(flag.Arg(0)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	user, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("| Welcome to ECS, %s\n",
+		user.Username)
+	fmt.Printf("| Interactive Mode\n")
+	repl.Start(os.Stdin, os.Stdout, interp)
+}
+
+// run implements `ecs run`: it runs a script to set up the world and then
+// drives the world with a fixed-timestep loop until the script calls
+// world.stop(), the frame count is reached or the user presses Ctrl-C.
+// With -record it journals the run so `ecs replay` can reproduce it; a
+// non-nil replayer makes it reproduce a recorded run instead.
+func run(args []string, replayer *ecs.Replayer) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	var opts options
+	opts.register(flags)
+	hz := flags.Float64("hz", 60, "ticks per second")
+	maxCatchUp := flags.Int("max-catch-up", 5, "most ticks per frame when running behind")
+	frames := flags.Int64("frames", 0, "run this many frames headless and exit (0 means run until stopped)")
+	stats := flags.Bool("stats", false, "print frame statistics on exit")
+	inputFile := flags.String("input", "", "replay the recorded key events in `file`")
+	record := flags.String("record", "", "record everything the run depends on to `file` for ecs replay")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: ecs run [flags] file.ecs\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || *hz <= 0 {
+		flags.Usage()
+		return 2
+	}
+
+	interp, err := opts.interpreter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if replayer != nil {
+		interp.SetJournal(replayer)
+	} else if *record != "" {
+		file, err := os.Create(*record)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		recorder := ecs.NewRecorder(file)
+		recorder.Entry("args", func() object.Object { return util.ToObject(args) })
+		interp.SetJournal(recorder)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if _, err := interp.RunFileContext(ctx, flags.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// A replay takes the key events of every tick from the log.
+	if *inputFile != "" && replayer == nil {
+		if err := replayInput(interp, *inputFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	result, err := interp.RunLoop(ctx, ecs.LoopConfig{
+		Step:       time.Duration(float64(time.Second) / *hz),
+		MaxCatchUp: *maxCatchUp,
+		Frames:     *frames,
+	})
+	if *stats {
+		fmt.Fprintf(os.Stderr, "%d frames, %d ticks in %s (%s dropped)\n",
+			result.Frames, result.Ticks, result.Elapsed.Round(time.Millisecond), result.Dropped)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// replay implements `ecs replay`: it reruns a run recorded with
+// `ecs run -record`, with the same flags and script and with the time,
+// random seeds, key events and files the recorded run saw.
+func replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: ecs replay out.log\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+	replayer, err := ecs.NewReplayer(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", flags.Arg(0), err)
+		return 1
+	}
+	recorded := replayer.Entry("args", nil)
+	if err, ok := recorded.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", flags.Arg(0), err.Message)
+		return 1
+	}
+	var runArgs []string
+	if err := util.FromObject(recorded, &runArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", flags.Arg(0), err)
+		return 1
+	}
+	status := run(runArgs, replayer)
+	if status == 0 && replayer.Remaining() > 0 {
+		fmt.Fprintf(os.Stderr, "replay finished with %d entries left in the log\n", replayer.Remaining())
+		return 1
+	}
+	return status
+}
+
+// debug implements `ecs debug`: it runs a script under the step debugger,
+// which reads its commands from standard input, and then ticks the world
+// headless for -frames frames so that systems can be stepped through too.
+func debug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	var opts options
+	opts.register(flags)
+	frames := flags.Int64("frames", 0, "tick the world this many frames after the script has run")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: ecs debug [flags] file.ecs\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	source, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	interp, err := opts.interpreter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("| Debugging", flags.Arg(0), "- type help for commands")
+	interp.Runtime().Debugger = debugger.New(os.Stdin, os.Stdout, string(source))
+	// Standard input belongs to the debugger, so readLine and input see
+	// the end of input.
+	interp.SetInput(nil)
+	if _, err := interp.RunString(string(source)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *frames > 0 {
+		if _, err := interp.RunLoop(context.Background(), ecs.LoopConfig{Frames: *frames}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func replayInput(interp *ecs.Interpreter, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := interp.ReplayInput(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// options holds the flags shared by every mode.
+type options struct {
+	strict        bool
+	timeout       time.Duration
+	maxSteps      int64
+	maxDepth      int
+	fsDirs        dirList
+	allowTerminal bool
+	allowTime     bool
+	allowExec     bool
+}
+
+func (o *options) register(flags *flag.FlagSet) {
+	flags.BoolVar(&o.strict, "strict", false, "raise errors on out-of-range array, string and vector accesses")
+	flags.DurationVar(&o.timeout, "timeout", 0, "stop a run after this much time (0 means no limit)")
+	flags.Int64Var(&o.maxSteps, "max-steps", 0, "stop a run after evaluating this many nodes (0 means no limit)")
+	flags.IntVar(&o.maxDepth, "max-depth", 0, "maximum nesting of function calls (0 means no limit)")
+	flags.Var(&o.fsDirs, "allow-fs", "grant the fs capability for `dir` (may be repeated)")
+	flags.BoolVar(&o.allowTerminal, "allow-terminal", false, "grant the terminal capability")
+	flags.BoolVar(&o.allowTime, "allow-time", false, "grant the time capability")
+	flags.BoolVar(&o.allowExec, "allow-exec", false, "grant the exec capability")
+}
+
+// interpreter returns an interpreter configured by the flags.
+func (o *options) interpreter() (*ecs.Interpreter, error) {
+	interp := ecs.New()
+	interp.Runtime().Strict = o.strict
+	interp.Runtime().Limits = object.Limits{
+		Timeout:  o.timeout,
+		MaxSteps: o.maxSteps,
+		MaxDepth: o.maxDepth,
+	}
+	if len(o.fsDirs) > 0 {
+		if err := interp.AllowFS(o.fsDirs...); err != nil {
+			return nil, err
+		}
+	}
+	grants := map[object.Capability]bool{
+		object.TERMINAL_CAPABILITY: o.allowTerminal,
+		object.TIME_CAPABILITY:     o.allowTime,
+		object.EXEC_CAPABILITY:     o.allowExec,
+	}
+	for capability, granted := range grants {
+		if granted {
+			interp.Grant(capability)
+		}
+	}
+	return interp, nil
+}
+
+// dirList collects the values of a repeatable flag.
+type dirList []string
+
+func (d *dirList) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *dirList) Set(value string) error {
+	*d = append(*d, value)
+	return nil
+}
@@ -0,0 +1,361 @@
+// Package debugger is an interactive step debugger for ECS scripts. It
+// implements object.Debugger, stopping the program at breakpoints or after
+// a step and reading commands until told to go on.
+//
+//	d := debugger.New(os.Stdin, os.Stdout, source)
+//	interp.Runtime().Debugger = d
+//	interp.RunString(source)
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SpaceHexagon/ecs/ast"
+	"github.com/SpaceHexagon/ecs/evaluator"
+	"github.com/SpaceHexagon/ecs/lexer"
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/parser"
+)
+
+const PROMPT = "(debug) "
+
+// HELP lists the commands the debugger understands.
+const HELP = `break N, b N     stop before the statements on line N
+delete N, d N    remove the breakpoint on line N
+breakpoints      list breakpoints
+step, s          run to the next statement, entering calls
+next, n          run to the next statement in this function or its callers
+out, o           run until the current function returns
+continue, c      run to the next breakpoint
+stack, bt        show the call stack
+env, e           show the variables of each scope, innermost first
+this             show the object the current function was called on
+print EXPR, p    evaluate EXPR where the program stopped
+list, l          show the source around the current line
+quit, q          stop the program
+An empty line repeats the last step, next or out.
+`
+
+type mode int
+
+const (
+	running  mode = iota // stop only at breakpoints
+	stepping             // stop at the next statement
+	stepOver             // stop at the next statement at most target deep
+	stepOut              // stop at the next statement less than target deep
+)
+
+// frame is a function call in progress, or the top level of the program.
+// seen holds the statements run since the current line was entered, so a
+// statement that runs again, as in a loop, enters the line anew.
+type frame struct {
+	fn   *object.Function
+	line int
+	seen map[ast.Statement]bool
+	env  *object.Environment
+	this *object.Hash
+}
+
+func (f *frame) name() string {
+	if f.fn == nil {
+		return "main"
+	}
+	params := make([]string, len(f.fn.Parameters))
+	for i, p := range f.fn.Parameters {
+		params[i] = p.String()
+	}
+	return fmt.Sprintf("fn(%s) from line %d", strings.Join(params, ", "), f.fn.Body.Token.Line)
+}
+
+// Debugger stops the program it is attached to and reads commands from its
+// input. It starts in step mode, so the program stops at its first
+// statement. Once the input is exhausted the program runs to the end.
+//
+// Statements run by tasks started with spawn are reported like any other,
+// but the call stack is shared, so it is only accurate while a single task
+// is running.
+type Debugger struct {
+	mu          sync.Mutex
+	in          *bufio.Scanner
+	out         io.Writer
+	source      []string
+	breakpoints map[int]bool
+
+	mode   mode
+	target int
+	last   string
+	frames []*frame
+	quit   bool
+
+	// evaluating is set while print runs code, whose statements and calls
+	// are not reported.
+	evaluating bool
+}
+
+// New returns a debugger that reads commands from in, writes to out and
+// shows lines of source, the program being debugged.
+func New(in io.Reader, out io.Writer, source string) *Debugger {
+	return &Debugger{
+		in:          bufio.NewScanner(in),
+		out:         out,
+		source:      strings.Split(source, "\n"),
+		breakpoints: map[int]bool{},
+		mode:        stepping,
+		frames:      []*frame{{}},
+	}
+}
+
+// Break sets a breakpoint before the statements that start on line.
+func (d *Debugger) Break(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = true
+}
+
+// Continue makes the program run until it reaches a breakpoint instead of
+// stopping at its first statement.
+func (d *Debugger) Continue() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mode = running
+}
+
+func (d *Debugger) Call(fn *object.Function, env *object.Environment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.evaluating {
+		return
+	}
+	d.frames = append(d.frames, &frame{fn: fn, env: env})
+}
+
+func (d *Debugger) Return(fn *object.Function) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.frames) > 1 && !d.evaluating {
+		d.frames = d.frames[:len(d.frames)-1]
+	}
+}
+
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment, this *object.Hash) *object.Error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.quit {
+		return stopped()
+	}
+	if d.evaluating {
+		return nil
+	}
+	top := d.frames[len(d.frames)-1]
+	line := ast.Line(stmt)
+	entered := line != top.line || top.seen[stmt]
+	if entered {
+		top.seen = map[ast.Statement]bool{}
+	}
+	top.seen[stmt] = true
+	top.line, top.env, top.this = line, env, this
+
+	depth := len(d.frames)
+	switch {
+	case d.mode == stepping,
+		d.mode == stepOver && depth <= d.target,
+		d.mode == stepOut && depth < d.target:
+	case d.breakpoints[line] && entered:
+		fmt.Fprintf(d.out, "breakpoint at line %d\n", line)
+	default:
+		return nil
+	}
+	d.show(line)
+	return d.prompt()
+}
+
+// prompt reads commands until one of them resumes the program.
+func (d *Debugger) prompt() *object.Error {
+	for {
+		fmt.Fprint(d.out, PROMPT)
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			d.mode = running
+			d.breakpoints = map[int]bool{}
+			return nil
+		}
+		command, arg, _ := strings.Cut(strings.TrimSpace(d.in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		if command == "" {
+			command = d.last
+		}
+		switch command {
+		case "":
+		case "step", "s":
+			d.last, d.mode = command, stepping
+			return nil
+		case "next", "n":
+			d.last, d.mode, d.target = command, stepOver, len(d.frames)
+			return nil
+		case "out", "o":
+			d.last, d.mode, d.target = command, stepOut, len(d.frames)
+			return nil
+		case "continue", "c":
+			d.mode = running
+			return nil
+		case "quit", "q":
+			d.quit = true
+			return stopped()
+		case "break", "b":
+			if line, ok := d.lineArg(arg); ok {
+				d.breakpoints[line] = true
+				fmt.Fprintf(d.out, "breakpoint set at line %d\n", line)
+			}
+		case "delete", "d":
+			if line, ok := d.lineArg(arg); ok {
+				delete(d.breakpoints, line)
+			}
+		case "breakpoints":
+			d.listBreakpoints()
+		case "stack", "bt":
+			for i := len(d.frames) - 1; i >= 0; i-- {
+				f := d.frames[i]
+				fmt.Fprintf(d.out, "#%d line %d in %s\n", len(d.frames)-1-i, f.line, f.name())
+			}
+		case "env", "e":
+			d.showEnv()
+		case "this":
+			if this := d.frames[len(d.frames)-1].this; this != nil {
+				fmt.Fprintln(d.out, summary(this))
+			} else {
+				fmt.Fprintln(d.out, "null")
+			}
+		case "print", "p":
+			d.print(arg)
+		case "list", "l":
+			top := d.frames[len(d.frames)-1]
+			for line := top.line - 3; line <= top.line+3; line++ {
+				if line >= 1 && line <= len(d.source) {
+					marker := " "
+					if line == top.line {
+						marker = ">"
+					}
+					fmt.Fprintf(d.out, "%s%4d  %s\n", marker, line, d.source[line-1])
+				}
+			}
+		case "help", "h":
+			io.WriteString(d.out, HELP)
+		default:
+			fmt.Fprintf(d.out, "unknown command %q, try help\n", command)
+		}
+	}
+}
+
+// stopped is the error the program ends with after quit. It is returned
+// for every statement that follows, in case the first one is caught.
+func stopped() *object.Error {
+	return &object.Error{Message: "stopped by the debugger"}
+}
+
+func (d *Debugger) show(line int) {
+	text := ""
+	if line >= 1 && line <= len(d.source) {
+		text = strings.TrimSpace(d.source[line-1])
+	}
+	fmt.Fprintf(d.out, "line %d: %s\n", line, text)
+}
+
+func (d *Debugger) lineArg(arg string) (int, bool) {
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		fmt.Fprintf(d.out, "expected a line number, got %q\n", arg)
+		return 0, false
+	}
+	return line, true
+}
+
+func (d *Debugger) listBreakpoints() {
+	if len(d.breakpoints) == 0 {
+		fmt.Fprintln(d.out, "no breakpoints")
+		return
+	}
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	for _, line := range lines {
+		fmt.Fprintf(d.out, "line %d\n", line)
+	}
+}
+
+// showEnv prints the scopes of the current environment, from the innermost
+// to the global one.
+func (d *Debugger) showEnv() {
+	depth := 0
+	for env := d.frames[len(d.frames)-1].env; env != nil; env = env.Outer() {
+		scope := fmt.Sprintf("scope %d", depth)
+		if env.Outer() == nil {
+			scope = "global"
+		}
+		fmt.Fprintf(d.out, "%s:\n", scope)
+		for _, name := range env.Names() {
+			value, _ := env.Get(name)
+			fmt.Fprintf(d.out, "  %s = %s\n", name, summary(value))
+		}
+		depth++
+	}
+}
+
+// print evaluates source where the program stopped. The debugger ignores
+// the evaluation, so it does not stop at breakpoints. d.mu is released
+// meanwhile, since the evaluation reports its statements.
+func (d *Debugger) print(source string) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Fprintf(d.out, "parse errors: %s\n", strings.Join(p.Errors(), "; "))
+		return
+	}
+	top := d.frames[len(d.frames)-1]
+	if top.env == nil {
+		return
+	}
+	this := top.this
+	if this == nil {
+		this = &object.Hash{}
+	}
+	d.evaluating = true
+	result := func() object.Object {
+		d.mu.Unlock()
+		defer d.mu.Lock()
+		var result object.Object
+		for _, stmt := range program.Statements {
+			result = evaluator.Eval(stmt, top.env, this)
+			if _, ok := result.(*object.Error); ok {
+				break
+			}
+		}
+		return result
+	}()
+	d.evaluating = false
+	fmt.Fprintln(d.out, summary(result))
+}
+
+// summary inspects obj on one line, abbreviating functions and long values.
+func summary(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "null"
+	case *object.Function:
+		return (&frame{fn: obj}).name()
+	case *object.Error:
+		return "ERROR: " + obj.Message
+	}
+	text := strings.Join(strings.Fields(obj.Inspect()), " ")
+	if len(text) > 100 {
+		text = text[:97] + "..."
+	}
+	return text
+}
@@ -0,0 +1,132 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/SpaceHexagon/ecs/evaluator"
+	"github.com/SpaceHexagon/ecs/lexer"
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/parser"
+)
+
+const source = `let add = fn(a, b) {
+	let sum = a + b;
+	sum
+};
+let x = add(1, 2);
+let y = x * 2;
+print(y);`
+
+// debug runs src under a debugger fed with commands and returns what the
+// debugger and the program wrote.
+func debug(t *testing.T, src, commands string) (string, object.Object) {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.Runtime().Out = &out
+	env.Runtime().Debugger = New(strings.NewReader(commands), &out, src)
+	result := evaluator.Eval(program, env, nil)
+	return out.String(), result
+}
+
+func TestDebugger(t *testing.T) {
+	out, _ := debug(t, source, "b 2\nc\nbt\ne\np a + b\nn\n\n\nl\nc\n")
+	want := `line 1: let add = fn(a, b) {
+(debug) breakpoint set at line 2
+(debug) breakpoint at line 2
+line 2: let sum = a + b;
+(debug) #0 line 2 in fn(a, b) from line 1
+#1 line 5 in main
+(debug) scope 0:
+  a = 1
+  b = 2
+global:
+  add = fn(a, b) from line 1
+(debug) 3
+(debug) line 3: sum
+(debug) line 6: let y = x * 2;
+(debug) line 7: print(y);
+(debug)     4  };
+    5  let x = add(1, 2);
+    6  let y = x * 2;
+>   7  print(y);
+(debug) 6
+`
+	if out != want {
+		t.Errorf("wrong transcript.\ngot:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestDebuggerStepping(t *testing.T) {
+	out, _ := debug(t, source, "n\ns\ns\nout\nprint y\nprint x\ncontinue\n")
+	want := `line 1: let add = fn(a, b) {
+(debug) line 5: let x = add(1, 2);
+(debug) line 2: let sum = a + b;
+(debug) line 3: sum
+(debug) line 6: let y = x * 2;
+(debug) ERROR: identifier not found: y
+(debug) 3
+(debug) 6
+`
+	if out != want {
+		t.Errorf("wrong transcript.\ngot:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, result := debug(t, source, "q\n")
+	if err, ok := result.(*object.Error); !ok || err.Message != "stopped by the debugger" {
+		t.Errorf("expected the program to be stopped. got=%v", result)
+	}
+	if strings.Contains(out, "6") {
+		t.Errorf("program kept running after quit: %q", out)
+	}
+
+	// Without commands the program runs to the end.
+	if out, _ := debug(t, source, ""); out != "line 1: let add = fn(a, b) {\n(debug) \n6\n" {
+		t.Errorf("wrong output without commands. got=%q", out)
+	}
+}
+
+func TestDebuggerLoops(t *testing.T) {
+	src := "let i = 0;\nwhile (i < 3) {\n  let i = i + 1;\n}"
+	out, _ := debug(t, src, "b 3\nc\np i\nc\nc\n")
+	want := `line 1: let i = 0;
+(debug) breakpoint set at line 3
+(debug) breakpoint at line 3
+line 3: let i = i + 1;
+(debug) 0
+(debug) breakpoint at line 3
+line 3: let i = i + 1;
+(debug) breakpoint at line 3
+line 3: let i = i + 1;
+(debug) 
+`
+	if out != want {
+		t.Errorf("wrong transcript.\ngot:\n%s\nwant:\n%s", out, want)
+	}
+
+	// Statements on one line stop once, and print does not stop at
+	// breakpoints in the code it runs.
+	src = "let f = fn() { let a = 1; let b = 2; a + b };\nlet x = f();\nf()"
+	out, _ = debug(t, src, "b 1\nc\np f()\nbt\nc\n")
+	want = `line 1: let f = fn() { let a = 1; let b = 2; a + b };
+(debug) breakpoint set at line 1
+(debug) breakpoint at line 1
+line 1: let f = fn() { let a = 1; let b = 2; a + b };
+(debug) 3
+(debug) #0 line 1 in fn() from line 1
+#1 line 2 in main
+(debug) breakpoint at line 1
+line 1: let f = fn() { let a = 1; let b = 2; a + b };
+(debug) 
+`
+	if out != want {
+		t.Errorf("wrong transcript.\ngot:\n%s\nwant:\n%s", out, want)
+	}
+}
@@ -0,0 +1,324 @@
+// Package ecs embeds the Entity Control Script interpreter in Go programs.
+//
+//	interp := ecs.New()
+//	interp.Register("log", func(msg string) { log.Println(msg) })
+//	if _, err := interp.RunString(`let double = fn(x) { x * 2 }`); err != nil {
+//		return err
+//	}
+//	result, err := interp.Call("double", 21)
+//
+// Every Interpreter has its own globals and builtins, so several of them can
+// run in one process without affecting each other.
+package ecs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/builtins"
+	"github.com/SpaceHexagon/ecs/evaluator"
+	"github.com/SpaceHexagon/ecs/lexer"
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/parser"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+// ParseError reports the syntax errors found in a script.
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors:\n\t" + strings.Join(e.Errors, "\n\t")
+}
+
+// ErrPermissionDenied matches, via errors.Is, runtime errors raised because
+// a program used a capability it was not granted.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrLimitExceeded matches, via errors.Is, runtime errors raised because a
+// run exceeded one of the limits in Runtime().Limits or was cancelled.
+var ErrLimitExceeded = errors.New("execution limit exceeded")
+
+// RuntimeError is returned when a script evaluates to an error object.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Message
+}
+
+func (e *RuntimeError) Unwrap() error {
+	switch e.Err.Kind {
+	case object.LIMIT_ERROR:
+		return ErrLimitExceeded
+	case object.PERMISSION_ERROR:
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// Interpreter runs scripts against a persistent global environment.
+type Interpreter struct {
+	env *object.Environment
+}
+
+// New returns an interpreter with a fresh global environment and its own
+// copy of the default builtins and method tables.
+func New() *Interpreter {
+	env := object.NewEnvironment()
+	interp := &Interpreter{env: env}
+	env.Runtime().Builtins = builtins.New()
+	env.Runtime().Methods = builtins.Methods()
+	env.Runtime().Host = interp
+	return interp
+}
+
+// Environment returns the global environment scripts are evaluated in.
+func (i *Interpreter) Environment() *object.Environment {
+	return i.env
+}
+
+// Runtime returns the settings shared by everything the interpreter runs.
+func (i *Interpreter) Runtime() *object.Runtime {
+	return i.env.Runtime()
+}
+
+// Grant allows scripts to use the given capabilities. Builtins that need a
+// capability return a permission error until it is granted.
+func (i *Interpreter) Grant(capabilities ...object.Capability) {
+	i.env.Runtime().Grant(capabilities...)
+}
+
+// AllowFS grants the fs capability limited to the given directories.
+// Relative script paths are resolved against the first directory.
+func (i *Interpreter) AllowFS(dirs ...string) error {
+	runtime := i.env.Runtime()
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		runtime.FSRoots = append(runtime.FSRoots, abs)
+	}
+	runtime.Grant(object.FS_CAPABILITY)
+	return nil
+}
+
+// SetOutput sets the writer print and printf write to.
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.env.Runtime().Out = w
+}
+
+// SetErrorOutput sets the writer eprint writes to.
+func (i *Interpreter) SetErrorOutput(w io.Writer) {
+	i.env.Runtime().Err = w
+}
+
+// SetInput sets the reader readLine and input read from.
+func (i *Interpreter) SetInput(r io.Reader) {
+	i.env.Runtime().In = r
+}
+
+// RunString evaluates source in the global environment and returns the value
+// of the last statement, which is nil for statements without a value such as
+// let.
+func (i *Interpreter) RunString(source string) (object.Object, error) {
+	return i.RunStringContext(context.Background(), source)
+}
+
+// RunStringContext is like RunString but stops evaluation with a limit error
+// once ctx is done. Each call is one run for the purposes of Runtime().Limits.
+func (i *Interpreter) RunStringContext(ctx context.Context, source string) (object.Object, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+	defer i.env.Runtime().BeginRun(ctx)()
+	return result(evaluator.Eval(program, i.env, nil))
+}
+
+// RunFile reads and evaluates the script at path. The source is read
+// through the journal, so a replay runs the script that was recorded.
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	return i.RunFileContext(context.Background(), path)
+}
+
+// RunFileContext is like RunFile but stops evaluation with a limit error
+// once ctx is done.
+func (i *Interpreter) RunFileContext(ctx context.Context, path string) (object.Object, error) {
+	source := i.Journaled("file", func() object.Object {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return &object.String{Value: string(data)}
+	})
+	if err, ok := source.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	text, ok := source.(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("journal returned %s for %s", source.Type(), path)
+	}
+	obj, err := i.RunStringContext(ctx, text.Value)
+	if parseErr, ok := err.(*ParseError); ok {
+		return nil, fmt.Errorf("%s: %w", path, parseErr)
+	}
+	return obj, err
+}
+
+// Call calls the global function name. Arguments are converted with
+// util.ToObject.
+func (i *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call but stops the call once ctx is done.
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (object.Object, error) {
+	fn, ok := i.GetGlobal(name)
+	if !ok {
+		return nil, fmt.Errorf("undefined function %s", name)
+	}
+	objects := make([]object.Object, len(args))
+	for idx, arg := range args {
+		obj, err := toObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objects[idx] = obj
+	}
+	defer i.env.Runtime().BeginRun(ctx)()
+	return result(evaluator.ApplyFunction(fn, objects, i.env))
+}
+
+// Tick advances the default world, and with it the clock that after and
+// every are scheduled on, by dt. Systems, physics, due timers, events and
+// coroutines run before it returns.
+func (i *Interpreter) Tick(dt time.Duration) error {
+	return i.TickContext(context.Background(), dt)
+}
+
+// TickContext is like Tick but stops once ctx is done.
+func (i *Interpreter) TickContext(ctx context.Context, dt time.Duration) error {
+	world, ok := i.env.Runtime().Builtins["world"]
+	if !ok {
+		return fmt.Errorf("interpreter has no world")
+	}
+	tick, ok := builtins.LookupMethod(i.env.Runtime(), world, "tick")
+	if !ok {
+		return fmt.Errorf("%s has no tick method", world.Type())
+	}
+	defer i.env.Runtime().BeginRun(ctx)()
+	_, err := result(evaluator.ApplyFunction(tick, []object.Object{&object.Float{Value: dt.Seconds()}}, i.env))
+	return err
+}
+
+// PressKey reports that key went down. Like all input it takes effect at
+// the start of the next tick, and it may be called from any goroutine.
+func (i *Interpreter) PressKey(key string) error {
+	input, err := i.input()
+	if err != nil {
+		return err
+	}
+	input.Press(key)
+	return nil
+}
+
+// ReleaseKey reports that key went up.
+func (i *Interpreter) ReleaseKey(key string) error {
+	input, err := i.input()
+	if err != nil {
+		return err
+	}
+	input.Release(key)
+	return nil
+}
+
+// ReplayInput feeds a recorded input stream, in the format written by
+// builtins.WriteInputEvents, into the ticks that follow.
+func (i *Interpreter) ReplayInput(r io.Reader) error {
+	input, err := i.input()
+	if err != nil {
+		return err
+	}
+	events, err := builtins.ReadInputEvents(r)
+	if err != nil {
+		return err
+	}
+	input.Replay(i.world().Frame(), events)
+	return nil
+}
+
+func (i *Interpreter) world() *builtins.World {
+	world, _ := i.env.Runtime().Builtins["world"].(*builtins.World)
+	return world
+}
+
+func (i *Interpreter) input() (*builtins.Input, error) {
+	if world := i.world(); world != nil && world.Input() != nil {
+		return world.Input(), nil
+	}
+	return nil, fmt.Errorf("interpreter has no input")
+}
+
+// SetGlobal defines a global variable. The value is converted with
+// util.ToObject, so Go values, structs and funcs can be passed directly.
+// Values that cannot be converted are reported and leave the global unset.
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := toObject(value)
+	if err != nil {
+		return err
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// GetGlobal looks up a global variable or builtin. Use util.FromObject to
+// convert the result into a Go value.
+func (i *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	if obj, ok := i.env.Get(name); ok {
+		return obj, true
+	}
+	obj, ok := i.env.Runtime().Builtins[name]
+	return obj, ok
+}
+
+// RegisterBuiltin adds a builtin that is visible only to this interpreter.
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.env.Runtime().Builtins[name] = &object.Builtin{Fn: fn}
+}
+
+// Register adds a Go func, or any other value, as a builtin of this
+// interpreter. Funcs are wrapped with util.ToObject.
+func (i *Interpreter) Register(name string, value interface{}) error {
+	obj, err := toObject(value)
+	if err != nil {
+		return err
+	}
+	i.env.Runtime().Builtins[name] = obj
+	return nil
+}
+
+func toObject(value interface{}) (object.Object, error) {
+	obj := util.ToObject(value)
+	if err, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	return obj, nil
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return obj, &RuntimeError{Err: err}
+	}
+	return obj, nil
+}
@@ -0,0 +1,797 @@
+package ecs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/builtins"
+	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/util"
+)
+
+func TestRunStringAndCall(t *testing.T) {
+	interp := New()
+	if _, err := interp.RunString(`let greet = fn(name, hp) { format("{} has {} hp", name, hp) }`); err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	result, err := interp.Call("greet", "Goblin", 50)
+	if err != nil {
+		t.Fatalf("Call returned error: %s", err)
+	}
+	if result.Inspect() != "Goblin has 50 hp" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+	if _, err := interp.Call("missing"); err == nil {
+		t.Errorf("expected error calling undefined function")
+	}
+	if _, err := interp.Call("greet", "Goblin"); err == nil || err.Error() != "wrong number of arguments. got=1, want=2" {
+		t.Errorf("expected arity error. got=%v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	interp := New()
+	_, err := interp.RunString("let = 5")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || len(parseErr.Errors) == 0 {
+		t.Errorf("expected ParseError. got=%v", err)
+	}
+	_, err = interp.RunString("1 + true")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("expected RuntimeError. got=%v", err)
+	}
+}
+
+func TestGlobalsAndBuiltins(t *testing.T) {
+	type Enemy struct {
+		Name string `ecs:"name"`
+		HP   int    `ecs:"hp"`
+	}
+	interp := New()
+	interp.SetGlobal("enemy", Enemy{Name: "Orc", HP: 10})
+	interp.Register("heal", func(hp, amount int) int { return hp + amount })
+	interp.RegisterBuiltin("answer", func(ctx *object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	})
+	if _, err := interp.RunString(`enemy.hp = heal(enemy.hp, answer())`); err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	obj, ok := interp.GetGlobal("enemy")
+	if !ok {
+		t.Fatalf("global enemy not found")
+	}
+	var enemy Enemy
+	if err := util.FromObject(obj, &enemy); err != nil {
+		t.Fatalf("FromObject returned error: %s", err)
+	}
+	if enemy.HP != 52 || enemy.Name != "Orc" {
+		t.Errorf("wrong enemy. got=%+v", enemy)
+	}
+
+	var runtimeErr *RuntimeError
+	err := interp.SetGlobal("feed", make(chan int))
+	if !errors.As(err, &runtimeErr) || err.Error() != "cannot convert chan int to an object" {
+		t.Errorf("expected RuntimeError from SetGlobal. got=%v", err)
+	}
+	if _, ok := interp.GetGlobal("feed"); ok {
+		t.Errorf("global feed was set from an unconvertible value")
+	}
+	err = interp.Register("feed", make(chan int))
+	if !errors.As(err, &runtimeErr) || err.Error() != "cannot convert chan int to an object" {
+		t.Errorf("expected RuntimeError from Register. got=%v", err)
+	}
+	if _, ok := interp.GetGlobal("feed"); ok {
+		t.Errorf("builtin feed was registered from an unconvertible value")
+	}
+}
+
+func TestHostBooleans(t *testing.T) {
+	interp := New()
+	interp.SetGlobal("yes", true)
+	interp.SetGlobal("no", false)
+	interp.SetGlobal("nothing", nil)
+	interp.Register("isEven", func(n int) bool { return n%2 == 0 })
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`if (no) { 1 } else { 2 }`, "2"},
+		{`if (nothing) { 1 } else { 2 }`, "2"},
+		{`[yes == true, no == false, nothing == null, yes != false, no == null]`, "[true, true, true, true, false]"},
+		{`[!yes, !no, !nothing, !!isEven(2)]`, "[false, true, true, true]"},
+	}
+	for _, tt := range tests {
+		result, err := interp.RunString(tt.input)
+		if err != nil {
+			t.Errorf("RunString(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestInterpretersAreIsolated(t *testing.T) {
+	a, b := New(), New()
+	a.RegisterBuiltin("only", func(ctx *object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: 1}
+	})
+	if _, err := a.RunString(`let x = 1; Math.extra = 5`); err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	if _, ok := b.GetGlobal("x"); ok {
+		t.Errorf("global leaked between interpreters")
+	}
+	if _, err := b.RunString("only()"); err == nil {
+		t.Errorf("builtin leaked between interpreters")
+	}
+	result, _ := b.RunString("Math.extra")
+	if result.Type() != object.NULL_OBJ {
+		t.Errorf("builtin module mutation leaked between interpreters. got=%s", result.Inspect())
+	}
+	builtins.RegisterMethod(a.Runtime(), object.INTEGER_OBJ, "double", func(ctx *object.CallContext, receiver object.Object, args ...object.Object) object.Object {
+		return &object.Integer{Value: receiver.(*object.Integer).Value * 2}
+	})
+	if result, err := a.RunString("(21).double()"); err != nil || result.Inspect() != "42" {
+		t.Errorf("registered method not found. got=%v err=%v", result, err)
+	}
+	if _, err := b.RunString("(21).double()"); err == nil {
+		t.Errorf("method leaked between interpreters")
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.ecs")
+	if err := os.WriteFile(path, []byte("let a = [1, 2, 3];\na.len() * 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := New().RunFile(path)
+	if err != nil {
+		t.Fatalf("RunFile returned error: %s", err)
+	}
+	if result.Inspect() != "6" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	if err := os.WriteFile(path, []byte("while (true) {}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := New().RunFileContext(ctx, path); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected RunFileContext to stop once ctx is done. got=%v", err)
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	interp := New()
+	var out bytes.Buffer
+	interp.Runtime().Out = &out
+	interp.RegisterBuiltin("each", func(ctx *object.CallContext, args ...object.Object) object.Object {
+		if ctx.Interpreter != interp {
+			t.Errorf("wrong interpreter handle. got=%v", ctx.Interpreter)
+		}
+		for _, element := range args[0].(*object.Array).Elements {
+			if result := ctx.Call(args[1], element); result.Type() == object.ERROR_OBJ {
+				return result
+			}
+		}
+		return &object.Null{}
+	})
+	interp.RegisterBuiltin("self", func(ctx *object.CallContext, args ...object.Object) object.Object {
+		if ctx.This == nil {
+			return &object.Null{}
+		}
+		return ctx.This
+	})
+	_, err := interp.RunString(`each([1, 2], fn(x) { print(x * 10) }); let o = {"name": "o", "self": self}; print(o.self().name)`)
+	if err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	if out.String() != "10\n20\no\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestStreams(t *testing.T) {
+	interp := New()
+	var out, errOut bytes.Buffer
+	interp.SetOutput(&out)
+	interp.SetErrorOutput(&errOut)
+	interp.SetInput(strings.NewReader("Goblin\r\n12\n"))
+	_, err := interp.RunString(`
+		let name = input("name? ")
+		let hp = readLine()
+		printf("%s has %s hp, %.1f%% %v", name, hp, 50.0, [1])
+		eprint("warning")
+		print(readLine() == null)
+	`)
+	if err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	if out.String() != "name? Goblin has 12 hp, 50.0% [1]true\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+	if errOut.String() != "warning\n" {
+		t.Errorf("wrong error output. got=%q", errOut.String())
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		limits   object.Limits
+		input    string
+		expected string
+	}{
+		{object.Limits{MaxSteps: 1000}, `while (true) {}`, "step limit of 1000 exceeded"},
+		{object.Limits{Timeout: 20 * time.Millisecond}, `while (true) {}`, "timeout of 20ms exceeded"},
+		{object.Limits{Timeout: 20 * time.Millisecond}, `sleep(10000) {}`, "timeout of 20ms exceeded"},
+		{object.Limits{MaxDepth: 50}, `let f = fn(n) { f(n + 1) }; f(0)`, "call depth limit of 50 exceeded"},
+		{object.Limits{MaxAllocations: 100}, `let a = []; for (i, 1000) { a.push([i]) }`, "allocation limit of 100 objects exceeded"},
+		{object.Limits{MaxAllocations: 100}, `Grid.create(10, 10)`, "allocation limit of 100 objects exceeded"},
+		{object.Limits{MaxAllocations: 100}, `chan(1000)`, "allocation limit of 100 objects exceeded"},
+		{object.Limits{MaxAllocations: 100}, `repeat("ab", 1000)`, "allocation limit of 100 objects exceeded"},
+		{object.Limits{MaxAllocations: 100}, `Grid.parse(repeat(repeat("#", 10) + "\n", 10))`, "allocation limit of 100 objects exceeded"},
+	}
+	for _, tt := range tests {
+		interp := New()
+		interp.Runtime().Limits = tt.limits
+		_, err := interp.RunString(tt.input)
+		if !errors.Is(err, ErrLimitExceeded) || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	interp := New()
+	interp.Runtime().Limits = object.Limits{MaxSteps: 1000}
+	for i := 0; i < 3; i++ {
+		if _, err := interp.RunString(`for (i, 50) { i }`); err != nil {
+			t.Fatalf("step budget not reset between runs: %s", err)
+		}
+	}
+	if _, err := interp.RunString(`1 + true`); errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("ordinary errors must not match ErrLimitExceeded")
+	}
+}
+
+func TestTasksEndWithTheirRun(t *testing.T) {
+	for _, limits := range []object.Limits{{Timeout: 50 * time.Millisecond}, {}} {
+		interp := New()
+		interp.Runtime().Limits = limits
+		start := time.Now()
+		if _, err := interp.RunString(`let t = spawn fn() { while (true) {} }; 1`); err != nil {
+			t.Fatalf("RunString returned error: %s", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("run took %s to stop its task", elapsed)
+		}
+		if result, err := interp.RunString(`t.done()`); err != nil || result.Inspect() != "true" {
+			t.Errorf("task still running after its run. got=%v err=%v", result, err)
+		}
+		_, err := interp.RunString(`await(t)`)
+		if !errors.Is(err, ErrLimitExceeded) || err.Error() != "execution cancelled: context canceled" {
+			t.Errorf("wrong task error. got=%v", err)
+		}
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	interp := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	_, err := interp.RunStringContext(ctx, `while (true) { sleep(5) {} }`)
+	if !errors.Is(err, ErrLimitExceeded) || err.Error() != "execution cancelled: context canceled" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancellation took too long: %s", elapsed)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	denied := []string{`time()`, `timeMs()`, `monotonic()`, `fs.read("a.txt")`, `terminal.clear()`, `exec "echo hi"`}
+	for _, input := range denied {
+		if _, err := New().RunString(input); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("expected permission error for %q. got=%v", input, err)
+		}
+	}
+
+	interp := New()
+	interp.Grant(object.TIME_CAPABILITY, object.TERMINAL_CAPABILITY)
+	var out bytes.Buffer
+	interp.SetOutput(&out)
+	if _, err := interp.RunString(`time(); terminal.color("red")`); err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	if out.String() != "\x1b[31m" {
+		t.Errorf("wrong terminal output. got=%q", out.String())
+	}
+	result, err := interp.RunString(`let a = monotonic(); let b = monotonic(); [timeMs() / 1000 < time(), b < a]`)
+	if err != nil || result.Inspect() != "[false, false]" {
+		t.Errorf("wrong clock result. got=%v err=%v", result, err)
+	}
+
+	interp.Grant(object.EXEC_CAPABILITY)
+	result, err = interp.RunString(`exec "echo hi"`)
+	if err != nil || result.Inspect() != "hi\n" {
+		t.Errorf("wrong exec result. got=%v err=%v", result, err)
+	}
+}
+
+func TestFSCapability(t *testing.T) {
+	dir := t.TempDir()
+	interp := New()
+	if err := interp.AllowFS(dir); err != nil {
+		t.Fatalf("AllowFS returned error: %s", err)
+	}
+	result, err := interp.RunString(`
+		fs.mkdir("saves")
+		fs.write("saves/slot1.txt", "hp=5")
+		fs.append("saves/slot1.txt", ";gold=2")
+		let result = [fs.read("saves/slot1.txt"), fs.list("saves"), fs.exists("missing")]
+		result
+	`)
+	if err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	if result.Inspect() != "[hp=5;gold=2, [slot1.txt], false]" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+	data, readErr := os.ReadFile(filepath.Join(dir, "saves", "slot1.txt"))
+	if readErr != nil || string(data) != "hp=5;gold=2" {
+		t.Errorf("file not written to granted directory. got=%q err=%v", data, readErr)
+	}
+
+	outside := filepath.Join(filepath.Dir(dir), "outside.txt")
+	if err := os.Symlink(filepath.Dir(dir), filepath.Join(dir, "link")); err != nil {
+		t.Fatalf("cannot create symlink: %s", err)
+	}
+	escapes := []string{`fs.read("../outside.txt")`, `fs.write("` + outside + `", "x")`, `fs.write("link/outside.txt", "x")`}
+	for _, input := range escapes {
+		if _, err := interp.RunString(input); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("expected permission error for %q. got=%v", input, err)
+		}
+	}
+	if _, statErr := os.Stat(outside); statErr == nil {
+		t.Errorf("file written outside the granted directory")
+	}
+}
+
+func TestTimers(t *testing.T) {
+	interp := New()
+	_, err := interp.RunString(`
+		let log = []
+		after(250, fn() { log.push("once") })
+		let id = every(100, fn() { log.push(world.time()) })
+		after(350, fn() { cancel(id) })
+		after(0, fn() { log.push("zero"); after(0, fn() { log.push("next tick") }) })
+	`)
+	if err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	if err := interp.Tick(300 * time.Millisecond); err != nil {
+		t.Fatalf("Tick returned error: %s", err)
+	}
+	log, _ := interp.GetGlobal("log")
+	if log.Inspect() != "[zero, 0.300000, 0.300000, once, 0.300000]" {
+		t.Errorf("wrong log after first tick. got=%s", log.Inspect())
+	}
+	if err := interp.Tick(time.Second); err != nil {
+		t.Fatalf("Tick returned error: %s", err)
+	}
+	if log.Inspect() != "[zero, 0.300000, 0.300000, once, 0.300000, next tick]" {
+		t.Errorf("wrong log after second tick. got=%s", log.Inspect())
+	}
+
+	interp.RunString(`after(0, fn() { 1 + true })`)
+	err = interp.Tick(0)
+	if err == nil || err.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("expected timer error from Tick. got=%v", err)
+	}
+}
+
+func TestEventHandlerErrors(t *testing.T) {
+	interp := New()
+	_, err := interp.RunString(`
+		let log = []
+		world.on("bad", fn(e) { 1 + true })
+		world.on("bad", fn(e) { log.push("second") })
+		world.on("next", fn(e) { log.push("next") })
+		world.emit("bad")
+		world.emit("next")
+	`)
+	if err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	err = interp.Tick(0)
+	if err == nil || err.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("expected handler error from Tick. got=%v", err)
+	}
+	log, _ := interp.GetGlobal("log")
+	if log.Inspect() != "[second]" {
+		t.Errorf("other handlers of the failed event did not run. got=%s", log.Inspect())
+	}
+	if err := interp.Tick(0); err != nil {
+		t.Fatalf("Tick returned error: %s", err)
+	}
+	if log.Inspect() != "[second, next]" {
+		t.Errorf("events after the failed one were not delivered. got=%s", log.Inspect())
+	}
+}
+
+func TestRunLoop(t *testing.T) {
+	interp := New()
+	_, err := interp.RunString(`
+		let alphas = []
+		world.system(fn(dt, w) { if (w.frame() == 30) { w.stop() } })
+		world.render(fn(alpha) { alphas.push(alpha) })
+	`)
+	if err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	stats, err := interp.RunLoop(context.Background(), LoopConfig{Step: 10 * time.Millisecond, Frames: 100})
+	if err != nil {
+		t.Fatalf("RunLoop returned error: %s", err)
+	}
+	if stats.Frames != 30 || stats.Ticks != 30 {
+		t.Errorf("wrong stats. got=%+v", stats)
+	}
+	alphas, _ := interp.GetGlobal("alphas")
+	if n := len(alphas.(*object.Array).Elements); n != 30 {
+		t.Errorf("render called %d times, want 30", n)
+	}
+	if result, _ := interp.RunString(`world.time()`); result.Inspect() != "0.300000" {
+		t.Errorf("wrong world time. got=%s", result.Inspect())
+	}
+	stats, err = interp.RunLoop(context.Background(), LoopConfig{Step: 10 * time.Millisecond, Frames: 5})
+	if err != nil {
+		t.Fatalf("RunLoop returned error: %s", err)
+	}
+	if stats.Frames != 5 {
+		t.Errorf("a stopped world should run again in the next loop. got=%+v", stats)
+	}
+
+	interp = New()
+	interp.RunString(`world.system(fn(dt, w) { while (true) {} })`)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := interp.RunLoop(ctx, LoopConfig{}); err != nil {
+		t.Errorf("cancelling the loop should not be an error. got=%s", err)
+	}
+
+	interp = New()
+	interp.RunString(`world.render(fn(alpha) { 1 + true })`)
+	if _, err := interp.RunLoop(context.Background(), LoopConfig{Frames: 1}); err == nil {
+		t.Errorf("expected render error")
+	}
+}
+
+func TestWorldSaveLoad(t *testing.T) {
+	for _, format := range []string{"json", "binary"} {
+		dir := t.TempDir()
+		interp := New()
+		if err := interp.AllowFS(dir); err != nil {
+			t.Fatal(err)
+		}
+		_, err := interp.RunString(`
+			Component.define("Health", {"hp": 10})
+			Component.define("Position", {"x": 0.0, "y": 0.0})
+			let a = world.spawn({"Health": {"hp": 4}, "Position": {"x": 1.5}})
+			let b = world.spawn({"Position": {"tags": ["a", [1, null, true]]}})
+			world.spawn().destroy()
+			b.setParent(a)
+			world.state().level = "cave"
+			world.tick(0.25)
+			world.save("save.dat", "` + format + `")
+		`)
+		if err != nil {
+			t.Fatalf("%s: RunString returned error: %s", format, err)
+		}
+
+		// A second interpreter with a newer Health schema loads the save.
+		loader := New()
+		loader.AllowFS(dir)
+		result, err := loader.RunString(`
+			Component.define("Health", {"hp": 10, "max": 100}, 2)
+			Component.migrate("Health", fn(data, version) { data.hp = data.hp * 10; data })
+			let w = World.load("save.dat")
+			let a = w.entity(1)
+			let c = w.spawn()
+			let result = [len(w.entities()), a.get("Health").hp, a.get("Health").max, a.get("Position").x, w.entity(2).get("Position").tags, w.state().level, w.time(), w.frame(), c.id(), w.entity(2).parent().id()]
+			result
+		`)
+		if err != nil {
+			t.Fatalf("%s: RunString returned error: %s", format, err)
+		}
+		expected := "[3, 40, 100, 1.500000, [a, [1, null, true]], cave, 0.250000, 1, 4, 1]"
+		if result.Inspect() != expected {
+			t.Errorf("%s: wrong loaded world. got=%s", format, result.Inspect())
+		}
+	}
+
+	dir := t.TempDir()
+	interp := New()
+	interp.AllowFS(dir)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`world.spawn({"AI": {"think": fn() { 1 }}}); world.save("bad.json")`, "cannot save component AI: cannot encode FUNCTION as JSON"},
+		{`world.save("w.json", "xml")`, `unknown world format "xml", want "json" or "binary"`},
+		{`fs.write("junk.json", "{}"); World.load("junk.json")`, "not a world file"},
+		{`fs.write("junk.dat", "ECSW"); World.load("junk.dat")`, "corrupt world file: malformed data"},
+		{`Component.define("Old", {}, 1); World.create().spawn({"Old": {}}).world().save("old.json"); Component.define("Old", {}, 0)`, "third argument to `Component.define` must be a positive INTEGER, got 0"},
+	}
+	for _, tt := range tests {
+		_, err := interp.RunString(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. got=%v, want=%q", tt.input, err, tt.expected)
+		}
+	}
+	if _, err := New().RunString(`world.save("x.json")`); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected permission error for save. got=%v", err)
+	}
+}
+
+func TestPrefabLoad(t *testing.T) {
+	dir := t.TempDir()
+	data := `{
+		"Goblin": {"components": {"Health": {"hp": 30}}, "children": ["Club"]},
+		"Club": {"components": {"Weapon": {"damage": 2}}}
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "prefabs.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	interp := New()
+	if _, err := interp.RunString(`Prefab.load("prefabs.json")`); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected permission error. got=%v", err)
+	}
+	interp.AllowFS(dir)
+	result, err := interp.RunString(`
+		let names = Prefab.load("prefabs.json")
+		let g = world.spawn("Goblin", {"Health": {"hp": 50}})
+		let result = [names, g.get("Health").hp, g.children()[0].get("Weapon").damage]
+		result
+	`)
+	if err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	if result.Inspect() != "[[Club, Goblin], 50, 2]" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestGridLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"level.txt": "S.#\n..#\n..G\n",
+		"costs.csv": "1,1,4\n1,9,1\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	interp := New()
+	if _, err := interp.RunString(`Grid.load("level.txt")`); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected permission error. got=%v", err)
+	}
+	interp.AllowFS(dir)
+	result, err := interp.RunString(`
+		let level = Grid.load("level.txt")
+		let costs = Grid.load("costs.csv")
+		let result = [level, level.get(2, 2), costs, costs.get(2, 0), len(level.astar(0, 0, 2, 2))]
+		result
+	`)
+	if err != nil {
+		t.Fatalf("RunString returned error: %s", err)
+	}
+	if result.Inspect() != "[grid(3x3), G, grid(3x2), 4, 5]" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestInputReplay(t *testing.T) {
+	stream := "# tick event key\n0 press right\n2 release right\n2 press space\n3 release space\n"
+	events, err := builtins.ReadInputEvents(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("ReadInputEvents returned error: %s", err)
+	}
+	var written strings.Builder
+	if err := builtins.WriteInputEvents(&written, events); err != nil {
+		t.Fatal(err)
+	}
+	if want := "0 press right\n2 release right\n2 press space\n3 release space\n"; written.String() != want {
+		t.Errorf("wrong stream written. got=%q, want=%q", written.String(), want)
+	}
+	if _, err := builtins.ReadInputEvents(strings.NewReader("1 hold space\n")); err == nil || err.Error() != `line 1: unknown event "hold"` {
+		t.Errorf("expected unknown event error. got=%v", err)
+	}
+
+	script := `
+		input.bind("right", ["right", "d"])
+		input.bind("jump", "space")
+		let player = {"x": 0, "jumps": 0}
+		world.system(fn(dt, w) {
+			if (input.isDown("right")) { player.x = player.x + 1 }
+			if (input.justPressed("jump")) { player.jumps = player.jumps + 1 }
+		})
+	`
+	play := func(replay func(interp *Interpreter) error) string {
+		interp := New()
+		if _, err := interp.RunString(script); err != nil {
+			t.Fatalf("RunString returned error: %s", err)
+		}
+		if err := replay(interp); err != nil {
+			t.Fatalf("replay returned error: %s", err)
+		}
+		for i := 0; i < 5; i++ {
+			if err := interp.Tick(time.Second / 60); err != nil {
+				t.Fatalf("Tick returned error: %s", err)
+			}
+		}
+		result, err := interp.RunString(`[player.x, player.jumps]`)
+		if err != nil {
+			t.Fatalf("RunString returned error: %s", err)
+		}
+		return result.Inspect()
+	}
+
+	fromGo := play(func(interp *Interpreter) error {
+		return interp.ReplayInput(strings.NewReader(stream))
+	})
+	if fromGo != "[2, 1]" {
+		t.Errorf("wrong result replaying from Go. got=%s", fromGo)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "keys.txt"), []byte(stream), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fromScript := play(func(interp *Interpreter) error {
+		if err := interp.AllowFS(dir); err != nil {
+			return err
+		}
+		_, err := interp.RunString(`input.replay("keys.txt")`)
+		return err
+	})
+	if fromScript != fromGo {
+		t.Errorf("replay from a script differs. got=%s, want=%s", fromScript, fromGo)
+	}
+
+	live := play(func(interp *Interpreter) error {
+		return interp.PressKey("d")
+	})
+	if live != "[5, 0]" {
+		t.Errorf("wrong result for a held key. got=%s", live)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	script := `
+		let config = json.parse(fs.read("config.json"))
+		input.bind("jump", "space")
+		let rng = Math.Random()
+		let state = {"jumps": [], "rolls": [Math.randomInt(1000), rng.randomInt(1000)], "started": time()}
+		world.system(fn(dt, w) {
+			if (input.justPressed("jump")) { state.jumps = push(state.jumps, [w.frame(), Math.random()]) }
+		})
+	`
+	play := func(dir string, journal object.Journal, keys bool) string {
+		interp := New()
+		interp.Grant(object.TIME_CAPABILITY)
+		if err := interp.AllowFS(dir); err != nil {
+			t.Fatalf("AllowFS returned error: %s", err)
+		}
+		interp.SetJournal(journal)
+		if _, err := interp.RunString(script); err != nil {
+			t.Fatalf("RunString returned error: %s", err)
+		}
+		for i := 0; i < 6; i++ {
+			if keys && i%2 == 1 {
+				interp.PressKey("space")
+			} else if keys {
+				interp.ReleaseKey("space")
+			}
+			if err := interp.Tick(time.Second / 60); err != nil {
+				t.Fatalf("Tick returned error: %s", err)
+			}
+		}
+		result, err := interp.RunString(`[config.level, state.rolls, state.started, state.jumps]`)
+		if err != nil {
+			t.Fatalf("RunString returned error: %s", err)
+		}
+		return result.Inspect()
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"level": 3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	recorded := play(dir, NewRecorder(&log), true)
+
+	// The replay sees the recorded file, seeds and keys although the file
+	// is gone and no keys are pressed.
+	replayer, err := NewReplayer(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayer returned error: %s", err)
+	}
+	replayed := play(t.TempDir(), replayer, false)
+	if replayed != recorded {
+		t.Errorf("replay differs from the recorded run.\nrecorded=%s\nreplayed=%s", recorded, replayed)
+	}
+	if !strings.HasPrefix(recorded, "[3, [") || !strings.Contains(recorded, "[[2, ") || !strings.Contains(recorded, "], [6, ") {
+		t.Errorf("wrong recorded result. got=%s", recorded)
+	}
+	if replayer.Remaining() != 0 {
+		t.Errorf("replay left %d entries", replayer.Remaining())
+	}
+
+	replayer, err = NewReplayer(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayer returned error: %s", err)
+	}
+	interp := New()
+	interp.Grant(object.TIME_CAPABILITY)
+	interp.SetJournal(replayer)
+	_, err = interp.RunString(`time()`)
+	if err == nil || err.Error() != "replay diverged at entry 1: expected file, got time" {
+		t.Errorf("expected divergence error. got=%v", err)
+	}
+
+	if _, err := NewReplayer(strings.NewReader("{\"value\": 1}\n")); err == nil || err.Error() != "line 1: entry has no kind" {
+		t.Errorf("expected invalid log error. got=%v", err)
+	}
+}
+
+func TestReplayEndsWhereTheRecordedLoopStopped(t *testing.T) {
+	loop := func(ctx context.Context, journal object.Journal) (LoopStats, string) {
+		interp := New()
+		interp.SetJournal(journal)
+		if _, err := interp.RunString(`let n = {"ticks": 0}; world.system(fn(dt, w) { n.ticks = n.ticks + 1 })`); err != nil {
+			t.Fatalf("RunString returned error: %s", err)
+		}
+		stats, err := interp.RunLoop(ctx, LoopConfig{Step: 5 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("RunLoop returned error: %s", err)
+		}
+		result, err := interp.RunString(`n.ticks`)
+		if err != nil {
+			t.Fatalf("RunString returned error: %s", err)
+		}
+		return stats, result.Inspect()
+	}
+
+	var log bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	recordedStats, recorded := loop(ctx, NewRecorder(&log))
+	if !strings.HasSuffix(log.String(), "{\"kind\":\"stop\",\"value\":null}\n") {
+		t.Errorf("log does not end with a stop entry. got=%s", log.String())
+	}
+
+	replayer, err := NewReplayer(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayer returned error: %s", err)
+	}
+	replayedStats, replayed := loop(context.Background(), replayer)
+	if replayed != recorded || replayedStats.Frames != recordedStats.Frames || replayedStats.Ticks != recordedStats.Ticks {
+		t.Errorf("replay differs from the recorded run. recorded=%s %+v, replayed=%s %+v", recorded, recordedStats, replayed, replayedStats)
+	}
+	if replayer.Remaining() != 0 {
+		t.Errorf("replay left %d entries", replayer.Remaining())
+	}
+}
@@ -3,27 +3,45 @@ package evaluator
 import (
 	"fmt"
 	"hash/fnv"
+	"os/exec"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/SpaceHexagon/ecs/util"
 
 	"github.com/SpaceHexagon/ecs/ast"
 	"github.com/SpaceHexagon/ecs/builtins"
 	"github.com/SpaceHexagon/ecs/object"
+	"github.com/SpaceHexagon/ecs/token"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
 func NewError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+func init() {
+	object.SetApplier(func(fn object.Object, args []object.Object, env *object.Environment, this *object.Hash) object.Object {
+		return applyFunction(fn, args, env, boundContext(fn, this))
+	})
+}
+
 func Eval(node ast.Node, env *object.Environment, objectContext *object.Hash) object.Object {
+	if err := env.Runtime().Step(); err != nil {
+		return err
+	}
+	if debugger := env.Runtime().Debugger; debugger != nil {
+		if err := debugStatement(debugger, node, env, objectContext); err != nil {
+			return err
+		}
+	}
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
@@ -74,7 +92,7 @@ func Eval(node ast.Node, env *object.Environment, objectContext *object.Hash) ob
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return allocate(env, evalPrefixExpression(node.Operator, right))
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env, objectContext)
@@ -85,9 +103,9 @@ func Eval(node ast.Node, env *object.Environment, objectContext *object.Hash) ob
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return allocate(env, evalInfixExpression(node.Operator, left, right))
 	case *ast.CallExpression:
-		function := Eval(node.Function, env, objectContext)
+		function, this := evalCallee(node.Function, env, objectContext)
 		if isError(function) {
 			return function
 		}
@@ -95,17 +113,14 @@ func Eval(node ast.Node, env *object.Environment, objectContext *object.Hash) ob
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args, objectContext)
+		return applyFunction(function, args, env, this)
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env, objectContext)
-		if isError(left) {
-			return left
-		}
-		index := Eval(node.Index, env, objectContext)
-		if isError(index) {
-			return index
-		}
-		return evalIndexExpression(left, index)
+		value, _ := evalIndexedValue(node, env, objectContext)
+		return value
+	case *ast.SliceExpression:
+		return allocate(env, evalSliceExpression(node, env, objectContext))
+	case *ast.SliceAssignmentExpression:
+		return evalSliceAssignmentExpression(node, env, objectContext)
 	case *ast.IndexAssignmentExpression:
 		left := Eval(node.Left, env, objectContext)
 		if isError(left) {
@@ -119,27 +134,29 @@ func Eval(node ast.Node, env *object.Environment, objectContext *object.Hash) ob
 		if isError(assignment) {
 			return assignment
 		}
-		return evalIndexAssignmentExpression(left, index, assignment)
+		return evalIndexAssignmentExpression(left, index, assignment, env.Runtime().Strict)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return allocate(env, &object.Integer{Value: node.Value})
 	case *ast.FloatLiteral:
-		return &object.Float{Value: node.Value}
+		return allocate(env, &object.Float{Value: node.Value})
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return allocate(env, &object.Function{Parameters: params, Env: env, Body: body, Generator: node.Generator})
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return allocate(env, &object.String{Value: node.Value})
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env, objectContext)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocate(env, &object.Array{Elements: elements})
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env, objectContext)
+		return allocate(env, evalHashLiteral(node, env, objectContext))
 	case *ast.IfExpression:
 		return evalIfExpression(node, env, objectContext)
 	case *ast.ForExpression:
@@ -150,8 +167,12 @@ func Eval(node ast.Node, env *object.Environment, objectContext *object.Hash) ob
 		return evalSleepExpression(node, env, objectContext)
 	case *ast.NewExpression:
 		return evalNewExpression(node, env, objectContext)
-	// case *ast.ExecExpression:
-	// 	return evalExecExpression(node, env, objectContext)
+	case *ast.ExecExpression:
+		return evalExecExpression(node, env, objectContext)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env, objectContext)
+	case *ast.YieldExpression:
+		return evalYieldExpression(node, env, objectContext)
 	case *ast.Identifier:
 		return evalIdentifier(node, env, objectContext)
 
@@ -159,6 +180,19 @@ func Eval(node ast.Node, env *object.Environment, objectContext *object.Hash) ob
 	return nil
 }
 
+// debugStatement reports node to the debugger if it is a statement. Blocks
+// are skipped, since the debugger sees each statement in them.
+func debugStatement(debugger object.Debugger, node ast.Node, env *object.Environment, objectContext *object.Hash) *object.Error {
+	stmt, ok := node.(ast.Statement)
+	if !ok {
+		return nil
+	}
+	if _, block := stmt.(*ast.BlockStatement); block {
+		return nil
+	}
+	return debugger.Statement(stmt, env, objectContext)
+}
+
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
@@ -240,6 +274,10 @@ func evalInfixExpression(
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
 		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case isLinear(left) || isLinear(right):
+		return evalLinearInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -247,8 +285,6 @@ func evalInfixExpression(
 	case left.Type() != right.Type():
 		return NewError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
-	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
 	default:
 		return NewError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -347,14 +383,15 @@ func evalNewExpression(ne *ast.NewExpression, env *object.Environment, objectCon
 		return NewError("new operator can only be used with Class or Hashmap. Invalid type: %s", classData.Type())
 	}
 	instance := util.CopyHashMap(classData)
+	instance.(*object.Hash).ClassName = ne.Name.Value
+	bindContextToMethods(instance.(*object.Hash))
 
 	className := object.String{Value: ne.Name.Value}
-	classNameKey := className.HashKey()
-	if instance.(*object.Hash).Pairs[classNameKey].Value.Type() != object.NULL_OBJ {
-		instance.(*object.Hash).Constructor = instance.(*object.Hash).Pairs[classNameKey].Value.(*object.Function)
+	if pair, ok := instance.(*object.Hash).Pairs[className.HashKey()]; ok {
+		if constructor, ok := pair.Value.(*object.Function); ok {
+			instance.(*object.Hash).Constructor = constructor
+		}
 	}
-	instance.(*object.Hash).ClassName = ne.Name.Value
-	bindContextToMethods(instance.(*object.Hash))
 
 	return instance
 }
@@ -362,11 +399,14 @@ func evalNewExpression(ne *ast.NewExpression, env *object.Environment, objectCon
 func bindContextToMethods(instance *object.Hash) {
 	pairs := instance.Pairs
 
-	for _, p := range pairs {
+	for key, p := range pairs {
 		pairType := p.Value.Type()
 
-		if pairType == object.BUILTIN_OBJ || pairType == object.FUNCTION_OBJ {
-			p.Value.(*object.Function).ObjectContext = *instance
+		if pairType == object.FUNCTION_OBJ {
+			// Copy the function so instances of one class do not share it.
+			method := *p.Value.(*object.Function)
+			method.ObjectContext = *instance
+			pairs[key] = object.HashPair{Key: p.Key, Value: &method}
 		}
 	}
 }
@@ -392,62 +432,55 @@ func evalForExpression(fl *ast.ForExpression, env *object.Environment, objectCon
 	)
 	rangeObj := Eval(fl.Range, env, objectContext)
 	element := fl.Element.Value
-	indexObj := &object.Integer{Value: index}
 
 	if isError(rangeObj) {
 		return rangeObj
 	}
 	rangeType := rangeObj.Type()
-	var (
-		err    object.Object
-		result object.Object
-	)
+	var result object.Object
 
 	if rangeType == object.INTEGER_OBJ {
 		length := rangeObj.(*object.Integer).Value
 		for index < length {
-			indexObj.Value = index
-			env.Set(element, indexObj)
+			// A new object per iteration, as closures and tasks may keep it.
+			env.Set(element, &object.Integer{Value: index})
 			result = Eval(fl.Consequence, env, objectContext)
 			if isError(result) {
-				err = result
+				return result
 			}
 			index++
 		}
 	} else if rangeType == object.ARRAY_OBJ {
 		length = int64(len(rangeObj.(*object.Array).Elements))
 		for index < length {
-			indexObj.Value = index
-			env.Set(element, indexObj)
+			env.Set(element, &object.Integer{Value: index})
 			result = Eval(fl.Consequence, env, objectContext)
 			if isError(result) {
-				err = result
+				return result
 			}
 			index++
 		}
 	} else if rangeType == object.STRING_OBJ {
-		length = int64(len(rangeObj.(*object.String).Value))
+		length = int64(utf8.RuneCountInString(rangeObj.(*object.String).Value))
 		for index < length {
-			indexObj.Value = index
-			env.Set(element, indexObj)
+			env.Set(element, &object.Integer{Value: index})
 			result = Eval(fl.Consequence, env, objectContext)
 			if isError(result) {
-				err = result
+				return result
 			}
 			index++
 		}
 	} else if rangeType == object.HASH_OBJ {
-		for _, v := range rangeObj.(*object.Hash).Pairs {
+		// Keys are visited in sorted order so that a journal hands out its
+		// values in the same order on every run.
+		for _, v := range builtins.SortedPairs(rangeObj.(*object.Hash)) {
 			env.Set(element, &object.String{Value: v.Value.Inspect()})
 			result = Eval(fl.Consequence, env, objectContext)
 			if isError(result) {
-				err = result
+				return result
 			}
 			index++
 		}
-		if err != nil {
-			return NewError("error in for loop %s", err)
-		}
 	} else {
 		return NewError("unknown range type in for loop: %s", rangeObj.Type())
 	}
@@ -462,8 +495,14 @@ func evalWhileExpression(ie *ast.WhileExpression, env *object.Environment, objec
 		return condition
 	}
 	for isTruthy(condition) {
-		Eval(ie.Consequence, env, objectContext)
+		result := Eval(ie.Consequence, env, objectContext)
+		if isError(result) {
+			return result
+		}
 		condition = Eval(ie.Condition, env, objectContext)
+		if isError(condition) {
+			return condition
+		}
 	}
 
 	return NULL
@@ -475,23 +514,251 @@ func evalSleepExpression(se *ast.SleepExpression, env *object.Environment, objec
 		return duration
 	}
 	sleepDuration, _ := strconv.Atoi(duration.Inspect())
-	time.Sleep(time.Duration(sleepDuration*1000000) * time.Nanosecond)
-	Eval(se.Consequence, env, objectContext)
+	if co := env.Coroutine(); co != nil {
+		// Inside a coroutine, sleeping suspends it instead of blocking.
+		if result := yieldValue(co, &object.Wait{Seconds: float64(sleepDuration) / 1000}); isError(result) {
+			return result
+		}
+	} else if err := env.Runtime().Sleep(time.Duration(sleepDuration) * time.Millisecond); err != nil {
+		return err
+	}
+	if result := Eval(se.Consequence, env, objectContext); isError(result) {
+		return result
+	}
 	return NULL
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+// evalYieldExpression suspends the coroutine the code is running in and
+// evaluates to the value it is resumed with.
+func evalYieldExpression(ye *ast.YieldExpression, env *object.Environment, objectContext *object.Hash) object.Object {
+	co := env.Coroutine()
+	if co == nil {
+		return NewError("yield outside of a coroutine")
+	}
+	var value object.Object = NULL
+	if ye.Value != nil {
+		value = Eval(ye.Value, env, objectContext)
+		if isError(value) {
+			return value
+		}
+	}
+	return yieldValue(co, value)
+}
+
+func yieldValue(co *object.Coroutine, value object.Object) object.Object {
+	resumed, ok := co.Yield(value)
+	if !ok {
+		return NewError("coroutine cancelled")
+	}
+	if resumed == nil {
+		return NULL
+	}
+	return resumed
+}
+
+// evalSpawnExpression evaluates the function and arguments of a spawn on the
+// calling goroutine and then runs the call on a new one, returning a task
+// that can be awaited. The task belongs to the current run and is cancelled
+// when the run ends.
+func evalSpawnExpression(se *ast.SpawnExpression, env *object.Environment, objectContext *object.Hash) object.Object {
+	function, this := evalCallee(se.Function, env, objectContext)
+	if isError(function) {
+		return function
+	}
+	args := evalExpressions(se.Arguments, env, objectContext)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	switch function.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return NewError("spawn requires a function, got %s", function.Type())
+	}
+	env.Runtime().EnableConcurrency()
+	task := object.NewTask()
+	env.Runtime().Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				task.Complete(NewError("task failed: %v", r))
+			}
+		}()
+		// Tasks start a new call stack outside any coroutine.
+		task.Complete(applyFunction(function, args, object.NewEnclosedEnvironment(env), this))
+	})
+	return task
+}
+
+// evalExecExpression runs a host command and returns its standard output.
+// The command is split on whitespace and run without a shell.
+func evalExecExpression(ee *ast.ExecExpression, env *object.Environment, objectContext *object.Hash) object.Object {
+	runtime := env.Runtime()
+	if err := runtime.Require(object.EXEC_CAPABILITY); err != nil {
+		return err
+	}
+	command := Eval(ee.Name, env, objectContext)
+	if isError(command) {
+		return command
+	}
+	fields := strings.Fields(command.(*object.String).Value)
+	if len(fields) == 0 {
+		return NewError("exec requires a command")
+	}
+	run := func() object.Object {
+		output, err := exec.CommandContext(runtime.RunContext(), fields[0], fields[1:]...).Output()
+		if err != nil {
+			if limitErr := runtime.CheckContext(); limitErr != nil {
+				return limitErr
+			}
+			return NewError("exec %s failed: %s", fields[0], err)
+		}
+		return &object.String{Value: string(output)}
+	}
+	if runtime.Journal != nil {
+		return runtime.Journal.Entry("exec", run)
+	}
+	return run()
+}
+
+func evalIndexExpression(left, index object.Object, strict bool) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexExpression(left, index)
+		return evalArrayIndexExpression(left, index, strict)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index, strict)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case isLinear(left) && index.Type() == object.INTEGER_OBJ:
+		return evalLinearIndexExpression(left, index.(*object.Integer).Value, strict)
 	default:
 		return NewError("index operator not supported: %s", left.Type())
 	}
 }
 
-func evalIndexAssignmentExpression(left, index object.Object, assignment object.Object) object.Object {
+func evalSliceExpression(se *ast.SliceExpression, env *object.Environment, objectContext *object.Hash) object.Object {
+	left := Eval(se.Left, env, objectContext)
+	if isError(left) {
+		return left
+	}
+	switch left := left.(type) {
+	case *object.String:
+		runes := []rune(left.Value)
+		start, end, err := evalSliceBounds(se.Start, se.End, int64(len(runes)), env, objectContext)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: string(runes[start:end])}
+	case *object.Array:
+		start, end, err := evalSliceBounds(se.Start, se.End, int64(len(left.Elements)), env, objectContext)
+		if err != nil {
+			return err
+		}
+		elements := make([]object.Object, end-start)
+		copy(elements, left.Elements[start:end])
+		return &object.Array{Elements: elements}
+	default:
+		return NewError("slice operator not supported: %s", left.Type())
+	}
+}
+
+func evalSliceAssignmentExpression(sae *ast.SliceAssignmentExpression, env *object.Environment, objectContext *object.Hash) object.Object {
+	left := Eval(sae.Left, env, objectContext)
+	if isError(left) {
+		return left
+	}
+	array, ok := left.(*object.Array)
+	if !ok {
+		return NewError("slice assignment not supported: %s", left.Type())
+	}
+	start, end, err := evalSliceBounds(sae.Start, sae.End, int64(len(array.Elements)), env, objectContext)
+	if err != nil {
+		return err
+	}
+	assignment := Eval(sae.Assignment, env, objectContext)
+	if isError(assignment) {
+		return assignment
+	}
+	replacement, ok := assignment.(*object.Array)
+	if !ok {
+		return NewError("can only assign ARRAY to a slice, got %s", assignment.Type())
+	}
+	elements := make([]object.Object, 0, int64(len(array.Elements))-(end-start)+int64(len(replacement.Elements)))
+	elements = append(elements, array.Elements[:start]...)
+	elements = append(elements, replacement.Elements...)
+	elements = append(elements, array.Elements[end:]...)
+	array.Elements = elements
+	return assignment
+}
+
+// evalSliceBounds evaluates the bounds of a slice over a sequence of the
+// given length. Omitted bounds default to the whole sequence and negative
+// bounds count from the end. Bounds outside the sequence are clamped, or
+// raise an error in strict mode.
+func evalSliceBounds(
+	startNode, endNode ast.Expression,
+	length int64,
+	env *object.Environment,
+	objectContext *object.Hash,
+) (int64, int64, object.Object) {
+	start, end := int64(0), length
+	for i, node := range []ast.Expression{startNode, endNode} {
+		if node == nil {
+			continue
+		}
+		bound := Eval(node, env, objectContext)
+		if isError(bound) {
+			return 0, 0, bound
+		}
+		integer, ok := bound.(*object.Integer)
+		if !ok {
+			return 0, 0, NewError("slice bound must be INTEGER, got %s", bound.Type())
+		}
+		value := integer.Value
+		if value < 0 {
+			value += length
+		}
+		if value < 0 || value > length {
+			if env.Runtime().Strict {
+				return 0, 0, NewError("slice bound out of range: %d with length %d", integer.Value, length)
+			}
+			value = clampBound(value, length)
+		}
+		if i == 0 {
+			start = value
+		} else {
+			end = value
+		}
+	}
+	if end < start {
+		end = start
+	}
+	return start, end, nil
+}
+
+// clampBound limits a slice bound to the range [0, length].
+func clampBound(bound, length int64) int64 {
+	if bound < 0 {
+		return 0
+	}
+	if bound > length {
+		return length
+	}
+	return bound
+}
+
+// normalizeIndex resolves negative indices relative to the end of a sequence
+// and reports whether the result lies within it.
+func normalizeIndex(idx, length int64) (int64, bool) {
+	if idx < 0 {
+		idx += length
+	}
+	return idx, idx >= 0 && idx < length
+}
+
+func indexOutOfRange(idx, length int64) *object.Error {
+	return NewError("index out of range: %d with length %d", idx, length)
+}
+
+func evalIndexAssignmentExpression(left, index object.Object, assignment object.Object, strict bool) object.Object {
 	var (
 		indexExpType = -1
 	)
@@ -508,7 +775,7 @@ func evalIndexAssignmentExpression(left, index object.Object, assignment object.
 
 	switch indexExpType {
 	case 0:
-		return evalArrayIndexAssignment(left, index, assignment)
+		return evalArrayIndexAssignment(left, index, assignment, strict)
 	case 1:
 		return evalHashIndexAssignment(left, index, assignment)
 	// case 2:
@@ -518,14 +785,31 @@ func evalIndexAssignmentExpression(left, index object.Object, assignment object.
 	}
 }
 
-func evalArrayIndexExpression(array, index object.Object) object.Object {
+func evalArrayIndexExpression(array, index object.Object, strict bool) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	length := int64(len(arrayObject.Elements))
+	pos, ok := normalizeIndex(idx, length)
+	if !ok {
+		if strict {
+			return indexOutOfRange(idx, length)
+		}
 		return NULL
 	}
-	return arrayObject.Elements[idx]
+	return arrayObject.Elements[pos]
+}
+func evalStringIndexExpression(str, index object.Object, strict bool) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	length := int64(len(runes))
+	pos, ok := normalizeIndex(idx, length)
+	if !ok {
+		if strict {
+			return indexOutOfRange(idx, length)
+		}
+		return NULL
+	}
+	return &object.String{Value: string(runes[pos])}
 }
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
@@ -540,15 +824,19 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
-func evalArrayIndexAssignment(array, index object.Object, value object.Object) object.Object {
+func evalArrayIndexAssignment(array, index object.Object, value object.Object, strict bool) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	length := int64(len(arrayObject.Elements))
+	pos, ok := normalizeIndex(idx, length)
+	if !ok {
+		if strict {
+			return indexOutOfRange(idx, length)
+		}
 		return NULL
 	}
 
-	arrayObject.Elements[idx] = value
+	arrayObject.Elements[pos] = value
 	return value
 }
 func evalHashIndexAssignment(hash, index object.Object, value object.Object) object.Object {
@@ -576,7 +864,11 @@ func evalIdentifier(
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
-	if builtin, ok := builtins.ECSBuiltins[node.Value]; ok {
+	globals := env.Runtime().Builtins
+	if globals == nil {
+		globals = builtins.ECSBuiltins
+	}
+	if builtin, ok := globals[node.Value]; ok {
 		return builtin
 	}
 	return NewError("identifier not found: " + node.Value)
@@ -587,7 +879,10 @@ func evalHashLiteral(
 	objectContext *object.Hash,
 ) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
-	for keyNode, valueNode := range node.Pairs {
+	// Pairs are evaluated in source order so that side effects, and the
+	// values a journal hands out, come in the same order on every run.
+	for _, keyNode := range node.OrderedKeys() {
+		valueNode := node.Pairs[keyNode]
 		key := Eval(keyNode, env, objectContext)
 		if isError(key) {
 			return key
@@ -618,31 +913,123 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return NewError("unknown operator: -%s", right.Type())
+	if isLinear(right) {
+		return evalLinearNegation(right)
+	}
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	}
+	return NewError("unknown operator: -%s", right.Type())
+}
+
+// evalCallee evaluates the function of a call expression together with the
+// hash it is called on. Methods called as obj.method() see obj as this,
+// methods taken from an instance keep the instance they were bound to, and
+// other calls inherit the caller's this.
+func evalCallee(node ast.Expression, env *object.Environment, objectContext *object.Hash) (object.Object, *object.Hash) {
+	if index, ok := node.(*ast.IndexExpression); ok {
+		function, left := evalIndexedValue(index, env, objectContext)
+		if receiver, ok := left.(*object.Hash); ok && !isError(function) {
+			return function, receiver
+		}
+		return function, boundContext(function, objectContext)
+	}
+	function := Eval(node, env, objectContext)
+	return function, boundContext(function, objectContext)
+}
+
+func boundContext(function object.Object, objectContext *object.Hash) *object.Hash {
+	if fn, ok := function.(*object.Function); ok && fn.ObjectContext.Pairs != nil {
+		return &fn.ObjectContext
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return objectContext
 }
 
-func applyFunction(fn object.Object, args []object.Object, objectContext *object.Hash) object.Object {
+// evalIndexedValue evaluates left[index] or left.index and also returns the
+// evaluated left side, which is the receiver when the value is called.
+func evalIndexedValue(node *ast.IndexExpression, env *object.Environment, objectContext *object.Hash) (object.Object, object.Object) {
+	left := Eval(node.Left, env, objectContext)
+	if isError(left) {
+		return left, nil
+	}
+	index := Eval(node.Index, env, objectContext)
+	if isError(index) {
+		return index, nil
+	}
+	if node.Token.Type == token.DOT && left.Type() != object.HASH_OBJ {
+		return evalMethodExpression(left, index, env.Runtime()), left
+	}
+	return evalIndexExpression(left, index, env.Runtime().Strict), left
+}
+
+// ApplyFunction calls a function or builtin with already evaluated
+// arguments. It is used by hosts to call into script; builtins see env as
+// the calling environment.
+func ApplyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return applyFunction(fn, args, env, boundContext(fn, nil))
+}
+
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment, this *object.Hash) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv, objectContext)
+		if len(args) < len(fn.Parameters) {
+			return NewError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+		extendedEnv := extendFunctionEnv(fn, args, env)
+		if err := extendedEnv.Runtime().CheckDepth(extendedEnv.Depth()); err != nil {
+			return err
+		}
+		if fn.Generator {
+			return newGenerator(fn, extendedEnv, this)
+		}
+		if debugger := env.Runtime().Debugger; debugger != nil {
+			debugger.Call(fn, extendedEnv)
+			defer debugger.Return(fn)
+		}
+		evaluated := Eval(fn.Body, extendedEnv, this)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		// implement api context
-		return fn.Fn(nil, nil, args...)
+		if fn.Capability != "" {
+			if err := env.Runtime().Require(fn.Capability); err != nil {
+				return err
+			}
+		}
+		return allocate(env, fn.Fn(newCallContext(env, this), args...))
 	default:
 		return NewError("not a function: %s", fn.Type())
 	}
 }
+
+func newCallContext(env *object.Environment, this *object.Hash) *object.CallContext {
+	runtime := env.Runtime()
+	return &object.CallContext{
+		Env:         env,
+		This:        this,
+		Interpreter: runtime.Host,
+		Out:         runtime.Out,
+		Err:         runtime.Err,
+		Context:     runtime.RunContext(),
+		Journal:     runtime.Journal,
+	}
+}
+
+// newGenerator returns the coroutine created by calling a fn* function. The
+// body runs on the first resume.
+func newGenerator(fn *object.Function, env *object.Environment, this *object.Hash) *object.Coroutine {
+	return object.NewCoroutine(func(co *object.Coroutine) object.Object {
+		env.SetCoroutine(co)
+		return unwrapReturnValue(Eval(fn.Body, env, this))
+	})
+}
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
+	caller *object.Environment,
 ) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+	env := object.NewCallEnvironment(fn.Env, caller)
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx])
 	}
@@ -655,6 +1042,19 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// allocate counts obj against the run's allocation limit. Only objects that
+// were just created by the caller should be passed in.
+func allocate(env *object.Environment, obj object.Object) object.Object {
+	switch obj.(type) {
+	case *object.Boolean, *object.Null, *object.Error:
+		return obj
+	}
+	if err := env.Runtime().Allocate(1); err != nil {
+		return err
+	}
+	return obj
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"log"
+	"strings"
 	"testing"
 
 	"github.com/SpaceHexagon/ecs/lexer"
@@ -41,7 +42,16 @@ func testEval(input string) object.Object {
 	p := parser.New(l)
 	program := p.ParseProgram()
 	env := object.NewEnvironment()
-	return Eval(program, env)
+	return Eval(program, env, nil)
+}
+
+func testEvalStrict(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.Runtime().Strict = true
+	return Eval(program, env, nil)
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -204,6 +214,14 @@ func TestErrorHandling(t *testing.T) {
 			"5; true + false; 5",
 			"unknown operator: BOOLEAN + BOOLEAN",
 		},
+		{
+			"for (i, 3) { i + true }",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let n = 0; while (n < 3) { let n = n + 1; -true }",
+			"unknown operator: -BOOLEAN",
+		},
 		{
 			"if (10 > 1) { true + false; }",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -296,6 +314,19 @@ func TestFunctionApplication(t *testing.T) {
 	for _, tt := range tests {
 		testIntegerObject(t, testEval(tt.input), tt.expected)
 	}
+
+	arity := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let add = fn(x, y) { x + y; }; add(5);", "wrong number of arguments. got=1, want=2"},
+		{"let first = fn(x) { x; }; first(1, 2);", 1},
+		{"let c = coroutine(fn(a, b) { yield a }, 1); c.resume()", "wrong number of arguments. got=1, want=2"},
+		{"Grid.create(2, 2).astar(0, 0, 1, 1, fn(v, x, y, extra) { 1 })", "wrong number of arguments. got=3, want=4"},
+	}
+	for _, tt := range arity {
+		testResult(t, tt.input, tt.expected)
+	}
 }
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
@@ -409,6 +440,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -458,6 +497,32 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestHashLiteralEvaluationOrder(t *testing.T) {
+	input := `
+	let counter = {"n": 0};
+	let next = fn() { counter.n = counter.n + 1; counter.n };
+	let h = {"e": next(), "d": next(), "c": next(), "b": next(), "a": next()};
+	let result = [h.e, h.d, h.c, h.b, h.a]; result`
+	evaluated := testEval(input)
+	if evaluated.Inspect() != "[1, 2, 3, 4, 5]" {
+		t.Errorf("pairs not evaluated in source order. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestHashIterationOrder(t *testing.T) {
+	input := `
+	let h = {"e": 5, "d": 4, "c": 3, "b": 2, "a": 1, 1: "int", "1": "string"};
+	let seen = [];
+	for (v, h) { seen.push(v) };
+	seen`
+	for i := 0; i < 20; i++ {
+		evaluated := testEval(input)
+		if evaluated.Inspect() != "[int, string, 1, 2, 3, 4, 5]" {
+			t.Fatalf("hash not iterated in key order. got=%s", evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -502,3 +567,586 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestStringIndexAndSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"hello"[0]`, "h"},
+		{`"hello"[4]`, "o"},
+		{`"hello"[5]`, nil},
+		{`"héllo"[1]`, "é"},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[:2]`, "he"},
+		{`"hello"[3:]`, "lo"},
+		{`"hello"[:]`, "hello"},
+		{`"hello"[3:1]`, ""},
+		{`"hello"[2:100]`, "llo"},
+		{`let s = "dialogue"; s[0:len(s) - 3]`, "dialo"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+		testStringObject(t, evaluated, expected)
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`join(split("a,b,c", ","), "-")`, "a-b-c"},
+		{`replace("a.b.c", ".", "/")`, "a/b/c"},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("--hi--", "-")`, "hi"},
+		{`upper("Goblin")`, "GOBLIN"},
+		{`lower("Goblin")`, "goblin"},
+		{`startsWith("Goblin", "Gob")`, true},
+		{`endsWith("Goblin", "Gob")`, false},
+		{`contains("Goblin", "bli")`, true},
+		{`indexOf("Goblin", "lin")`, 3},
+		{`indexOf("Goblin", "orc")`, -1},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 4611686018427387904)`, "repeated string would be longer than 268435456 bytes"},
+		{`repeat("ab", -1)`, "negative repeat count: -1"},
+		{`len(repeat("", 4611686018427387904))`, 0},
+		{`format("{} has {} hp", "Goblin", 50)`, "Goblin has 50 hp"},
+		{`format("{1} {0} {{ok}}", "a", "b")`, "b a {ok}"},
+		{`format("{} {}", "a")`, "format string references argument 2, but got 1"},
+		{`format("{0} {3}", "a", "b")`, "format string references argument 4, but got 2"},
+		{`upper(1)`, "argument to `upper` must be STRING, got INTEGER"},
+		{`regex.test("^[a-z]+$", "goblin")`, true},
+		{`regex.find("[0-9]+", "hp: 50")`, "50"},
+		{`join(regex.findAll("[0-9]+", "1 22 333"), ",")`, "1,22,333"},
+		{`regex.replace("(\w+)@", "bob@ ann@", "<$1>")`, "<bob> <ann>"},
+		{`regex.groups("(\w+)=(\d+)", "hp=50")[2]`, "50"},
+		{`len(regex.split("\s+", "a  b c"))`, 3},
+		{`regex.test("(", "x")`, "invalid regular expression: error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+// testResult evaluates input, which must parse, and checks the result the
+// way the tables in this file are written: an int, bool or nil is checked
+// with the typed helpers, and a string is compared with the message of an
+// error or else with the inspected result.
+func testResult(t *testing.T, input string, expected interface{}) {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Errorf("parse errors for %q: %s", input, strings.Join(p.Errors(), "; "))
+		return
+	}
+	evaluated := Eval(program, object.NewEnvironment(), nil)
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, evaluated, int64(expected))
+	case bool:
+		testBooleanObject(t, evaluated, expected)
+	case nil:
+		testNullObject(t, evaluated)
+	case string:
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q",
+				input, expected, actual)
+		}
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[1, 2, 3].len()`, 3},
+		{`"goblin".len()`, 6},
+		{`"goblin".upper()`, "GOBLIN"},
+		{`"a,b".split(",").join("+")`, "a+b"},
+		{`"{} hp".format(10)`, "10 hp"},
+		{`let a = [1]; a.push(2, 3); a`, "[1, 2, 3]"},
+		{`let a = [1]; a.push(2)`, 2},
+		{`let a = [1, 2, 3]; a.pop()`, 3},
+		{`let a = [1, 2, 3]; a.pop(); a`, "[1, 2]"},
+		{`let a = []; a.pop()`, nil},
+		{`let a = [1, 3]; a.insert(1, 2); a`, "[1, 2, 3]"},
+		{`let a = [1, 2, 3]; a.removeAt(0)`, 1},
+		{`let a = [1, 2, 3]; a.removeAt(1); a`, "[1, 3]"},
+		{`let a = [1, 2, 3]; a.removeAt(3)`, "removeAt index out of range: 3 with length 3"},
+		{`["a", "b"].indexOf("b")`, 1},
+		{`[1, 2].contains(3)`, false},
+		{`let a = [1, 2, 3]; a.reverse(); a`, "[3, 2, 1]"},
+		{`let a = [1]; let b = a.copy(); b.push(2); a`, "[1]"},
+		{`let n = 3; n.toFixed(2)`, "3.00"},
+		{`let n = 2.345; n.toFixed(1)`, "2.3"},
+		{`let n = 1; n.toFixed(-1)`, "argument to `toFixed` must be an INTEGER from 0 to 100, got -1"},
+		{`let n = 1; n.toFixed(4611686018427387904)`, "argument to `toFixed` must be an INTEGER from 0 to 100, got 4611686018427387904"},
+		{`let n = 1; len(n.toFixed(100))`, 102},
+		{`"ab".repeat(2)`, "abab"},
+		{`"ab".repeat(4611686018427387904)`, "repeated string would be longer than 268435456 bytes"},
+		{`let n = 2.5; n.floor()`, 2},
+		{`let n = 7; n.toString()`, "7"},
+		{`let add = fn(a, b) { a + b }; add.call(1, 2)`, 3},
+		{`let add = fn(a, b) { a + b }; add.apply([2, 3])`, 5},
+		{`let add = fn(a, b) { a + b }; add.arity()`, 2},
+		{`[1].shuffle()`, "undefined method shuffle for ARRAY"},
+		{`let h = {"len": fn() { 42 }}; h.len()`, 42},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestArraySlices(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"[1, 2, 3, 4][1:10]", "[2, 3, 4]"},
+		{`"hello"[-3:]`, "llo"},
+		{`"hello"[-1]`, "o"},
+		{"let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a", "[1, 2, 3]"},
+		{"let a = [1, 2, 3, 4]; a[1:3] = [7]; a", "[1, 7, 4]"},
+		{"let a = [1, 2]; a[1:1] = [5, 6]; a", "[1, 5, 6, 2]"},
+		{"let a = [1, 2]; a[2:] = [3]; a", "[1, 2, 3]"},
+		{"let a = [1, 2]; a[-1] = 5; a", "[1, 5]"},
+		{"let a = [1, 2]; a[:] = 5", "can only assign ARRAY to a slice, got INTEGER"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestStrictModeBounds(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3][3]", "index out of range: 3 with length 3"},
+		{"[1, 2, 3][-4]", "index out of range: -4 with length 3"},
+		{`"abc"[5]`, "index out of range: 5 with length 3"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1 with length 1"},
+		{"[1, 2][0:5]", "slice bound out of range: 5 with length 2"},
+		{"let f = fn(a) { a[2] }; f([1])", "index out of range: 2 with length 1"},
+		{"Math.Vec2(1, 2)[2]", "index out of range: 2 in VEC2"},
+		{"Math.Quat()[-1]", "index out of range: -1 in QUAT"},
+		{"Math.Mat3()[3]", "index out of range: 3 in MAT3"},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalStrict(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expected, errObj.Message)
+		}
+	}
+	testIntegerObject(t, testEvalStrict("[1, 2, 3][-1]"), 3)
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json.stringify({"b": [1, 2.5, true, "x"], "a": {"n": null}})`, `{"a":{"n":null},"b":[1,2.5,true,"x"]}`},
+		{`json.stringify(1.0)`, `1.0`},
+		{`json.stringify([1, [2]], 2)`, "[\n  1,\n  [\n    2\n  ]\n]"},
+		{`json.stringify([1], -1)`, "indent for `json.stringify` must be between 0 and 10, got -1"},
+		{`json.stringify([1], 11)`, "indent for `json.stringify` must be between 0 and 10, got 11"},
+		{`[json.parse("1e3"), json.parse("1000"), json.parse("1.0")]`, "[1000.000000, 1000, 1.000000]"},
+		{`json.parse("[1, 2.5, 3e2]")[2]`, "300.000000"},
+		{`typeof json.parse("1")`, "INTEGER"},
+		{`typeof json.parse("1.0")`, "FLOAT"},
+		{`json.parse(json.stringify({"hp": 5})).hp`, 5},
+		{`let s = json.stringify({"a": [1, 2.5, {"b": null}], "c": false}); json.stringify(json.parse(s)) == s`, true},
+		{`json.parse(json.stringify([null]))[0] == null`, true},
+		{`json.parse("{")`, "invalid JSON: unexpected EOF"},
+		{`json.parse("[1] 2")`, "invalid JSON: unexpected data after top-level value"},
+		{`json.stringify(fn() {})`, "cannot encode FUNCTION as JSON"},
+		{`let a = []; a.push(a); json.stringify(a)`, "cannot encode cyclic ARRAY as JSON"},
+		{`json.stringify({1: "a", "1": "b"})`, "cannot encode HASH with INTEGER key 1 as JSON"},
+		{`json.stringify({"a": {true: 1}})`, "cannot encode HASH with BOOLEAN key true as JSON"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestThisBinding(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let o = {"hp": 3, "get": fn() { this.hp }}; o.get()`, 3},
+		{`let o = {"hp": 3, "get": fn() { this.hp }}; o["get"]()`, 3},
+		{`let o = {"hp": 3, "heal": fn(n) { this.hp = this.hp + n }}; o.heal(2); o.hp`, 5},
+		{`class Unit { "hp": 1, "get": fn() { this.hp } }; let a = new Unit; let b = new Unit; b.hp = 7; a.get()`, 1},
+		{`class Unit { "hp": 1, "get": fn() { this.hp } }; let a = new Unit; a.hp = 4; let get = a.get; get()`, 4},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let t = spawn fn() { 1 + 2 }; await(t)`, 3},
+		{`let add = fn(a, b) { a + b }; let t = spawn add(2, 3); t.await()`, 5},
+		{`let add = fn(a, b) { a + b }; await(spawn(add, 4, 5))`, 9},
+		{`let tasks = []; for (i, 4) { tasks.push(spawn fn(n) { n * n }(i)) }; let total = 0; for (i, 4) { let total = total + await(tasks[i]) }; total`, 14},
+		{`let ch = chan(); spawn fn() { for (i, 3) { send(ch, i) }; close(ch) }; let sum = 0; let v = recv(ch); while (v != null) { let sum = sum + v; let v = recv(ch) }; sum`, 3},
+		{`let ch = chan(2); ch.send("a"); ch.send("b"); [ch.len(), ch.recv(), ch.recv()]`, "[2, a, b]"},
+		{`let a = chan(); let b = chan(1); b.send(7); select([a, b])`, "[1, 7]"},
+		{`select([chan()], 0)`, "[-1, null]"},
+		{`select([chan()], 5)`, "[-1, null]"},
+		{`let ch = chan(); close(ch); [recv(ch), select([ch])]`, "[null, [0, null]]"},
+		{`let ch = chan(); close(ch); close(ch)`, "close of closed channel"},
+		{`let ch = chan(1); close(ch); send(ch, 1)`, "send on closed channel"},
+		{`chan(-1)`, "argument to `chan` must be a non-negative INTEGER, got -1"},
+		{`chan(4611686018427387904)`, "channel capacity must be at most 1048576, got 4611686018427387904"},
+		{`chan(1048576).len()`, 0},
+		{`await(spawn fn() { 1 + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`spawn 5`, "spawn requires a function, got INTEGER"},
+		{`let t = spawn fn() { 1 }; await(t); t.done()`, true},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestCoroutines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let g = fn*() { yield 1; yield 2; 3 }; let c = g(); [c.resume(), c.resume(), c.resume(), c.done()]`, "[1, 2, 3, true]"},
+		{`let g = fn*(n) { let got = yield n; got * 2 }; let c = g(5); [c.resume(), c.resume(21)]`, "[5, 42]"},
+		{`let c = coroutine(fn(a) { yield a; yield a + 1 }, 10); [c.resume(), c.resume(), c.resume(), c.done()]`, "[10, 11, null, true]"},
+		{`let c = fn*() { 1 }(); c.resume(); c.resume()`, "cannot resume finished coroutine"},
+		{`let c = fn*() { yield 1; yield 2 }(); c.resume(); c.cancel(); c.done()`, true},
+		{`yield 1`, "yield outside of a coroutine"},
+		{`wait(1)`, "wait outside of a coroutine"},
+		{`let c = fn*() { wait(0.5) }(); c.resume()`, "wait(0.5)"},
+		{`let c = fn*() { sleep(10000) { 1 } }(); c.resume()`, "wait(10)"},
+		{`let c = fn*() { 1 + true }(); c.resume()`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let c = fn*() { c.resume() }(); c.resume()`, "coroutine is running"},
+		{`let w = World.create(); let log = []; w.start(fn() { log.push("a"); wait(1); log.push("b") }); w.tick(0.5); let before = len(log); w.tick(0.5); [before, log, w.time(), w.frame()]`, "[1, [a, b], 1.000000, 2]"},
+		{`let w = World.create(); let e = w.spawn(); let count = {"n": 0}; e.start(fn(self) { while (true) { count.n = count.n + 1; yield } }); w.tick(1); w.tick(1); e.destroy(); w.tick(1); [count.n, e.alive()]`, "[3, false]"},
+		{`Component.define("Health", {"hp": 10, "max": 10}); let w = World.create(); let e = w.spawn({"Health": {"hp": 4}}); [e.get("Health").hp, e.get("Health").max, e.has("Health"), e.has("Position")]`, "[4, 10, true, false]"},
+		{`let w = World.create(); w.spawn({"Position": {"x": 0.0}, "Velocity": {"x": 2.0}}); w.spawn({"Position": {"x": 5}}); w.system(fn(dt, world) { for (i, len(world.query("Position", "Velocity"))) { let e = world.query("Position", "Velocity")[i]; e.get("Position").x = e.get("Position").x + e.get("Velocity").x * dt } }); w.tick(0.5); w.tick(0.5); [w.entity(1).get("Position").x, w.entity(2).get("Position").x]`, "[2.000000, 5]"},
+		{`let w = World.create(); w.spawn({"Position": 1})`, "component Position must be HASH, got INTEGER"},
+		{`let h = {"a": 1}; h["self"] = h; Component.define("Cyclic", {"x": h})`, "defaults of Cyclic: cannot copy cyclic HASH"},
+		{`let d = {"hp": 1}; Component.define("Copied", d); d["self"] = d; World.create().spawn({"Copied": {}}).get("Copied").hp`, 1},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let w = World.create(); let log = []; w.on("hit", fn(e) { log.push(e.amount) }); w.emit("hit", {"amount": 3}); let before = len(log); w.tick(0.1); [before, log]`, "[0, [3]]"},
+		{`let w = World.create(); let log = []; let e = w.spawn(); w.on("damaged", fn(ev) { log.push([ev.type, ev.source.id(), ev.amount]) }); e.emit("damaged", {"amount": 10}); w.tick(0.1); log`, "[[damaged, 1, 10]]"},
+		{`let w = World.create(); let log = []; w.on("a", fn(e) { log.push("first " + e.type) }); w.on("b", fn(e) { log.push("b") }); w.on("a", fn(e) { log.push("second " + e.type) }); w.emit("a"); w.emit("b"); w.emit("a"); w.tick(0.1); log`, "[first a, second a, b, first a, second a]"},
+		{`let w = World.create(); let n = {"count": 0}; w.once("ping", fn(e) { n.count = n.count + 1 }); w.emit("ping"); w.emit("ping"); w.tick(0.1); w.emit("ping"); w.tick(0.1); n.count`, 1},
+		{`let w = World.create(); let n = {"count": 0}; let id = w.on("ping", fn(e) { n.count = n.count + 1 }); w.emit("ping"); w.tick(0.1); let removed = w.off(id); w.emit("ping"); w.tick(0.1); [removed, n.count]`, "[1, 1]"},
+		{`let w = World.create(); let f = fn(e) { 1 }; w.on("x", f); w.on("x", f); w.on("x", fn(e) { 2 }); [w.off("x", f), w.off("x")]`, "[2, 1]"},
+		{`let w = World.create(); let log = []; let a = w.spawn(); let b = w.spawn(); a.on("hit", fn(e) { log.push(e.source.id()) }); b.emit("hit"); a.emit("hit"); w.tick(0.1); log`, "[1]"},
+		{`let w = World.create(); let log = []; let a = w.spawn(); a.on("hit", fn(e) { log.push("a") }); a.emit("hit"); a.destroy(); w.tick(0.1); log`, "[]"},
+		{`let w = World.create(); let log = []; w.on("tick", fn(e) { log.push(e.n); w.emit("tick", {"n": e.n + 1}) }); w.emit("tick", {"n": 0}); w.tick(0.1); w.tick(0.1); log`, "[0, 1]"},
+		{`let w = World.create(); w.on("bad", fn(e) { 1 + true }); w.emit("bad"); w.tick(0.1)`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let w = World.create(); w.on("x", 1)`, "second argument to `on` must be FUNCTION, got INTEGER"},
+		{`let w = World.create(); w.emit("x", 1)`, "second argument to `emit` must be HASH, got INTEGER"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestTimers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let w = World.create(); let log = []; w.after(1000, fn() { log.push("b") }); w.after(500, fn() { log.push("a") }); w.tick(0.4); let first = len(log); w.tick(0.6); [first, log]`, "[0, [a, b]]"},
+		{`let w = World.create(); let n = {"count": 0}; w.every(100, fn() { n.count = n.count + 1 }); for (i, 10) { w.tick(0.1) }; w.tick(0.5); n.count`, 15},
+		{`let w = World.create(); let id = w.after(10, fn() { 1 }); [w.cancel(id), w.cancel(id)]`, "[true, false]"},
+		{`let w = World.create(); let n = {"count": 0}; let id = 0; let id = w.every(100, fn() { n.count = n.count + 1; if (n.count == 2) { w.cancel(id) } }); w.tick(1); n.count`, 2},
+		{`every(0, fn() { 1 })`, "first argument to `every` must be a positive number, got 0"},
+		{`after(-1, fn() { 1 })`, "first argument to `after` must be a non-negative number, got -1"},
+		{`after(10, 1)`, "second argument to `after` must be FUNCTION, got INTEGER"},
+		{`cancel("x")`, "argument to `cancel` must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestPrefabs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`Component.define("Stats", {"hp": 10, "speed": 1}); Prefab.define("Goblin", {"components": {"Stats": {"hp": 30}, "Name": {"name": "goblin"}}}); let w = World.create(); let g = w.spawn("Goblin"); [g.get("Stats").hp, g.get("Stats").speed, g.get("Name").name, g.components()]`, "[30, 1, goblin, [Name, Stats]]"},
+		{`Prefab.define("Orc", {"components": {"Stats": {"hp": 30, "speed": 2}}}); let w = World.create(); let a = w.spawn("Orc", {"Stats": {"hp": 50}, "Boss": {}}); let b = w.spawn("Orc"); [a.get("Stats").hp, a.get("Stats").speed, a.has("Boss"), b.get("Stats").hp, b.has("Boss")]`, "[50, 2, true, 30, false]"},
+		{`Prefab.define("Sword", {"components": {"Item": {"damage": 3}}}); Prefab.define("Knight", {"components": {"Stats": {}}, "children": ["Sword", {"prefab": "Sword", "components": {"Item": {"damage": 5}}}, {"components": {"Shield": {}}}]}); let w = World.create(); let k = w.spawn("Knight"); let c = k.children(); [len(w.entities()), len(c), c[0].get("Item").damage, c[1].get("Item").damage, c[2].has("Shield"), c[0].parent() == k]`, "[4, 3, 3, 5, true, true]"},
+		{`Prefab.define("Elf", {"components": {"Stats": {"hp": 5}}}); let g = Prefab.spawn("Elf", {"Stats": {"hp": 7}}); [g.get("Stats").hp, g.world() == world]`, "[7, true]"},
+		{`Prefab.define("Loop", {"children": ["Loop"]}); World.create().spawn("Loop")`, "prefab Loop contains itself"},
+		{`let h = {"a": 1}; h["self"] = h; Prefab.define("Cyclic", {"components": {"X": h}}); World.create().spawn("Cyclic")`, "component X: cannot copy cyclic HASH"},
+		{`let a = [1]; a.push(a); Prefab.define("Plain", {"components": {"X": {}}}); World.create().spawn("Plain", {"X": {"list": a}})`, "component X: cannot copy cyclic ARRAY"},
+		{`Prefab.define("Broken", {"children": ["Missing"]}); let w = World.create(); let result = w.spawn("Broken"); [result, len(w.entities())]`, "unknown prefab Missing"},
+		{`World.create().spawn("Nope")`, "unknown prefab Nope"},
+		{`Prefab.define("Bad", {"components": {"Stats": 1}})`, "prefab Bad: component Stats must be HASH, got INTEGER"},
+		{`Prefab.define("Bad", {"colour": "red"})`, "prefab Bad: unknown prefab field colour"},
+		{`Prefab.define("Bad", 1)`, "prefab Bad: prefab must be HASH or STRING, got INTEGER"},
+		{`Prefab.define("Named", {}); Prefab.names().contains("Named")`, true},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestHierarchy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let w = World.create(); let tank = w.spawn(); let turret = w.spawn(); turret.setParent(tank); [turret.parent() == tank, tank.children(), tank.parent()]`, "[true, [entity(2)], null]"},
+		{`let w = World.create(); let a = w.spawn(); let b = w.spawn(); let c = w.spawn(); c.setParent(a); c.setParent(b); c.setParent(null); [a.children(), b.children(), c.parent()]`, "[[], [], null]"},
+		{`let w = World.create(); let a = w.spawn(); let b = w.spawn(); let c = w.spawn(); b.setParent(a); c.setParent(b); a.destroy(); [b.alive(), c.alive(), len(w.entities())]`, "[false, false, 0]"},
+		{`let w = World.create(); let a = w.spawn(); let b = w.spawn(); b.setParent(a); b.destroy(); [a.alive(), a.children()]`, "[true, []]"},
+		{`let w = World.create(); let a = w.spawn(); let b = w.spawn(); b.setParent(a); a.setParent(b)`, "cannot make entity 1 a child of its descendant 2"},
+		{`let w = World.create(); let a = w.spawn(); a.setParent(a)`, "cannot make entity 1 a child of its descendant 1"},
+		{`let a = World.create().spawn(); a.setParent(World.create().spawn())`, "entity 1 belongs to another world"},
+		{`let w = World.create(); w.spawn().setParent(1)`, "argument to `setParent` must be ENTITY or NULL, got INTEGER"},
+		{`let w = World.create(); let e = w.spawn({"Transform": {"x": 2}}); w.tick(0); let t = e.get("Transform"); [t.worldX, t.worldY, t.scaleX]`, "[2.000000, 0.000000, 1.000000]"},
+		{`let w = World.create(); let tank = w.spawn({"Transform": {"x": 10, "y": 5, "rotation": 1.5707963267948966, "scaleX": 2.0, "scaleY": 2.0}}); let turret = w.spawn({"Transform": {"x": 1, "rotation": 0.5}}); turret.setParent(tank); w.tick(0); let t = turret.get("Transform"); [t.worldX.round(), t.worldY.round(), t.worldRotation.toFixed(2), t.worldScaleX]`, "[10, 7, 2.07, 2.000000]"},
+		{`let w = World.create(); let a = w.spawn({"Transform": {"x": 1}}); let group = w.spawn(); let b = w.spawn({"Transform": {"y": 1}}); group.setParent(a); b.setParent(group); w.tick(0); [b.get("Transform").worldX, b.get("Transform").worldY]`, "[1.000000, 1.000000]"},
+		{`let w = World.create(); w.spawn({"Transform": {"x": "left"}}); w.tick(0)`, "Transform.x of entity 1 must be a number, got STRING"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestLinearAlgebra(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`Math.Vec2(1, 2) + Math.Vec2(0.5, 3)`, "vec2(1.5, 5)"},
+		{`Math.Vec3(1, 2, 3) - Math.Vec3(1, 1, 1)`, "vec3(0, 1, 2)"},
+		{`Math.Vec3(1, 2, 3) * Math.Vec3(2, 2, 2)`, "vec3(2, 4, 6)"},
+		{`[Math.Vec2(1, 2) * 2, 0.5 * Math.Vec2(1, 2), Math.Vec2(1, 2) / 2, -Math.Vec2(1, 2)]`, "[vec2(2, 4), vec2(0.5, 1), vec2(0.5, 1), vec2(-1, -2)]"},
+		{`Math.Vec3()`, "vec3(0, 0, 0)"},
+		{`let v = Math.Vec4(1, 2, 3, 4); [v.x, v.y, v.z, v.w, v[2]]`, "[1.000000, 2.000000, 3.000000, 4.000000, 3.000000]"},
+		{`[Math.Vec2(1, 2) == Math.Vec2(1, 2), Math.Vec2(1, 2) != Math.Vec2(1, 3), Math.Vec2(1, 2) == Math.Vec3(1, 2, 0)]`, "[true, true, false]"},
+		{`[Math.dot(Math.Vec3(1, 2, 3), Math.Vec3(4, 5, 6)), Math.Vec3(1, 0, 0).cross(Math.Vec3(0, 1, 0)), Math.length(Math.Vec2(3, 4)), Math.Vec2(3, 4).normalize()]`, "[32.000000, vec3(0, 0, 1), 5.000000, vec2(0.6, 0.8)]"},
+		{`[Math.lerp(Math.Vec2(0, 0), Math.Vec2(10, 20), 0.25), Math.lerp(0, 10, 0.5)]`, "[vec2(2.5, 5), 5.000000]"},
+		{`let m = Math.Mat3(1, 0, 0, 0, 1, 0, 5, 6, 1); m * Math.Vec3(1, 1, 1)`, "vec3(6, 7, 1)"},
+		{`Math.Mat4() * Math.Mat4() == Math.Mat4()`, true},
+		{`let m = Math.Mat4([1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 3, 4, 5, 1]); [m * Math.Vec3(1, 1, 1), m.get(0, 3), m.transpose().get(3, 0), m[3]]`, "[vec3(4, 5, 6), 3.000000, 3.000000, vec4(3, 4, 5, 1)]"},
+		{`Math.Mat3() * 2`, "mat3(2, 0, 0, 0, 2, 0, 0, 0, 2)"},
+		{`let q = Math.axisAngle(Math.Vec3(0, 0, 1), 1.5707963267948966); let v = q * Math.Vec3(1, 0, 0); [v.x.round(), v.y.round(), v.z.round()]`, "[0, 1, 0]"},
+		{`let q = Math.axisAngle(Math.Vec3(0, 1, 0), 1.0); let r = q * Math.Quat(); r == q`, true},
+		{`let a = Math.Quat(); let b = Math.axisAngle(Math.Vec3(0, 0, 1), 2.0); let h = Math.slerp(a, b, 0.5); let expected = Math.axisAngle(Math.Vec3(0, 0, 1), 1.0); [(h.z - expected.z).toFixed(6), (h.w - expected.w).toFixed(6)]`, "[0.000000, 0.000000]"},
+		{`let m = Math.axisAngle(Math.Vec3(0, 0, 1), 1.5707963267948966).toMat4(); let v = m * Math.Vec3(1, 0, 0); [v.x.round(), v.y.round()]`, "[0, 1]"},
+		{`let view = Math.lookAt(Math.Vec3(0, 0, 5), Math.Vec3(0, 0, 0), Math.Vec3(0, 1, 0)); view * Math.Vec3(0, 0, 0)`, "vec3(0, 0, -5)"},
+		{`let p = Math.perspective(1.5707963267948966, 1, 1, 3); let v = p * Math.Vec3(0, 0, -1); [v.z.toFixed(3), p.get(3, 2)]`, "[-1.000, -1.000000]"},
+		{`Math.Vec2(1, 2) + Math.Vec3(1, 2, 3)`, "type mismatch: VEC2 + VEC3"},
+		{`Math.Vec2(1, 2) + 1`, "unknown operator: VEC2 + INTEGER"},
+		{`Math.Vec2(1, 2) + "a"`, "type mismatch: VEC2 + STRING"},
+		{`Math.Vec2(1, "a")`, "argument to `Vec2` must be INTEGER or FLOAT, got STRING"},
+		{`Math.cross(Math.Vec2(1, 2), Math.Vec3(1, 2, 3))`, "argument to `cross` must be VEC3, got VEC2"},
+		{`Math.Vec2(1, 2).q`, "undefined method q for VEC2"},
+		{`Math.Vec2(1, 2)[2]`, nil},
+		{`[Math.Quat()[-1], Math.Mat3()[3]]`, "[null, null]"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[Math.PI, Math.E]`, "[3.141593, 2.718282]"},
+		{`[Math.sqrt(16), Math.sqrt(2.25), Math.abs(-3), Math.abs(0.0 - 1.5)]`, "[4.000000, 1.500000, 3, 1.500000]"},
+		{`[Math.floor(1.7), Math.ceil(1.2), Math.round(2.5), Math.round(0.0 - 2.5), Math.floor(3)]`, "[1, 2, 3, -3, 3]"},
+		{`[Math.sin(0), Math.cos(0), Math.asin(1) * 2.0 == Math.PI, Math.atan2(1, 1) * 4.0 == Math.PI]`, "[0.000000, 1.000000, true, true]"},
+		{`[Math.pow(2, 10), Math.exp(0), Math.log(Math.E), Math.hypot(3, 4)]`, "[1024.000000, 1.000000, 1.000000, 5.000000]"},
+		{`[Math.min(3, 1, 2), Math.max(3, 1.5), Math.min([4, 2.5, 8]), Math.max(-1)]`, "[1, 3, 2.500000, -1]"},
+		{`[Math.clamp(5, 0, 3), Math.clamp(-1, 0, 3), Math.clamp(0.5, 0, 1), Math.clamp(2, 0.0, 1.0)]`, "[3, 0, 0.500000, 1.000000]"},
+		{`[Math.sign(-4), Math.sign(0), Math.sign(2.5), Math.lerp(2, 4, 0.5), Math.fract(1.25)]`, "[-1, 0, 1.000000, 3.000000, 0.250000]"},
+		{`let a = Math.Random(42); let b = Math.Random(42); [a.random() == b.random(), a.randomInt(100) == b.randomInt(100), a.seed()]`, "[true, true, 42]"},
+		{`let r = Math.Random(7); let ok = true; for (i, 100) { let n = r.randomInt(3, 6); if (n < 3) { ok = false }; if (5 < n) { ok = false } }; ok`, true},
+		{`let x = Math.random(); [!(x < 0.0), x < 1.0, Math.randomInt(1)]`, "[true, true, 0]"},
+		{`let r = Math.Random(1); let s = r.shuffle([1, 2, 3, 4]); [len(s), r.pick([9]), r.pick([])]`, "[4, 9, null]"},
+		{`Math.sqrt("a")`, "argument to `sqrt` must be INTEGER or FLOAT, got STRING"},
+		{`Math.floor(true)`, "argument to `floor` must be INTEGER or FLOAT, got BOOLEAN"},
+		{`Math.clamp(1, 3, 0)`, "`clamp` minimum 3 is greater than maximum 0"},
+		{`Math.min()`, "`min` needs at least one number"},
+		{`Math.randomInt(5, 5)`, "`randomInt` range [5, 5) is empty"},
+		{`let x = 2.5; [-x, -(-x), -1.5 * 2.0, Math.abs(-0.25)]`, "[-2.500000, 2.500000, -3.000000, 0.250000]"},
+		{`-true`, "unknown operator: -BOOLEAN"},
+		{`Math.round(Math.pow(10, 300))`, "result of `round` does not fit in an INTEGER: 1.0000000000000006e+300"},
+		{`Math.floor(Math.log(-1))`, "result of `floor` does not fit in an INTEGER: NaN"},
+		{`Math.ceil(Math.log(0))`, "result of `ceil` does not fit in an INTEGER: -Inf"},
+		{`Math.pow(2.0, 70).floor()`, "result of `floor` does not fit in an INTEGER: 1.1805916207174113e+21"},
+		{`Math.pow(2.0, 70).toInt()`, "result of `toInt` does not fit in an INTEGER: 1.1805916207174113e+21"},
+		{`Math.floor(-9223372036854775808.0)`, "-9223372036854775808"},
+		{`let n = Math.randomInt(-9223372036854775807, 9223372036854775807); n < 9223372036854775807`, true},
+		{`Math.Random(3).randomInt(9223372036854775806, 9223372036854775807)`, 9223372036854775806},
+		{`Math.Random("seed")`, "argument to `Random` must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestPhysics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let w = World.create(); let e = w.spawn({"Transform": {}, "RigidBody": {"vx": 2}}); w.physics().setGravity(0, -10); w.tick(0.5); [e.get("Transform").x, e.get("Transform").y, e.get("RigidBody").vy, w.physics().gravity()]`, "[1.000000, -2.500000, -5.000000, vec2(0, -10)]"},
+		{`let w = World.create(); let e = w.spawn({"Transform": {}, "RigidBody": {"vx": 2, "static": true}}); w.tick(1); e.get("Transform").x`, "0.000000"},
+		{`let w = World.create(); let a = w.spawn({"Transform": {"x": 0}, "RigidBody": {"vx": 1, "restitution": 1}, "Collider": {}}); let b = w.spawn({"Transform": {"x": 1.5}, "RigidBody": {"vx": -1}, "Collider": {}}); w.tick(0.5); [a.get("RigidBody").vx, b.get("RigidBody").vx, a.get("Transform").x, b.get("Transform").x]`, "[-1.000000, 1.000000, 0.250000, 1.250000]"},
+		{`let w = World.create(); let ball = w.spawn({"Transform": {"y": 1}, "RigidBody": {"vy": -4, "restitution": 0.5}, "Collider": {"shape": "circle", "radius": 0.5}}); w.spawn({"Transform": {"y": -0.5}, "Collider": {"width": 10, "height": 1}}); w.tick(0.25); [ball.get("Transform").y, ball.get("RigidBody").vy]`, "[0.500000, 2.000000]"},
+		{`let w = World.create(); let box = w.spawn({"Transform": {"y": 0.4}, "RigidBody": {"vx": 4, "vy": -1, "friction": 0.5}, "Collider": {}}); w.spawn({"Transform": {"y": -0.5}, "Collider": {"width": 10, "height": 1}, "RigidBody": {"static": true, "friction": 0.5}}); w.tick(0.1); let vx = box.get("RigidBody").vx; [vx < 4.0, 0.0 < vx, box.get("RigidBody").vy]`, "[true, true, 0.000000]"},
+		{`let w = World.create(); let hits = {"a": [], "b": []}; let a = w.spawn({"Transform": {}, "RigidBody": {}, "Collider": {}}); let b = w.spawn({"Transform": {"x": 0.5}, "Collider": {}}); a.on("collision", fn(e) { hits["a"] = push(hits["a"], [e.other, e.normalX, e.source]) }); b.on("collision", fn(e) { hits["b"] = push(hits["b"], [e.other, e.normalX]) }); w.tick(0.1); [hits["a"], hits["b"]]`, "[[[entity(2), 1.000000, entity(1)]], [[entity(1), -1.000000]]]"},
+		{`let w = World.create(); let seen = {"n": 0}; let a = w.spawn({"Transform": {}, "RigidBody": {"vx": 1}, "Collider": {}}); w.spawn({"Transform": {"x": 0.5}, "Collider": {"trigger": true}}); w.on("trigger", fn(e) { seen["n"] = seen["n"] + 1 }); w.on("collision", fn(e) { seen["n"] = seen["n"] + 100 }); w.tick(0.1); [seen["n"], a.get("Transform").x]`, "[2, 0.100000]"},
+		{`let w = World.create(); w.spawn({"Transform": {"x": 5}, "Collider": {}}); let far = w.spawn({"Transform": {"x": 9}, "Collider": {"shape": "circle", "radius": 1}}); w.spawn({"Transform": {"x": 2}, "Collider": {"trigger": true}}); let hit = w.physics().raycast(0, 0, 1, 0); [hit.entity, hit.x, hit.distance, hit.normalX, w.physics().raycast(0, 0, 0, 1), w.physics().raycast(6, 0, 1, 0).entity == far, w.physics().raycast(0, 0, 1, 0, 3)]`, "[entity(1), 4.500000, 4.500000, -1.000000, null, true, null]"},
+		{`let w = World.create(); w.spawn({"Transform": {}, "Collider": {}}); w.spawn({"Transform": {"x": 3}, "Collider": {"shape": "circle"}}); w.spawn({"Transform": {"x": 10}, "RigidBody": {}}); [w.physics().overlapBox(1.5, 0, 2.5, 1), w.physics().overlapCircle(3, 1, 0.75), w.physics().overlapCircle(20, 0, 1)]`, "[[entity(1), entity(2)], [entity(2)], []]"},
+		{`let run = fn() { let w = World.create(); w.physics().setGravity(0, -9.8); w.spawn({"Transform": {"y": -1}, "Collider": {"width": 20}}); for (i, 8) { w.spawn({"Transform": {"x": float(i) * 0.3, "y": i}, "RigidBody": {"restitution": 0.3, "friction": 0.2}, "Collider": {"shape": "circle", "radius": 0.4}}) }; for (i, 120) { w.tick(0.016) }; let entities = w.entities(); let total = 0.0; for (i, 8) { let total = total + entities[i + 1].get("Transform").y }; total }; run() == run()`, true},
+		{`physics.setGravity(0, -1); world.spawn({"Transform": {}, "RigidBody": {}}); world.tick(1); physics.gravity()`, "vec2(0, -1)"},
+		{`let w = World.create(); w.spawn({"Transform": {}, "Collider": {"shape": "triangle"}}); w.tick(0)`, "Collider.shape of entity 1 must be \"box\" or \"circle\", got \"triangle\""},
+		{`let w = World.create(); w.spawn({"Transform": {}, "RigidBody": {"mass": 0}}); w.tick(0)`, "RigidBody.mass of entity 1 must be positive, got 0"},
+		{`let w = World.create(); w.spawn({"Transform": {}, "RigidBody": {"vx": "fast"}}); w.tick(0)`, "RigidBody.vx of entity 1 must be a number, got STRING"},
+		{`World.create().physics().raycast(0, 0, 0, 0)`, "raycast direction must not be zero"},
+		{`let w = World.create(); w.physics().setCellSize(0.0001); let floor = w.spawn({"Transform": {}, "Collider": {"width": 100, "height": 100}}); let hits = []; floor.on("collision", fn(e) { hits.push(e.other) }); w.spawn({"Transform": {"x": 49}, "RigidBody": {}, "Collider": {}}); w.spawn({"Transform": {"x": 60}, "RigidBody": {}, "Collider": {}}); w.tick(0.1); w.tick(0); hits`, "[entity(2)]"},
+		{`let w = World.create(); w.spawn({"Transform": {"x": Math.log(-1)}, "Collider": {}}); w.tick(0)`, "Transform.x of entity 1 must be finite, got NaN"},
+		{`let w = World.create(); w.spawn({"Transform": {}, "RigidBody": {"vx": Math.pow(10.0, 308)}, "Collider": {}}); w.tick(10)`, "collider of entity 1 has moved out of bounds"},
+		{`World.create().physics().setCellSize(Math.pow(10.0, 400))`, "cell size must be a finite number that is not negative, got +Inf"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestGrid(t *testing.T) {
+	maze := "let g = Grid.parse(\"S..#\n.#.#\n...G\n\");"
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let g = Grid.create(3, 2); g.set(1, 1, "x"); [g, g.width(), g.height(), g.get(1, 1), g.get(0, 0), g.get(5, 5), g.inBounds(2, 1), g.inBounds(3, 1)]`, "[grid(3x2), 3, 2, x, 0, null, true, false]"},
+		{`let g = Grid.create(2, 2, false); g.fill(true); g.toArray()`, "[[true, true], [true, true]]"},
+		{maze + ` [g.get(0, 0), g.get(3, 0), g.width(), g.height()]`, "[S, #, 4, 3]"},
+		{"let g = Grid.parseCSV(\"1, 2, 3\n4, 5.5, wall\n\"); g.toArray()", "[[1, 2, 3], [4, 5.500000, wall]]"},
+		{`let g = Grid.create(3, 3); [g.neighbors(0, 0), g.neighbors(1, 1, true)]`, "[[[1, 0], [0, 1]], [[1, 0], [2, 1], [1, 2], [0, 1], [2, 0], [2, 2], [0, 2], [0, 0]]]"},
+		{maze + ` g.astar(0, 0, 3, 2)`, "[[0, 0], [1, 0], [2, 0], [2, 1], [2, 2], [3, 2]]"},
+		{maze + ` [g.dijkstra(0, 0, 3, 2) == null, len(g.dijkstra(0, 0, 3, 2)), g.astar(0, 0, 3, 0), g.astar(2, 2, 2, 2)]`, "[false, 6, null, [[2, 2]]]"},
+		{maze + ` [len(g.astar(0, 0, 3, 2, {"diagonal": true})), Grid.create(4, 4).astar(0, 0, 3, 3, {"diagonal": true})]`, "[6, [[0, 0], [1, 1], [2, 2], [3, 3]]]"},
+		{`let g = Grid.create(3, 3); g.set(1, 1, 9); let cost = fn(v, x, y) { if (v == 9) { return null }; 1 }; [g.astar(0, 1, 2, 1, cost), len(g.astar(0, 1, 2, 1))]`, "[[[0, 1], [0, 0], [1, 0], [2, 0], [2, 1]], 3]"},
+		{"let g = Grid.parseCSV(\"1,1,1\n1,5,1\n1,1,1\"); g.dijkstra(0, 1, 2, 1, fn(v, x, y) { v })", "[[0, 1], [0, 0], [1, 0], [2, 0], [2, 1]]"},
+		{"let g = Grid.parseCSV(\"1,9,1\n1,9,1\n1,1,1\"); let calls = {\"n\": 0}; g.astar(0, 0, 2, 0, fn(v, x, y) { calls[\"n\"] = calls[\"n\"] + 1; v }); calls[\"n\"] < 10", true},
+		{"let g = Grid.parse(\"..#\n.##\n...\"); [g.floodFill(0, 0), g.floodFill(2, 0)]", "[[[0, 0], [1, 0], [0, 1], [0, 2], [1, 2], [2, 2]], [[2, 0], [2, 1], [1, 1]]]"},
+		{`let g = Grid.create(3, 1); g.set(2, 0, 1); g.floodFill(0, 0, 7); g.toArray()`, "[[7, 7, 1]]"},
+		{"let g = Grid.parse(\"....\n.#..\n....\"); [g.lineOfSight(0, 0, 3, 0), g.lineOfSight(0, 1, 3, 1), g.lineOfSight(0, 0, 2, 2), g.lineOfSight(0, 2, 3, 2, fn(v, x, y) { x == 2 })]", "[true, false, false, false]"},
+		{"let g = Grid.parse(\"#.\n.#\"); g.lineOfSight(1, 0, 0, 1)", false},
+		{"Grid.parse(\"ab\nabc\")", "line 2 has 3 cells, want 2"},
+		{"Grid.parseCSV(\"1,2\n3\")", "invalid CSV: record on line 2: wrong number of fields"},
+		{`Grid.create(2, 2).set(2, 0, 1)`, "cell (2, 0) is outside the 2x2 grid"},
+		{`Grid.create(2, 2).astar(0, 0, 1, 1, fn(v, x, y) { -1 })`, "cost of cell (1, 0) must not be negative, got -1"},
+		{`Grid.create(2, 2).astar(0, 0, 1, 1, fn(v, x, y) { "far" })`, "cost of cell (1, 0) must be a number, null or false, got STRING"},
+		{`Grid.create(2, 2).astar(0, 0, 1, 1, {"heuristic": 2})`, "unknown option heuristic for `astar`"},
+		{`Grid.create(2, 2).get(0.5, 1)`, "argument to `get` must be INTEGER, got FLOAT"},
+		{`Grid.create(4294967296, 4294967296)`, "grid of 4294967296x4294967296 cells is larger than the maximum of 16777216 cells"},
+		{`Grid.create(4096, 4097)`, "grid of 4096x4097 cells is larger than the maximum of 16777216 cells"},
+		{`Grid.create(0, 4294967296).width()`, 0},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestBuiltinBooleans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`if (startsWith("abc", "x")) { 1 } else { 2 }`, 2},
+		{`[startsWith("abc", "a") == true, startsWith("abc", "x") != false, !startsWith("abc", "x")]`, "[true, false, true]"},
+		{`[Grid.create(1, 1).get(5, 5) == null, json.parse("null") == null, json.parse("true") == true]`, "[true, true, true]"},
+		{`let c = fn*() { yield 1 }(); c.cancel(); [c.done() == true, !c.done()]`, "[true, false]"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`input.bind("jump", ["space", "w"]); let state = fn() { [input.isDown("jump"), input.justPressed("jump"), input.justReleased("jump")] }; input.press("space"); let before = state(); world.tick(0.1); let pressed = state(); world.tick(0.1); let held = state(); input.release("space"); world.tick(0.1); let result = [before, pressed, held, state()]; result`, "[[false, false, false], [true, true, false], [true, false, false], [false, false, true]]"},
+		{`input.bind("jump", ["space", "w"]); input.press("space"); world.tick(0.1); input.press("w"); input.release("space"); world.tick(0.1); [input.isDown("jump"), input.justPressed("jump"), input.justReleased("jump")]`, "[true, false, false]"},
+		{`input.bind("fire", "x"); input.press("x"); input.release("x"); world.tick(0.1); [input.isDown("fire"), input.justPressed("fire"), input.justReleased("fire")]`, "[false, true, true]"},
+		{`input.bind("left", "a"); let seen = {"n": 0}; world.system(fn(dt, w) { if (input.isDown("left")) { seen["n"] = seen["n"] + 1 } }); input.press("a"); world.tick(0.1); world.tick(0.1); input.release("a"); world.tick(0.1); seen["n"]`, 2},
+		{`input.bind("jump", "space"); input.bind("jump", "up"); input.press("space"); world.tick(0.1); input.isDown("jump")`, false},
+		{`input.bind("jump", "space"); input.unbind("jump"); input.isDown("jump")`, "unknown input action jump"},
+		{`input.isDown("dance")`, "unknown input action dance"},
+		{`input.bind("jump", [1])`, "keys for `input.bind` must be STRING, got INTEGER"},
+		{`input.press(1)`, "argument to `input.press` must be STRING, got INTEGER"},
+	}
+	for _, tt := range tests {
+		testResult(t, tt.input, tt.expected)
+	}
+}
@@ -0,0 +1,162 @@
+package evaluator
+
+import (
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+func isLinear(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Vector, *object.Matrix, *object.Quat:
+		return true
+	}
+	return false
+}
+
+func scalarValue(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	}
+	return 0, false
+}
+
+// evalLinearInfixExpression implements the operators on vectors, matrices
+// and quaternions. Vectors of the same size add, subtract and multiply
+// component-wise; matrices multiply each other and transform vectors;
+// quaternions compose with * and rotate Vec3s. Any of them can be scaled by
+// a number, and vectors divided by one.
+func evalLinearInfixExpression(operator string, left, right object.Object) object.Object {
+	if _, ok := scalarValue(left); ok && operator == "*" {
+		left, right = right, left
+	}
+	if s, ok := scalarValue(right); ok {
+		switch left := left.(type) {
+		case *object.Vector:
+			switch operator {
+			case "*":
+				return left.Scale(s)
+			case "/":
+				return left.Scale(1 / s)
+			}
+		case *object.Matrix:
+			if operator == "*" {
+				return left.Scale(s)
+			}
+		case *object.Quat:
+			if operator == "*" {
+				return left.Scale(s)
+			}
+		}
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	switch left := left.(type) {
+	case *object.Vector:
+		if right, ok := right.(*object.Vector); ok && left.Type() == right.Type() {
+			switch operator {
+			case "+":
+				return left.Add(right)
+			case "-":
+				return left.Sub(right)
+			case "*":
+				return left.Mul(right)
+			case "==":
+				return nativeBoolToBooleanObject(left.Equal(right))
+			case "!=":
+				return nativeBoolToBooleanObject(!left.Equal(right))
+			}
+		}
+	case *object.Matrix:
+		switch right := right.(type) {
+		case *object.Matrix:
+			if left.Size == right.Size {
+				switch operator {
+				case "*":
+					return left.Mul(right)
+				case "==":
+					return nativeBoolToBooleanObject(left.Equal(right))
+				case "!=":
+					return nativeBoolToBooleanObject(!left.Equal(right))
+				}
+			}
+		case *object.Vector:
+			if operator == "*" && (len(right.Values) == left.Size || (left.Size == 4 && len(right.Values) == 3)) {
+				return left.MulVector(right)
+			}
+		}
+	case *object.Quat:
+		switch right := right.(type) {
+		case *object.Quat:
+			switch operator {
+			case "+":
+				return left.Add(right)
+			case "-":
+				return left.Sub(right)
+			case "*":
+				return left.Mul(right)
+			case "==":
+				return nativeBoolToBooleanObject(left.Equal(right))
+			case "!=":
+				return nativeBoolToBooleanObject(!left.Equal(right))
+			}
+		case *object.Vector:
+			if operator == "*" && len(right.Values) == 3 {
+				return left.Rotate(right)
+			}
+		}
+	}
+	switch operator {
+	case "==":
+		return FALSE
+	case "!=":
+		return TRUE
+	}
+	if left.Type() != right.Type() {
+		return NewError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+	return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalLinearNegation(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Vector:
+		return right.Scale(-1)
+	case *object.Matrix:
+		return right.Scale(-1)
+	case *object.Quat:
+		return right.Scale(-1)
+	}
+	return NewError("unknown operator: -%s", right.Type())
+}
+
+// evalLinearIndexExpression returns a component of a vector or quaternion,
+// v[0] being v.x, or a column of a matrix as a vector. Like arrays, an
+// index out of range reads null unless strict is set.
+func evalLinearIndexExpression(left object.Object, i int64, strict bool) object.Object {
+	var values []float64
+	switch left := left.(type) {
+	case *object.Vector:
+		values = left.Values
+	case *object.Quat:
+		values = []float64{left.X, left.Y, left.Z, left.W}
+	case *object.Matrix:
+		if i < 0 || i >= int64(left.Size) {
+			return linearIndexOutOfRange(left, i, strict)
+		}
+		column := left.Values[int(i)*left.Size : int(i+1)*left.Size]
+		return object.NewVector(append([]float64(nil), column...)...)
+	}
+	if i < 0 || i >= int64(len(values)) {
+		return linearIndexOutOfRange(left, i, strict)
+	}
+	return &object.Float{Value: values[i]}
+}
+
+func linearIndexOutOfRange(left object.Object, i int64, strict bool) object.Object {
+	if strict {
+		return NewError("index out of range: %d in %s", i, left.Type())
+	}
+	return NULL
+}
@@ -0,0 +1,25 @@
+package evaluator
+
+import (
+	"github.com/SpaceHexagon/ecs/builtins"
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// evalMethodExpression resolves value.name for values that are not hashes:
+// first as a field of values such as vectors, then by looking the name up
+// in the method table of the value's type.
+func evalMethodExpression(receiver, name object.Object, runtime *object.Runtime) object.Object {
+	key, ok := name.(*object.String)
+	if !ok {
+		return NewError("index operator not supported: %s", receiver.Type())
+	}
+	if holder, ok := receiver.(object.FieldHolder); ok {
+		if field, ok := holder.Field(key.Value); ok {
+			return field
+		}
+	}
+	if method, ok := builtins.LookupMethod(runtime, receiver, key.Value); ok {
+		return method
+	}
+	return NewError("undefined method %s for %s", key.Value, receiver.Type())
+}
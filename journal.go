@@ -0,0 +1,175 @@
+package ecs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/SpaceHexagon/ecs/builtins"
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// journalEntry is one line of a journal. Value holds the JSON encoding of
+// the value; strings that are not valid UTF-8, such as binary world files,
+// are stored in Bytes instead, and errors in Error.
+type journalEntry struct {
+	Kind  string          `json:"kind"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Bytes []byte          `json:"bytes,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// Recorder is an object.Journal that passes every value through and writes
+// it to a log, one JSON object per line.
+type Recorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewRecorder returns a journal that records to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Entry calls produce and records its result. A value that cannot be
+// written is returned as an error, since a log with a gap cannot be
+// replayed.
+func (r *Recorder) Entry(kind string, produce func() object.Object) object.Object {
+	value := produce()
+	entry := journalEntry{Kind: kind}
+	switch v := value.(type) {
+	case *object.Error:
+		entry.Error = v.Message
+	case *object.String:
+		if !utf8.ValidString(v.Value) {
+			entry.Bytes = []byte(v.Value)
+			break
+		}
+		entry.Value = encodeJournalValue(v)
+	default:
+		entry.Value = encodeJournalValue(v)
+	}
+	if entry.Value == nil && entry.Bytes == nil && entry.Error == "" {
+		return &object.Error{Message: fmt.Sprintf("cannot record %s: unsupported value %s", kind, value.Type())}
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return &object.Error{Message: fmt.Sprintf("cannot record %s: %s", kind, err)}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return &object.Error{Message: fmt.Sprintf("cannot record %s: %s", kind, err)}
+	}
+	return value
+}
+
+func encodeJournalValue(value object.Object) json.RawMessage {
+	encoded, ok := builtins.StringifyJSON(value, "").(*object.String)
+	if !ok {
+		return nil
+	}
+	return json.RawMessage(encoded.Value)
+}
+
+// Replayer is an object.Journal that returns the values of a recorded log
+// in order instead of producing new ones.
+type Replayer struct {
+	mu      sync.Mutex
+	entries []journalEntry
+	next    int
+}
+
+// NewReplayer reads a log written by a Recorder.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	var entries []journalEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if entry.Kind == "" {
+			return nil, fmt.Errorf("line %d: entry has no kind", line)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &Replayer{entries: entries}, nil
+}
+
+// Entry returns the next recorded value without calling produce. It
+// returns an error when the next entry is of another kind, which means the
+// run has diverged from the recorded one, or when the log is exhausted.
+func (r *Replayer) Entry(kind string, produce func() object.Object) object.Object {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next == len(r.entries) {
+		return &object.Error{Message: fmt.Sprintf("replay log ended before %s", kind)}
+	}
+	entry := r.entries[r.next]
+	if entry.Kind != kind {
+		return &object.Error{Message: fmt.Sprintf("replay diverged at entry %d: expected %s, got %s", r.next+1, entry.Kind, kind)}
+	}
+	r.next++
+	switch {
+	case entry.Error != "":
+		return &object.Error{Message: entry.Error}
+	case entry.Bytes != nil:
+		return &object.String{Value: string(entry.Bytes)}
+	case len(entry.Value) == 0:
+		return builtins.NULL
+	}
+	return builtins.ParseJSON(string(entry.Value))
+}
+
+// skip consumes the next entry if it is of the given kind, and reports
+// whether it did.
+func (r *Replayer) skip(kind string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next == len(r.entries) || r.entries[r.next].Kind != kind {
+		return false
+	}
+	r.next++
+	return true
+}
+
+// Remaining returns the number of entries not yet replayed. It is zero
+// after a faithful replay of a complete run.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries) - r.next
+}
+
+// SetJournal makes the interpreter take the values that differ between
+// runs, such as the time, random seeds, key events and file contents,
+// through j. Pass a Recorder to record a run and a Replayer to reproduce
+// it; nil turns journaling off.
+func (i *Interpreter) SetJournal(j object.Journal) {
+	i.env.Runtime().Journal = j
+}
+
+// Journaled returns produce() through the interpreter's journal, so hosts
+// can record and replay their own nondeterministic values.
+func (i *Interpreter) Journaled(kind string, produce func() object.Object) object.Object {
+	if j := i.env.Runtime().Journal; j != nil {
+		return j.Entry(kind, produce)
+	}
+	return produce()
+}
+
+func (i *Interpreter) replaying() bool {
+	_, ok := i.env.Runtime().Journal.(*Replayer)
+	return ok
+}
@@ -212,3 +212,47 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestIdentifiersWithDigits(t *testing.T) {
+	l := New(`Math.Vec2(x1, 2)`)
+	expected := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "Math"},
+		{token.DOT, "."},
+		{token.IDENT, "Vec2"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RPAREN, ")"},
+	}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	l := New("let x = 5;\n  if (x) {\n\tprint(\"hi\")\n}")
+	expected := []struct {
+		literal      string
+		line, column int
+	}{
+		{"let", 1, 1}, {"x", 1, 5}, {"=", 1, 7}, {"5", 1, 9}, {";", 1, 10},
+		{"if", 2, 3}, {"(", 2, 6}, {"x", 2, 7}, {")", 2, 8}, {"{", 2, 10},
+		{"print", 3, 2}, {"(", 3, 7}, {"hi", 3, 8}, {")", 3, 12},
+		{"}", 4, 1}, {"", 4, 2},
+	}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Literal != tt.literal || tok.Line != tt.line || tok.Column != tt.column {
+			t.Fatalf("tests[%d] - wrong position. expected=%q at %d:%d, got=%q at %d:%d",
+				i, tt.literal, tt.line, tt.column, tok.Literal, tok.Line, tok.Column)
+		}
+	}
+}
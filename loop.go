@@ -0,0 +1,172 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/builtins"
+	"github.com/SpaceHexagon/ecs/evaluator"
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// LoopConfig configures RunLoop.
+type LoopConfig struct {
+	// Step is the fixed timestep the world is ticked with. The default is
+	// 1/60 of a second.
+	Step time.Duration
+
+	// MaxCatchUp is the most ticks run in one frame when the loop has
+	// fallen behind. Time beyond that is dropped so a slow frame cannot
+	// make the next one slower. The default is 5.
+	MaxCatchUp int
+
+	// Frames, if positive, runs that many frames headless: every frame
+	// ticks exactly once without waiting for the wall clock, so the run is
+	// deterministic.
+	Frames int64
+}
+
+// LoopStats describes a finished RunLoop.
+type LoopStats struct {
+	// Frames is the number of frames rendered and Ticks the number of
+	// fixed steps the world was advanced by.
+	Frames int64
+	Ticks  int64
+
+	// Elapsed is the wall time the loop ran for, and Dropped the part of
+	// it that was skipped because of MaxCatchUp.
+	Elapsed time.Duration
+	Dropped time.Duration
+}
+
+// RunLoop drives the default world with a fixed timestep until ctx is done,
+// the script calls world.stop() or config.Frames frames have run. A stop
+// from before the loop started is forgotten, so a world stopped by one
+// RunLoop can be driven by the next. After the
+// ticks of each frame it calls the world.render callbacks with the
+// interpolation factor. Cancelling ctx is a clean shutdown, not an error.
+//
+// The wall time of each frame goes through the journal. When replaying,
+// frames take their recorded times and the loop does not wait. A loop
+// ended by ctx journals a stop entry, so its replay ends at the same frame.
+func (i *Interpreter) RunLoop(ctx context.Context, config LoopConfig) (LoopStats, error) {
+	if config.Step <= 0 {
+		config.Step = time.Second / 60
+	}
+	if config.MaxCatchUp <= 0 {
+		config.MaxCatchUp = 5
+	}
+	obj, ok := i.env.Runtime().Builtins["world"]
+	world, isWorld := obj.(*builtins.World)
+	if !ok || !isWorld {
+		return LoopStats{}, fmt.Errorf("interpreter has no world")
+	}
+
+	world.Resume()
+	var stats LoopStats
+	start := time.Now()
+	previous := start
+	var accumulator time.Duration
+	for !world.Stopped() && (config.Frames <= 0 || stats.Frames < config.Frames) {
+		if i.stopping(ctx) {
+			break
+		}
+		if config.Frames > 0 {
+			accumulator += config.Step
+		} else {
+			elapsed, err := i.frameTime(&previous)
+			if err != nil {
+				return i.loopResult(ctx, stats, start, err)
+			}
+			accumulator += elapsed
+		}
+		for ticks := 0; accumulator >= config.Step; ticks++ {
+			if ticks == config.MaxCatchUp {
+				dropped := accumulator - accumulator%config.Step
+				stats.Dropped += dropped
+				accumulator -= dropped
+				break
+			}
+			if err := i.TickContext(ctx, config.Step); err != nil {
+				return i.loopResult(ctx, stats, start, err)
+			}
+			accumulator -= config.Step
+			stats.Ticks++
+		}
+		alpha := &object.Float{Value: float64(accumulator) / float64(config.Step)}
+		if err := i.render(ctx, world, alpha); err != nil {
+			return i.loopResult(ctx, stats, start, err)
+		}
+		stats.Frames++
+		if config.Frames <= 0 && !i.replaying() {
+			wait(ctx, config.Step-accumulator)
+		}
+	}
+	return i.loopResult(ctx, stats, start, nil)
+}
+
+// stopping reports whether the loop should end before the next frame:
+// when ctx is done, which is journaled as a "stop" entry, or when replaying
+// and the recorded run stopped there.
+func (i *Interpreter) stopping(ctx context.Context) bool {
+	if replayer, ok := i.env.Runtime().Journal.(*Replayer); ok {
+		return replayer.skip("stop") || ctx.Err() != nil
+	}
+	if ctx.Err() == nil {
+		return false
+	}
+	i.Journaled("stop", func() object.Object { return builtins.NULL })
+	return true
+}
+
+// frameTime returns the wall time since previous, or the recorded time of
+// the frame when replaying, and advances previous.
+func (i *Interpreter) frameTime(previous *time.Time) (time.Duration, error) {
+	elapsed := i.Journaled("frame", func() object.Object {
+		now := time.Now()
+		elapsed := now.Sub(*previous)
+		*previous = now
+		return &object.Integer{Value: int64(elapsed)}
+	})
+	switch elapsed := elapsed.(type) {
+	case *object.Integer:
+		return time.Duration(elapsed.Value), nil
+	case *object.Error:
+		return 0, &RuntimeError{Err: elapsed}
+	}
+	return 0, fmt.Errorf("journal returned %s for a frame time", elapsed.Type())
+}
+
+func (i *Interpreter) render(ctx context.Context, world *builtins.World, alpha object.Object) error {
+	renderers := world.Renderers()
+	if len(renderers) == 0 {
+		return nil
+	}
+	defer i.env.Runtime().BeginRun(ctx)()
+	for _, fn := range renderers {
+		if _, err := result(evaluator.ApplyFunction(fn, []object.Object{alpha}, i.env)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loopResult finishes the stats and hides the error a tick returns when it
+// is interrupted by ctx being cancelled.
+func (i *Interpreter) loopResult(ctx context.Context, stats LoopStats, start time.Time, err error) (LoopStats, error) {
+	stats.Elapsed = time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		err = nil
+	}
+	return stats, err
+}
+
+func wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
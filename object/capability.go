@@ -0,0 +1,43 @@
+package object
+
+import "fmt"
+
+// Capability names a group of builtins that reach outside the interpreter.
+// Programs can only use a capability the host has granted.
+type Capability string
+
+const (
+	FS_CAPABILITY       Capability = "fs"
+	TERMINAL_CAPABILITY Capability = "terminal"
+	TIME_CAPABILITY     Capability = "time"
+	EXEC_CAPABILITY     Capability = "exec"
+)
+
+// Capabilities lists every capability in the order they are documented.
+var Capabilities = []Capability{FS_CAPABILITY, TERMINAL_CAPABILITY, TIME_CAPABILITY, EXEC_CAPABILITY}
+
+// Grant allows the program to use the given capabilities.
+func (r *Runtime) Grant(capabilities ...Capability) {
+	if r.granted == nil {
+		r.granted = map[Capability]bool{}
+	}
+	for _, capability := range capabilities {
+		r.granted[capability] = true
+	}
+}
+
+// Allowed reports whether capability has been granted.
+func (r *Runtime) Allowed(capability Capability) bool {
+	return r.granted[capability]
+}
+
+// Require returns a permission error unless capability has been granted.
+func (r *Runtime) Require(capability Capability) *Error {
+	if r.Allowed(capability) {
+		return nil
+	}
+	return &Error{
+		Message: fmt.Sprintf("permission denied: %s capability not granted", capability),
+		Kind:    PERMISSION_ERROR,
+	}
+}
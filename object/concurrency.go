@@ -0,0 +1,90 @@
+package object
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	TASK_OBJ    = "TASK"
+	CHANNEL_OBJ = "CHANNEL"
+)
+
+// Task is the handle returned by spawn. Its result is available once Done
+// is closed.
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+func NewTask() *Task {
+	return &Task{done: make(chan struct{})}
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string {
+	select {
+	case <-t.done:
+		return "task(done)"
+	default:
+		return "task(running)"
+	}
+}
+
+// Complete records the task's result and wakes everything waiting on it.
+// It must be called exactly once.
+func (t *Task) Complete(result Object) {
+	t.result = result
+	close(t.done)
+}
+
+// Done is closed when the task has finished.
+func (t *Task) Done() <-chan struct{} { return t.done }
+
+// Result returns the value the task finished with, or nil while it is still
+// running.
+func (t *Task) Result() Object {
+	select {
+	case <-t.done:
+		return t.result
+	default:
+		return nil
+	}
+}
+
+// Channel passes values between tasks.
+type Channel struct {
+	C chan Object
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{C: make(chan Object, capacity)}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string {
+	return fmt.Sprintf("chan(%d/%d)", len(c.C), cap(c.C))
+}
+
+// Close closes the channel. Receivers drain buffered values and then get
+// null. It returns false if the channel was already closed.
+func (c *Channel) Close() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.closed = true
+	close(c.C)
+	return true
+}
+
+// Closed reports whether Close has been called.
+func (c *Channel) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
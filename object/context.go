@@ -0,0 +1,70 @@
+package object
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// CallContext describes the call site of a builtin. The evaluator passes a
+// new one to every builtin call; Go code calling a builtin directly may pass
+// nil if the builtin does not need it.
+type CallContext struct {
+	// Env is the environment the builtin was called from.
+	Env *Environment
+
+	// This is the hash the builtin was called on, as in obj.method(), or nil.
+	This *Hash
+
+	// Interpreter is the host handle stored in Runtime.Host.
+	Interpreter interface{}
+
+	// Out and Err are where the builtin should write output and
+	// diagnostics.
+	Out io.Writer
+	Err io.Writer
+
+	// Context is cancelled when the program should stop.
+	Context context.Context
+
+	// Journal is Runtime.Journal. Builtins should obtain clocks, seeds and
+	// other values that vary between runs through it when it is set.
+	Journal Journal
+}
+
+// In returns the reader the builtin should read input from, or nil if there
+// is none.
+func (c *CallContext) In() *bufio.Reader {
+	if c.Env == nil {
+		return nil
+	}
+	return c.Env.Runtime().Input()
+}
+
+// Applier calls fn with args as if from code running in env with this
+// bound. The evaluator registers one with SetApplier.
+type Applier func(fn Object, args []Object, env *Environment, this *Hash) Object
+
+var applier Applier
+
+// SetApplier installs the function used by CallContext.Call.
+func SetApplier(a Applier) {
+	applier = a
+}
+
+// Call calls a function or builtin from Go, so builtins can take callbacks
+// written in script.
+func (c *CallContext) Call(fn Object, args ...Object) Object {
+	return applier(fn, args, c.Env, c.This)
+}
+
+// StartCoroutine returns a suspended coroutine that calls fn with args when
+// first resumed. fn, and everything it calls, may use yield and wait.
+func (c *CallContext) StartCoroutine(fn Object, args ...Object) *Coroutine {
+	env, this := c.Env, c.This
+	return NewCoroutine(func(co *Coroutine) Object {
+		callEnv := NewEnclosedEnvironment(env)
+		callEnv.coroutine = co
+		return applier(fn, args, callEnv, this)
+	})
+}
@@ -0,0 +1,171 @@
+package object
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	COROUTINE_OBJ = "COROUTINE"
+	WAIT_OBJ      = "WAIT"
+)
+
+// Coroutine is a function that can suspend itself with yield and be resumed
+// later. Its body runs on its own goroutine, but control is handed back and
+// forth so that the coroutine and its resumer never run at the same time.
+//
+// A suspended coroutine holds its goroutine until it is resumed to the end
+// or cancelled; one that is dropped while suspended leaks the goroutine, so
+// owners such as worlds call Cancel on the coroutines they give up.
+type Coroutine struct {
+	body      func(co *Coroutine) Object
+	resume    chan Object
+	yield     chan coroutineResult
+	stop      chan struct{}
+	exited    chan struct{}
+	started   bool
+	running   bool
+	finished  bool
+	cancelled bool
+}
+
+type coroutineResult struct {
+	value Object
+	done  bool
+}
+
+// NewCoroutine returns a suspended coroutine that runs body when it is
+// first resumed.
+func NewCoroutine(body func(co *Coroutine) Object) *Coroutine {
+	return &Coroutine{
+		body:   body,
+		resume: make(chan Object),
+		yield:  make(chan coroutineResult),
+		stop:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+func (c *Coroutine) Type() ObjectType { return COROUTINE_OBJ }
+func (c *Coroutine) Inspect() string {
+	switch {
+	case c.finished:
+		return "coroutine(done)"
+	case c.running:
+		return "coroutine(running)"
+	case c.started:
+		return "coroutine(suspended)"
+	default:
+		return "coroutine(new)"
+	}
+}
+
+// Resume runs the coroutine until it yields or finishes and returns the
+// yielded or returned value. value becomes the result of the pending yield;
+// it is ignored on the first resume. A coroutine cancelled while running
+// returns null. If ctx, which may be nil, is cancelled first, the coroutine
+// is cancelled and Resume returns the limit error of ctx's runtime.
+func (c *Coroutine) Resume(ctx *CallContext, value Object) Object {
+	if c.finished {
+		return &Error{Message: "cannot resume finished coroutine"}
+	}
+	if c.running {
+		return &Error{Message: "coroutine is running"}
+	}
+	c.running = true
+	defer func() { c.running = false }()
+	if !c.started {
+		c.started = true
+		go c.run()
+	} else {
+		c.resume <- value
+	}
+	var done <-chan struct{}
+	if ctx != nil && ctx.Context != nil {
+		done = ctx.Context.Done()
+	}
+	select {
+	case result := <-c.yield:
+		if result.done {
+			c.finished = true
+		}
+		if c.cancelled {
+			return NULL
+		}
+		return result.value
+	case <-c.exited:
+		c.finished = true
+		return NULL
+	case <-done:
+		c.Cancel()
+		return ctx.Env.Runtime().ContextError(ctx.Context)
+	}
+}
+
+func (c *Coroutine) run() {
+	defer close(c.exited)
+	var result Object
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result = &Error{Message: fmt.Sprintf("coroutine failed: %v", r)}
+			}
+		}()
+		result = c.body(c)
+	}()
+	select {
+	case c.yield <- coroutineResult{value: result, done: true}:
+	case <-c.stop:
+	}
+}
+
+// Yield is called from the coroutine's body to hand value to the resumer
+// and wait to be resumed. It returns false if the coroutine was cancelled,
+// in which case the body should return.
+func (c *Coroutine) Yield(value Object) (Object, bool) {
+	select {
+	case <-c.stop:
+		return nil, false
+	default:
+	}
+	select {
+	case c.yield <- coroutineResult{value: value}:
+	case <-c.stop:
+		return nil, false
+	}
+	select {
+	case resumed := <-c.resume:
+		return resumed, true
+	case <-c.stop:
+		return nil, false
+	}
+}
+
+// Cancel stops the coroutine. A suspended coroutine unwinds with an error
+// and its goroutine exits; a coroutine that cancels itself stops at its
+// next yield. Cancel must be called from the resumer or the coroutine
+// itself, never from an unrelated goroutine.
+func (c *Coroutine) Cancel() {
+	if c.finished {
+		return
+	}
+	c.finished = true
+	c.cancelled = true
+	if c.started {
+		close(c.stop)
+	}
+}
+
+// Done reports whether the coroutine has finished or was cancelled.
+func (c *Coroutine) Done() bool { return c.finished }
+
+// Wait is yielded by wait(seconds). Schedulers such as the world's tick
+// resume the coroutine once that much game time has passed.
+type Wait struct {
+	Seconds float64
+}
+
+func (w *Wait) Type() ObjectType { return WAIT_OBJ }
+func (w *Wait) Inspect() string {
+	return fmt.Sprintf("wait(%s)", strconv.FormatFloat(w.Seconds, 'g', -1, 64))
+}
@@ -0,0 +1,18 @@
+package object
+
+import "github.com/SpaceHexagon/ecs/ast"
+
+// Debugger follows a program as the evaluator runs it, so it can stop the
+// program and let the user look around. The evaluator calls it on the
+// goroutine running the code, and the program waits while a method runs.
+type Debugger interface {
+	// Statement is called before each statement other than a block is
+	// evaluated, with the environment and this it is evaluated with.
+	// Returning an error aborts the program with that error.
+	Statement(stmt ast.Statement, env *Environment, this *Hash) *Error
+
+	// Call is called when a script function is entered, with the
+	// environment holding its parameters, and Return when it returns.
+	Call(fn *Function, env *Environment)
+	Return(fn *Function)
+}
@@ -1,29 +1,101 @@
 package object
 
+import "sort"
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, runtime: newRuntime()}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: outer, runtime: outer.runtime}
+}
+
+// NewCallEnvironment returns the environment for a call of a function that
+// was defined in outer, made by code running in caller. The call is one
+// level deeper than caller and runs in caller's coroutine.
+func NewCallEnvironment(outer, caller *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	if caller != nil {
+		env.depth = caller.depth + 1
+		env.coroutine = caller.coroutine
+	}
 	return env
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store     map[string]Object
+	outer     *Environment
+	runtime   *Runtime
+	coroutine *Coroutine
+	depth     int
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	if e.runtime.Concurrent() {
+		e.runtime.envMu.RLock()
+		defer e.runtime.envMu.RUnlock()
+	}
+	return e.get(name)
+}
+
+func (e *Environment) get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+		obj, ok = e.outer.get(name)
 	}
 	return obj, ok
 }
+
 func (e *Environment) Set(name string, val Object) Object {
+	if e.runtime.Concurrent() {
+		e.runtime.envMu.Lock()
+		defer e.runtime.envMu.Unlock()
+	}
 	e.store[name] = val
 	return val
 }
+
+// Outer returns the enclosing environment, or nil for a global one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the sorted names of the variables defined in the
+// environment itself, not in the enclosing ones.
+func (e *Environment) Names() []string {
+	if e.runtime.Concurrent() {
+		e.runtime.envMu.RLock()
+		defer e.runtime.envMu.RUnlock()
+	}
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Depth returns the number of function calls active when the environment
+// was created.
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+// Coroutine returns the coroutine the environment's code is running in, or
+// nil. Unlike variables it is not inherited from enclosing environments;
+// the evaluator passes it from caller to callee instead.
+func (e *Environment) Coroutine() *Coroutine {
+	return e.coroutine
+}
+
+// SetCoroutine records the coroutine the environment's code is running in.
+func (e *Environment) SetCoroutine(co *Coroutine) {
+	e.coroutine = co
+}
+
+// Runtime returns the settings shared with the enclosing environments.
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
@@ -0,0 +1,14 @@
+package object
+
+// Journal stands between a program and the values it observes that can
+// differ from one run to the next: clocks, random seeds, key events, stdin
+// and file contents. Builtins obtain each such value through Entry, so a
+// journal can record the values of one run and hand them back in order in a
+// later run, which then behaves exactly the same.
+type Journal interface {
+	// Entry returns the next value of the given kind. A recording journal
+	// calls produce and stores its result; a replaying one returns the
+	// stored value without calling produce, or an error if the program
+	// asked for something else than it did when recorded.
+	Entry(kind string, produce func() Object) Object
+}
@@ -0,0 +1,148 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Limits bound a single run of a program. A zero value means no limit.
+type Limits struct {
+	// MaxSteps is the number of AST nodes that may be evaluated.
+	MaxSteps int64
+	// Timeout is the wall-clock time a run may take.
+	Timeout time.Duration
+	// MaxDepth is the number of nested function calls.
+	MaxDepth int
+	// MaxAllocations is the number of objects a run may create.
+	MaxAllocations int64
+}
+
+// contextCheckInterval is how many steps pass between checks of the run's
+// context, which is too slow to check on every step.
+const contextCheckInterval = 256
+
+func newLimitError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: LIMIT_ERROR}
+}
+
+// BeginRun starts a run: it resets the counters checked against Limits and
+// derives the run's context from ctx, applying Limits.Timeout. Runs started
+// while another is in progress, such as a host calling back into script from
+// a builtin, share the outer run's counters and context. The returned
+// function must be called when the run ends; it cancels the run's context
+// and waits for the tasks started with Go, so no task outlives its run.
+func (r *Runtime) BeginRun(ctx context.Context) func() {
+	if atomic.AddInt32(&r.runs, 1) > 1 {
+		return func() { atomic.AddInt32(&r.runs, -1) }
+	}
+	var cancel context.CancelFunc
+	if r.Limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.Limits.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	r.contextMu.Lock()
+	previous := r.Context
+	r.Context = ctx
+	r.contextMu.Unlock()
+	atomic.StoreInt64(&r.steps, 0)
+	atomic.StoreInt64(&r.allocations, 0)
+	return func() {
+		cancel()
+		r.tasks.Wait()
+		r.contextMu.Lock()
+		r.Context = previous
+		r.contextMu.Unlock()
+		atomic.AddInt32(&r.runs, -1)
+	}
+}
+
+// Go runs fn on a new goroutine that belongs to the current run, which
+// waits for it to return once the run's context is cancelled. fn must stop
+// soon after the run's context is done.
+func (r *Runtime) Go(fn func()) {
+	r.tasks.Add(1)
+	go func() {
+		defer r.tasks.Done()
+		fn()
+	}()
+}
+
+// RunContext returns the context of the current run. It is safe to call
+// from spawned tasks.
+func (r *Runtime) RunContext() context.Context {
+	r.contextMu.RLock()
+	defer r.contextMu.RUnlock()
+	if r.Context == nil {
+		return context.Background()
+	}
+	return r.Context
+}
+
+// Step counts the evaluation of one node. It returns a limit error when the
+// step budget is used up or the run's context is done.
+func (r *Runtime) Step() *Error {
+	steps := atomic.AddInt64(&r.steps, 1)
+	if r.Limits.MaxSteps > 0 && steps > r.Limits.MaxSteps {
+		return newLimitError("step limit of %d exceeded", r.Limits.MaxSteps)
+	}
+	if steps%contextCheckInterval == 0 {
+		return r.CheckContext()
+	}
+	return nil
+}
+
+// CheckContext returns a limit error if the run's context is done.
+func (r *Runtime) CheckContext() *Error {
+	ctx := r.RunContext()
+	select {
+	case <-ctx.Done():
+		return r.ContextError(ctx)
+	default:
+		return nil
+	}
+}
+
+// Sleep waits for d or until the run's context is done, whichever comes
+// first.
+func (r *Runtime) Sleep(d time.Duration) *Error {
+	ctx := r.RunContext()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return r.ContextError(ctx)
+	}
+}
+
+// ContextError returns the limit error reported when ctx is done.
+func (r *Runtime) ContextError(ctx context.Context) *Error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) && r.Limits.Timeout > 0 {
+		return newLimitError("timeout of %s exceeded", r.Limits.Timeout)
+	}
+	return newLimitError("execution cancelled: %s", err)
+}
+
+// CheckDepth returns a limit error if a call at the given depth, as reported
+// by Environment.Depth, would exceed Limits.MaxDepth.
+func (r *Runtime) CheckDepth(depth int) *Error {
+	if r.Limits.MaxDepth > 0 && depth > r.Limits.MaxDepth {
+		return newLimitError("call depth limit of %d exceeded", r.Limits.MaxDepth)
+	}
+	return nil
+}
+
+// Allocate counts n newly created objects.
+func (r *Runtime) Allocate(n int64) *Error {
+	allocations := atomic.AddInt64(&r.allocations, n)
+	if r.Limits.MaxAllocations > 0 && allocations > r.Limits.MaxAllocations {
+		return newLimitError("allocation limit of %d objects exceeded", r.Limits.MaxAllocations)
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+package object
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	VEC2_OBJ = "VEC2"
+	VEC3_OBJ = "VEC3"
+	VEC4_OBJ = "VEC4"
+	MAT3_OBJ = "MAT3"
+	MAT4_OBJ = "MAT4"
+	QUAT_OBJ = "QUAT"
+)
+
+// FieldHolder is implemented by values with named read-only fields, such
+// as the x, y, z and w of vectors and quaternions or the functions of a
+// builtin that is also a module.
+type FieldHolder interface {
+	Field(name string) (Object, bool)
+}
+
+// Vector is an immutable Vec2, Vec3 or Vec4. Operations on vectors return
+// new vectors.
+type Vector struct {
+	Values []float64
+}
+
+// NewVector returns a vector with 2, 3 or 4 components.
+func NewVector(values ...float64) *Vector {
+	return &Vector{Values: values}
+}
+
+func (v *Vector) Type() ObjectType {
+	switch len(v.Values) {
+	case 2:
+		return VEC2_OBJ
+	case 3:
+		return VEC3_OBJ
+	default:
+		return VEC4_OBJ
+	}
+}
+func (v *Vector) Inspect() string {
+	return "vec" + strconv.Itoa(len(v.Values)) + "(" + formatFloats(v.Values) + ")"
+}
+
+var fieldNames = map[string]int{"x": 0, "y": 1, "z": 2, "w": 3}
+
+func (v *Vector) Field(name string) (Object, bool) {
+	i, ok := fieldNames[name]
+	if !ok || i >= len(v.Values) {
+		return nil, false
+	}
+	return &Float{Value: v.Values[i]}, true
+}
+
+func (v *Vector) zip(other *Vector, f func(a, b float64) float64) *Vector {
+	values := make([]float64, len(v.Values))
+	for i := range values {
+		values[i] = f(v.Values[i], other.Values[i])
+	}
+	return &Vector{Values: values}
+}
+
+func (v *Vector) Add(other *Vector) *Vector {
+	return v.zip(other, func(a, b float64) float64 { return a + b })
+}
+
+func (v *Vector) Sub(other *Vector) *Vector {
+	return v.zip(other, func(a, b float64) float64 { return a - b })
+}
+
+// Mul multiplies two vectors component by component.
+func (v *Vector) Mul(other *Vector) *Vector {
+	return v.zip(other, func(a, b float64) float64 { return a * b })
+}
+
+func (v *Vector) Scale(s float64) *Vector {
+	return v.zip(v, func(a, _ float64) float64 { return a * s })
+}
+
+func (v *Vector) Dot(other *Vector) float64 {
+	sum := 0.0
+	for i := range v.Values {
+		sum += v.Values[i] * other.Values[i]
+	}
+	return sum
+}
+
+// Cross returns the cross product of two Vec3s.
+func (v *Vector) Cross(other *Vector) *Vector {
+	a, b := v.Values, other.Values
+	return NewVector(a[1]*b[2]-a[2]*b[1], a[2]*b[0]-a[0]*b[2], a[0]*b[1]-a[1]*b[0])
+}
+
+func (v *Vector) Length() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Normalize returns v scaled to length 1, or v itself if it has length 0.
+func (v *Vector) Normalize() *Vector {
+	length := v.Length()
+	if length == 0 {
+		return v
+	}
+	return v.zip(v, func(a, _ float64) float64 { return a / length })
+}
+
+// Lerp interpolates linearly from v, at t = 0, to other, at t = 1.
+func (v *Vector) Lerp(other *Vector, t float64) *Vector {
+	return v.zip(other, func(a, b float64) float64 { return a + (b-a)*t })
+}
+
+func (v *Vector) Equal(other *Vector) bool {
+	if len(v.Values) != len(other.Values) {
+		return false
+	}
+	for i := range v.Values {
+		if v.Values[i] != other.Values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Matrix is an immutable Mat3 or Mat4 stored in column-major order, so
+// Values[col*Size+row] is the element in row, col.
+type Matrix struct {
+	Size   int
+	Values []float64
+}
+
+// IdentityMatrix returns the size by size identity matrix.
+func IdentityMatrix(size int) *Matrix {
+	m := &Matrix{Size: size, Values: make([]float64, size*size)}
+	for i := 0; i < size; i++ {
+		m.Values[i*size+i] = 1
+	}
+	return m
+}
+
+func (m *Matrix) Type() ObjectType {
+	if m.Size == 3 {
+		return MAT3_OBJ
+	}
+	return MAT4_OBJ
+}
+func (m *Matrix) Inspect() string {
+	return "mat" + strconv.Itoa(m.Size) + "(" + formatFloats(m.Values) + ")"
+}
+
+// At returns the element in row, col.
+func (m *Matrix) At(row, col int) float64 {
+	return m.Values[col*m.Size+row]
+}
+
+func (m *Matrix) Mul(other *Matrix) *Matrix {
+	n := m.Size
+	result := &Matrix{Size: n, Values: make([]float64, n*n)}
+	for col := 0; col < n; col++ {
+		for row := 0; row < n; row++ {
+			sum := 0.0
+			for k := 0; k < n; k++ {
+				sum += m.At(row, k) * other.At(k, col)
+			}
+			result.Values[col*n+row] = sum
+		}
+	}
+	return result
+}
+
+// MulVector transforms v. A Vec3 multiplied by a Mat4 is treated as a
+// point: it is extended with w = 1 and the result divided by w.
+func (m *Matrix) MulVector(v *Vector) *Vector {
+	in := v.Values
+	point := m.Size == 4 && len(in) == 3
+	if point {
+		in = []float64{in[0], in[1], in[2], 1}
+	}
+	out := make([]float64, m.Size)
+	for row := range out {
+		for col := 0; col < m.Size; col++ {
+			out[row] += m.At(row, col) * in[col]
+		}
+	}
+	if point {
+		if out[3] != 0 && out[3] != 1 {
+			return NewVector(out[0]/out[3], out[1]/out[3], out[2]/out[3])
+		}
+		return NewVector(out[0], out[1], out[2])
+	}
+	return &Vector{Values: out}
+}
+
+func (m *Matrix) Scale(s float64) *Matrix {
+	result := &Matrix{Size: m.Size, Values: make([]float64, len(m.Values))}
+	for i, value := range m.Values {
+		result.Values[i] = value * s
+	}
+	return result
+}
+
+func (m *Matrix) Transpose() *Matrix {
+	result := &Matrix{Size: m.Size, Values: make([]float64, len(m.Values))}
+	for row := 0; row < m.Size; row++ {
+		for col := 0; col < m.Size; col++ {
+			result.Values[row*m.Size+col] = m.At(row, col)
+		}
+	}
+	return result
+}
+
+func (m *Matrix) Equal(other *Matrix) bool {
+	if m.Size != other.Size {
+		return false
+	}
+	for i := range m.Values {
+		if m.Values[i] != other.Values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LookAt returns a right-handed view matrix for a camera at eye looking at
+// target.
+func LookAt(eye, target, up *Vector) *Matrix {
+	f := target.Sub(eye).Normalize()
+	s := f.Cross(up).Normalize()
+	u := s.Cross(f)
+	return &Matrix{Size: 4, Values: []float64{
+		s.Values[0], u.Values[0], -f.Values[0], 0,
+		s.Values[1], u.Values[1], -f.Values[1], 0,
+		s.Values[2], u.Values[2], -f.Values[2], 0,
+		-s.Dot(eye), -u.Dot(eye), f.Dot(eye), 1,
+	}}
+}
+
+// Perspective returns a right-handed projection matrix with clip space z
+// from -1 to 1. fovy is the vertical field of view in radians.
+func Perspective(fovy, aspect, near, far float64) *Matrix {
+	f := 1 / math.Tan(fovy/2)
+	return &Matrix{Size: 4, Values: []float64{
+		f / aspect, 0, 0, 0,
+		0, f, 0, 0,
+		0, 0, (far + near) / (near - far), -1,
+		0, 0, 2 * far * near / (near - far), 0,
+	}}
+}
+
+// Quat is an immutable quaternion x*i + y*j + z*k + w. Unit quaternions
+// represent rotations.
+type Quat struct {
+	X, Y, Z, W float64
+}
+
+// AxisAngle returns the rotation by angle radians about axis.
+func AxisAngle(axis *Vector, angle float64) *Quat {
+	a := axis.Normalize()
+	sin, cos := math.Sincos(angle / 2)
+	return &Quat{X: a.Values[0] * sin, Y: a.Values[1] * sin, Z: a.Values[2] * sin, W: cos}
+}
+
+func (q *Quat) Type() ObjectType { return QUAT_OBJ }
+func (q *Quat) Inspect() string {
+	return "quat(" + formatFloats([]float64{q.X, q.Y, q.Z, q.W}) + ")"
+}
+
+func (q *Quat) Field(name string) (Object, bool) {
+	switch name {
+	case "x":
+		return &Float{Value: q.X}, true
+	case "y":
+		return &Float{Value: q.Y}, true
+	case "z":
+		return &Float{Value: q.Z}, true
+	case "w":
+		return &Float{Value: q.W}, true
+	}
+	return nil, false
+}
+
+func (q *Quat) Add(r *Quat) *Quat {
+	return &Quat{X: q.X + r.X, Y: q.Y + r.Y, Z: q.Z + r.Z, W: q.W + r.W}
+}
+
+func (q *Quat) Sub(r *Quat) *Quat {
+	return &Quat{X: q.X - r.X, Y: q.Y - r.Y, Z: q.Z - r.Z, W: q.W - r.W}
+}
+
+// Mul returns the rotation r followed by q.
+func (q *Quat) Mul(r *Quat) *Quat {
+	return &Quat{
+		X: q.W*r.X + q.X*r.W + q.Y*r.Z - q.Z*r.Y,
+		Y: q.W*r.Y - q.X*r.Z + q.Y*r.W + q.Z*r.X,
+		Z: q.W*r.Z + q.X*r.Y - q.Y*r.X + q.Z*r.W,
+		W: q.W*r.W - q.X*r.X - q.Y*r.Y - q.Z*r.Z,
+	}
+}
+
+func (q *Quat) Scale(s float64) *Quat {
+	return &Quat{X: q.X * s, Y: q.Y * s, Z: q.Z * s, W: q.W * s}
+}
+
+func (q *Quat) Dot(r *Quat) float64 {
+	return q.X*r.X + q.Y*r.Y + q.Z*r.Z + q.W*r.W
+}
+
+func (q *Quat) Length() float64 {
+	return math.Sqrt(q.Dot(q))
+}
+
+func (q *Quat) Normalize() *Quat {
+	length := q.Length()
+	if length == 0 {
+		return q
+	}
+	return q.Scale(1 / length)
+}
+
+// Rotate applies the rotation q to a Vec3.
+func (q *Quat) Rotate(v *Vector) *Vector {
+	p := &Quat{X: v.Values[0], Y: v.Values[1], Z: v.Values[2]}
+	r := q.Mul(p).Mul(&Quat{X: -q.X, Y: -q.Y, Z: -q.Z, W: q.W})
+	return NewVector(r.X, r.Y, r.Z)
+}
+
+// Slerp interpolates along the shortest arc from q, at t = 0, to r, at
+// t = 1.
+func (q *Quat) Slerp(r *Quat, t float64) *Quat {
+	cos := q.Dot(r)
+	if cos < 0 {
+		r, cos = r.Scale(-1), -cos
+	}
+	if cos > 0.9995 {
+		return q.Add(r.Sub(q).Scale(t)).Normalize()
+	}
+	theta := math.Acos(cos)
+	sin := math.Sin(theta)
+	return q.Scale(math.Sin((1-t)*theta) / sin).Add(r.Scale(math.Sin(t*theta) / sin))
+}
+
+// Matrix returns the Mat4 that rotates like q.
+func (q *Quat) Matrix() *Matrix {
+	x, y, z, w := q.X, q.Y, q.Z, q.W
+	return &Matrix{Size: 4, Values: []float64{
+		1 - 2*(y*y+z*z), 2 * (x*y + z*w), 2 * (x*z - y*w), 0,
+		2 * (x*y - z*w), 1 - 2*(x*x+z*z), 2 * (y*z + x*w), 0,
+		2 * (x*z + y*w), 2 * (y*z - x*w), 1 - 2*(x*x+y*y), 0,
+		0, 0, 0, 1,
+	}}
+}
+
+func (q *Quat) Equal(r *Quat) bool {
+	return *q == *r
+}
+
+func formatFloats(values []float64) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	return strings.Join(parts, ", ")
+}
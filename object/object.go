@@ -15,6 +15,8 @@ type Object interface {
 	Inspect() string
 }
 
+// The type names appear in error messages such as "unknown operator:
+// STRING - STRING", so each is the plain name of the type.
 const (
 	INTEGER_OBJ      = "INTEGER"
 	FLOAT_OBJ        = "FLOAT"
@@ -23,15 +25,29 @@ const (
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
-	STRING_OBJ       = "STRING_OBJ"
+	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
 )
 
-type BuiltinFunction func(context interface{}, scope interface{}, args ...Object) Object
+type BuiltinFunction func(ctx *CallContext, args ...Object) Object
+
+// Method is a builtin that operates on the value it was accessed from, e.g.
+// the array in arr.push(x).
+type Method func(ctx *CallContext, receiver Object, args ...Object) Object
 type Null struct{}
 
+// NULL, TRUE and FALSE are the null and boolean values shared by the
+// evaluator, the builtins and conversions from Go, so that conditions and
+// == can compare them by identity. Go code should return these rather than
+// new values.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 func (n *Null) Inspect() string  { return "null" }
 
@@ -68,6 +84,7 @@ type Function struct {
 	Body          *ast.BlockStatement
 	Env           *Environment
 	ObjectContext Hash
+	Generator     bool
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -78,6 +95,9 @@ func (f *Function) Inspect() string {
 		params = append(params, p.String())
 	}
 	out.WriteString("fn")
+	if f.Generator {
+		out.WriteString("*")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -110,8 +130,22 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+// ErrorKind distinguishes errors the host may want to handle differently
+// from ordinary script errors.
+type ErrorKind string
+
+const (
+	// LIMIT_ERROR is raised when a run exceeds one of its Limits or its
+	// context is cancelled.
+	LIMIT_ERROR ErrorKind = "LIMIT"
+	// PERMISSION_ERROR is raised when a program uses a capability it was
+	// not granted.
+	PERMISSION_ERROR ErrorKind = "PERMISSION"
+)
+
 type Error struct {
 	Message string
+	Kind    ErrorKind
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
@@ -119,11 +153,21 @@ func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Builtin struct {
 	Fn BuiltinFunction
+	// Capability, when set, must be granted before the builtin can be called.
+	Capability Capability
+	// Fields are read as builtin.name, so a builtin can also serve as a
+	// module, as input does.
+	Fields map[string]Object
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
+func (b *Builtin) Field(name string) (Object, bool) {
+	field, ok := b.Fields[name]
+	return field, ok
+}
+
 type Hashable interface {
 	HashKey() HashKey
 }
@@ -1,6 +1,13 @@
 package object
 
-import "testing"
+import (
+	"bufio"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,3 +24,105 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestTypeNames(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected ObjectType
+	}{
+		{&Integer{Value: 1}, "INTEGER"},
+		{&Float{Value: 1.5}, "FLOAT"},
+		{&Boolean{Value: true}, "BOOLEAN"},
+		{&Null{}, "NULL"},
+		{&String{Value: "a"}, "STRING"},
+		{&Array{}, "ARRAY"},
+		{&Hash{}, "HASH"},
+	}
+	for _, tt := range tests {
+		if tt.obj.Type() != tt.expected {
+			t.Errorf("%s has type %s, want %s", tt.obj.Inspect(), tt.obj.Type(), tt.expected)
+		}
+	}
+}
+
+func TestCoroutineCancel(t *testing.T) {
+	co := NewCoroutine(func(co *Coroutine) Object {
+		for {
+			if _, ok := co.Yield(&Integer{Value: 1}); !ok {
+				return &Error{Message: "coroutine cancelled"}
+			}
+		}
+	})
+	if result := co.Resume(nil, nil); result.Inspect() != "1" {
+		t.Fatalf("wrong yielded value. got=%s", result.Inspect())
+	}
+	co.Cancel()
+	select {
+	case <-co.exited:
+	case <-time.After(time.Second):
+		t.Fatalf("cancelled coroutine did not release its goroutine")
+	}
+	if !co.Done() {
+		t.Errorf("cancelled coroutine is not done")
+	}
+	if result := co.Resume(nil, nil); result.Inspect() != "ERROR: cannot resume finished coroutine" {
+		t.Errorf("wrong result resuming a cancelled coroutine. got=%s", result.Inspect())
+	}
+
+	unstarted := NewCoroutine(func(co *Coroutine) Object { return &Null{} })
+	unstarted.Cancel()
+	if !unstarted.Done() {
+		t.Errorf("cancelled coroutine is not done")
+	}
+}
+
+func TestCoroutineResume(t *testing.T) {
+	var self *Coroutine
+	self = NewCoroutine(func(co *Coroutine) Object { return self.Resume(nil, nil) })
+	if result := self.Resume(nil, nil); result.Inspect() != "ERROR: coroutine is running" {
+		t.Errorf("wrong result resuming a running coroutine. got=%s", result.Inspect())
+	}
+
+	panicking := NewCoroutine(func(co *Coroutine) Object { panic("boom") })
+	if result := panicking.Resume(nil, nil); result.Inspect() != "ERROR: coroutine failed: boom" {
+		t.Errorf("wrong result for a panicking coroutine. got=%s", result.Inspect())
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	stuck := NewCoroutine(func(co *Coroutine) Object {
+		<-block
+		return &Null{}
+	})
+	timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ctx := &CallContext{Env: NewEnvironment(), Context: timeout}
+	if result := stuck.Resume(ctx, nil); result.Inspect() != "ERROR: execution cancelled: context deadline exceeded" {
+		t.Errorf("wrong result when the run is cancelled. got=%s", result.Inspect())
+	}
+	if !stuck.Done() {
+		t.Errorf("coroutine interrupted by its run is not done")
+	}
+}
+
+func TestInputFromTasks(t *testing.T) {
+	runtime := NewEnvironment().Runtime()
+	runtime.In = strings.NewReader("line\n")
+	readers := make(chan *bufio.Reader, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readers <- runtime.Input()
+		}()
+	}
+	wg.Wait()
+	close(readers)
+	first := runtime.Input()
+	for reader := range readers {
+		if reader != first {
+			t.Fatalf("tasks got different readers for the same input")
+		}
+	}
+}
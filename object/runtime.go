@@ -0,0 +1,111 @@
+package object
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Runtime holds the settings shared by an environment and every environment
+// enclosed by it, so functions and closures see the settings of the program
+// that created them.
+type Runtime struct {
+	// Strict makes out-of-range array, string and vector accesses raise
+	// errors instead of reading null or silently dropping writes.
+	Strict bool
+
+	// Builtins are the global builtins visible to the program. When nil the
+	// evaluator falls back to the default builtin set.
+	Builtins map[string]Object
+
+	// Methods are the method tables of the built-in types, by type and
+	// method name. When nil the evaluator falls back to the default tables.
+	Methods map[ObjectType]map[string]Method
+
+	// Host is the value embedding the interpreter, usually an
+	// *ecs.Interpreter. It is handed to builtins as CallContext.Interpreter.
+	Host interface{}
+
+	// Out receives everything the program prints, Err receives eprint
+	// output and In is read by readLine and input.
+	Out io.Writer
+	Err io.Writer
+	In  io.Reader
+
+	// Context is checked by builtins that block or run for a long time, and
+	// periodically by the evaluator.
+	Context context.Context
+
+	// FSRoots are the directories the fs capability may access. Relative
+	// paths are resolved against the first root.
+	FSRoots []string
+
+	// Limits bound each run. They are enforced between BeginRun calls.
+	Limits Limits
+
+	// Journal, when set, records or replays the nondeterministic values
+	// builtins observe.
+	Journal Journal
+
+	// Debugger, when set, is told about every statement and function call
+	// the evaluator runs.
+	Debugger Debugger
+
+	contextMu   sync.RWMutex
+	runs        int32
+	steps       int64
+	allocations int64
+	tasks       sync.WaitGroup
+
+	// concurrent is set once tasks may run, after which environments are
+	// accessed under envMu.
+	concurrent atomic.Bool
+	envMu      sync.RWMutex
+
+	granted map[Capability]bool
+
+	inputMu     sync.Mutex
+	input       *bufio.Reader
+	inputSource io.Reader
+}
+
+func newRuntime() *Runtime {
+	return &Runtime{
+		Out:     os.Stdout,
+		Err:     os.Stderr,
+		In:      os.Stdin,
+		Context: context.Background(),
+	}
+}
+
+// Input returns a buffered reader for In. The reader is kept between calls
+// so that lines buffered by one read are not lost to the next. It returns nil
+// when In is nil. It is safe to call from any goroutine.
+func (r *Runtime) Input() *bufio.Reader {
+	r.inputMu.Lock()
+	defer r.inputMu.Unlock()
+	if r.In == nil {
+		return nil
+	}
+	if r.input == nil || r.inputSource != r.In {
+		r.input = bufio.NewReader(r.In)
+		r.inputSource = r.In
+	}
+	return r.input
+}
+
+// EnableConcurrency makes every environment sharing this runtime safe to use
+// from several goroutines. It is called before the first task is spawned
+// and cannot be undone. Hashes and arrays are not locked; tasks should share
+// them through channels.
+func (r *Runtime) EnableConcurrency() {
+	r.concurrent.Store(true)
+}
+
+// Concurrent reports whether EnableConcurrency has been called.
+func (r *Runtime) Concurrent() bool {
+	return r.concurrent.Load()
+}
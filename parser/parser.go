@@ -100,6 +100,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FOR, p.parseForExpression)
 	p.registerPrefix(token.WHILE, p.parseWhileExpression)
@@ -110,6 +111,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.NEW, p.parseNewExpression)
 	p.registerPrefix(token.EXEC, p.parseExecExpression)
+	p.registerPrefix(token.SPAWN, p.parseSpawnExpression)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
 	// init parser - both curToken and peekToken should be set
 	p.nextToken()
 	p.nextToken()
@@ -141,6 +144,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
 		}
@@ -357,9 +361,19 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-
+	bracket := p.curToken
 	p.nextToken()
-	index := p.parseExpression(LOWEST)
+
+	var index ast.Expression
+	if !p.curTokenIs(token.COLON) {
+		index = p.parseExpression(LOWEST)
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+		}
+	}
+	if p.curTokenIs(token.COLON) {
+		return p.parseSliceExpression(bracket, left, index)
+	}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
@@ -375,6 +389,27 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	}
 }
 
+// parseSliceExpression parses the remainder of left[start:end] once the
+// parser is positioned on the colon. Either bound may be omitted.
+func (p *Parser) parseSliceExpression(bracket token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: bracket, Left: left, Start: start}
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	if p.peekTokenIs(token.ASSIGN) {
+		assignment := &ast.SliceAssignmentExpression{Token: bracket, Left: left, Start: exp.Start, End: exp.End}
+		p.nextToken()
+		p.nextToken()
+		assignment.Assignment = p.parseExpression(LOWEST)
+		return assignment
+	}
+	return exp
+}
+
 type TokenExpressionPair struct {
 	token      token.Token
 	expression ast.Expression
@@ -386,7 +421,7 @@ func (p *Parser) parseDotIndexExpression(left ast.Expression) ast.Expression {
 	var (
 		Index ast.Expression
 	)
-	if p.peekTokenIs(token.IDENT) {
+	if p.peekTokenIs(token.IDENT) || token.IsKeyword(p.peekToken.Literal) {
 		p.nextToken()
 		identValue := p.curToken.Literal
 		Index = &ast.StringLiteral{Token: p.curToken, Value: identValue}
@@ -470,6 +505,41 @@ func (p *Parser) parseNewExpression() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	exp := &ast.SpawnExpression{Token: p.curToken}
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		args := p.parseExpressionList(token.RPAREN)
+		if len(args) == 0 {
+			p.errors = append(p.errors, "spawn requires a function")
+			return nil
+		}
+		exp.Function = args[0]
+		exp.Arguments = args[1:]
+		return exp
+	}
+	p.nextToken()
+	function := p.parseExpression(LOWEST)
+	if call, ok := function.(*ast.CallExpression); ok {
+		exp.Function = call.Function
+		exp.Arguments = call.Arguments
+	} else {
+		exp.Function = function
+		exp.Arguments = []ast.Expression{}
+	}
+	return exp
+}
+
+func (p *Parser) parseYieldExpression() ast.Expression {
+	exp := &ast.YieldExpression{Token: p.curToken}
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		return exp
+	}
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+	return exp
+}
+
 func (p *Parser) parseExecExpression() ast.Expression {
 	exp := &ast.ExecExpression{Token: p.curToken}
 	if !p.expectPeek(token.STRING) {
@@ -577,8 +647,16 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
+	if p.peekTokenIs(token.ASTERISK) {
+		p.nextToken()
+		lit.Generator = true
+	}
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
@@ -696,3 +696,97 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"s[1:3]", "(s[1:3])"},
+		{"s[:3]", "(s[:3])"},
+		{"s[1:]", "(s[1:])"},
+		{"s[:]", "(s[:])"},
+		{"s[a + 1:len(s)]", "(s[(a + 1):len(s)])"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestParsingSliceAssignment(t *testing.T) {
+	l := lexer.New("a[1:2] = [3]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.SliceAssignmentExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceAssignmentExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, exp.Left, "a")
+	testIntegerLiteral(t, exp.Start, 1)
+	testIntegerLiteral(t, exp.End, 2)
+	if exp.Assignment.String() != "[3]" {
+		t.Errorf("assignment wrong. got=%q", exp.Assignment.String())
+	}
+}
+
+func TestParsingSpawnExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		function string
+		args     int
+	}{
+		{"spawn work(1, 2)", "work", 2},
+		{"spawn(work, 1, 2)", "work", 2},
+		{"spawn fn() { 1 }", "fn() 1", 0},
+		{"spawn obj.run()", "(obj[run])", 0},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.SpawnExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SpawnExpression. got=%T", stmt.Expression)
+		}
+		if exp.Function.String() != tt.function {
+			t.Errorf("function wrong for %q. got=%q", tt.input, exp.Function.String())
+		}
+		if len(exp.Arguments) != tt.args {
+			t.Errorf("wrong number of arguments for %q. got=%d", tt.input, len(exp.Arguments))
+		}
+	}
+}
+
+func TestParsingGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn*() { yield 1; yield; 2 }", "fn*() yield 1yield2"},
+		{"fn*(x) { let y = yield x; y }", "fn*(x) let y = yield x;y"},
+		{"fn() { yield }", "fn() yield"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
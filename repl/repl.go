@@ -1,15 +1,9 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
 
-	"github.com/SpaceHexagon/ecs/object"
-
-	"github.com/SpaceHexagon/ecs/evaluator"
-	"github.com/SpaceHexagon/ecs/lexer"
-	"github.com/SpaceHexagon/ecs/parser"
+	"github.com/SpaceHexagon/ecs"
 )
 
 const MONKEY_FACE = `
@@ -37,24 +31,24 @@ func printParserErrors(out io.Writer, errors []string) {
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+// Start reads lines from in and evaluates them with interp until in is
+// exhausted. The interpreter's input and output are redirected to in and out
+// so scripts that print or read input share the session's streams.
+func Start(in io.Reader, out io.Writer, interp *ecs.Interpreter) {
+	interp.SetInput(in)
+	interp.SetOutput(out)
+	reader := interp.Runtime().Input()
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		io.WriteString(out, PROMPT)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		evaluated, err := interp.RunString(line)
+		if parseErr, ok := err.(*ecs.ParseError); ok {
+			printParserErrors(out, parseErr.Errors)
 			continue
 		}
-		evaluated := evaluator.Eval(program, env, nil)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -5,6 +5,11 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+
+	// Line and Column locate the first character of the token in the
+	// source, counting from 1. Column counts bytes.
+	Line   int
+	Column int
 }
 
 const (
@@ -51,10 +56,13 @@ const (
 	RETURN   = "RETURN"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	TYPEOF   = "TYPEOF"
 	EXEC     = "EXEC"
 	NEW      = "NEW"
 	CLASS    = "CLASS"
+	SPAWN    = "SPAWN"
+	YIELD    = "YIELD"
 )
 
 var keywords = map[string]TokenType{
@@ -65,6 +73,7 @@ var keywords = map[string]TokenType{
 	"return": RETURN,
 	"true":   TRUE,
 	"false":  FALSE,
+	"null":   NULL,
 	"for":    FOR,
 	"sleep":  SLEEP,
 	"exec":   EXEC,
@@ -72,6 +81,15 @@ var keywords = map[string]TokenType{
 	"typeof": TYPEOF,
 	"new":    NEW,
 	"class":  CLASS,
+	"spawn":  SPAWN,
+	"yield":  YIELD,
+}
+
+// IsKeyword reports whether ident is reserved. Keywords can still be used as
+// property names after a dot.
+func IsKeyword(ident string) bool {
+	_, ok := keywords[ident]
+	return ok
 }
 
 func LookupIdent(ident string) TokenType {
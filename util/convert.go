@@ -0,0 +1,516 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+// The struct tag used to rename or skip fields, e.g. `ecs:"hp"` or `ecs:"-"`.
+const structTag = "ecs"
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// ToObject converts a Go value into an object. Structs become hashes keyed
+// by field name (or their `ecs` tag), slices and arrays become arrays, maps
+// become hashes, pointers are followed, time.Time becomes an RFC 3339 string
+// and funcs are wrapped as builtins. Values that are already objects are
+// returned unchanged. Values that cannot be represented yield an error
+// object.
+func ToObject(value interface{}) object.Object {
+	return toObject(reflect.ValueOf(value), map[visit]bool{})
+}
+
+// visit identifies a pointer, map or slice being converted, so that values
+// which contain themselves are reported instead of followed forever.
+type visit struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+func toObject(v reflect.Value, visiting map[visit]bool) object.Object {
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return object.NULL
+	}
+	if v.Kind() != reflect.Interface && v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case object.Object:
+			return value
+		case json.Number:
+			if i, err := value.Int64(); err == nil {
+				return &object.Integer{Value: i}
+			}
+			f, _ := value.Float64()
+			return &object.Float{Value: f}
+		case time.Time:
+			return &object.String{Value: value.Format(time.RFC3339Nano)}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return object.TRUE
+		}
+		return object.FALSE
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return &object.Error{Message: fmt.Sprintf("%d overflows INTEGER", v.Uint())}
+		}
+		return &object.Integer{Value: int64(v.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}
+	case reflect.String:
+		return &object.String{Value: v.String()}
+	case reflect.Interface:
+		if v.IsNil() {
+			return object.NULL
+		}
+		return toObject(v.Elem(), visiting)
+	case reflect.Ptr:
+		key := visit{v.Type(), v.Pointer(), 0}
+		if visiting[key] {
+			return cyclicError(v)
+		}
+		visiting[key] = true
+		defer delete(visiting, key)
+		return toObject(v.Elem(), visiting)
+	case reflect.Slice:
+		if v.IsNil() {
+			return object.NULL
+		}
+		key := visit{v.Type(), v.Pointer(), v.Len()}
+		if visiting[key] {
+			return cyclicError(v)
+		}
+		visiting[key] = true
+		defer delete(visiting, key)
+		fallthrough
+	case reflect.Array:
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			elements[i] = toObject(v.Index(i), visiting)
+			if isConversionError(elements[i]) {
+				return elements[i]
+			}
+		}
+		return &object.Array{Elements: elements}
+	case reflect.Map:
+		if v.IsNil() {
+			return object.NULL
+		}
+		mapKey := visit{v.Type(), v.Pointer(), 0}
+		if visiting[mapKey] {
+			return cyclicError(v)
+		}
+		visiting[mapKey] = true
+		defer delete(visiting, mapKey)
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, v.Len())}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := toObject(iter.Key(), visiting)
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return &object.Error{Message: fmt.Sprintf("unusable as hash key: %s", key.Type())}
+			}
+			value := toObject(iter.Value(), visiting)
+			if isConversionError(value) {
+				return value
+			}
+			hash.Pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return hash
+	case reflect.Struct:
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+		for _, field := range structFields(v.Type()) {
+			value := toObject(v.FieldByIndex(field.index), visiting)
+			if isConversionError(value) {
+				return value
+			}
+			key := &object.String{Value: field.name}
+			hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return hash
+	case reflect.Func:
+		if v.IsNil() {
+			return object.NULL
+		}
+		return wrapFunc(v)
+	default:
+		return &object.Error{Message: fmt.Sprintf("cannot convert %s to an object", v.Type())}
+	}
+}
+
+func cyclicError(v reflect.Value) *object.Error {
+	return &object.Error{Message: fmt.Sprintf("cannot convert cyclic %s to an object", v.Type())}
+}
+
+func isConversionError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+// wrapFunc exposes a Go func as a builtin. Arguments are converted with
+// FromObject. A trailing error result is returned as an error object, a
+// single remaining result is converted with ToObject and several results
+// are returned as an array. A panic in fn is returned as an error object
+// too, so a faulty host function cannot bring down the interpreter.
+func wrapFunc(fn reflect.Value) *object.Builtin {
+	fnType := fn.Type()
+	return &object.Builtin{
+		Fn: func(ctx *object.CallContext, args ...object.Object) (result object.Object) {
+			defer func() {
+				if r := recover(); r != nil {
+					result = &object.Error{Message: fmt.Sprintf("%s panicked: %v", fnType, r)}
+				}
+			}()
+			numIn := fnType.NumIn()
+			if fnType.IsVariadic() {
+				if len(args) < numIn-1 {
+					return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want at least %d", len(args), numIn-1)}
+				}
+			} else if len(args) != numIn {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), numIn)}
+			}
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				var argType reflect.Type
+				if fnType.IsVariadic() && i >= numIn-1 {
+					argType = fnType.In(numIn - 1).Elem()
+				} else {
+					argType = fnType.In(i)
+				}
+				in[i] = reflect.New(argType).Elem()
+				if err := fromObject(arg, in[i], map[object.Object]bool{}); err != nil {
+					return &object.Error{Message: fmt.Sprintf("argument %d: %s", i+1, err)}
+				}
+			}
+			out := fn.Call(in)
+			if n := len(out); n > 0 && fnType.Out(n-1) == errorType {
+				if err, _ := out[n-1].Interface().(error); err != nil {
+					return &object.Error{Message: err.Error()}
+				}
+				out = out[:n-1]
+			}
+			switch len(out) {
+			case 0:
+				return object.NULL
+			case 1:
+				return toObject(out[0], map[visit]bool{})
+			default:
+				elements := make([]object.Object, len(out))
+				for i, result := range out {
+					elements[i] = toObject(result, map[visit]bool{})
+				}
+				return &object.Array{Elements: elements}
+			}
+		},
+	}
+}
+
+type fieldInfo struct {
+	name  string
+	index []int
+}
+
+// structFields lists the exported fields of a struct type under their
+// object names. Fields of embedded structs without a tag are promoted.
+func structFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get(structTag)
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && field.Type.Kind() != reflect.Ptr {
+				for _, inner := range structFields(embedded) {
+					inner.index = append([]int{i}, inner.index...)
+					fields = append(fields, inner)
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, fieldInfo{name: name, index: []int{i}})
+	}
+	return fields
+}
+
+// FromObject stores obj in the Go value target points to, converting it the
+// same way ToObject does in reverse. Hash keys are matched to struct fields
+// by `ecs` tag or field name, ignoring case. An interface{} target receives
+// plain Go values: string, int64, float64, bool, nil, []interface{} and
+// map[string]interface{}.
+func FromObject(obj object.Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	return fromObject(obj, v.Elem(), map[object.Object]bool{})
+}
+
+// fromObject converts obj into v. visiting holds the arrays and hashes
+// being converted, to report cycles instead of recursing forever.
+func fromObject(obj object.Object, v reflect.Value, visiting map[object.Object]bool) error {
+	if obj == nil {
+		obj = object.NULL
+	}
+	t := v.Type()
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		native, err := toNative(obj, visiting)
+		if err != nil {
+			return err
+		}
+		if native == nil {
+			v.Set(reflect.Zero(t))
+		} else {
+			v.Set(reflect.ValueOf(native))
+		}
+		return nil
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if obj.Type() == object.NULL_OBJ {
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+	if t == timeType {
+		return timeFromObject(obj, v)
+	}
+	switch obj.(type) {
+	case *object.Array, *object.Hash:
+		// A pointer target converts obj itself again.
+		if t.Kind() == reflect.Ptr {
+			break
+		}
+		if visiting[obj] {
+			return fmt.Errorf("cannot convert cyclic %s to %s", obj.Type(), t)
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if err := fromObject(obj, elem.Elem(), visiting); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			v.SetBool(b.Value)
+			return nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			v.SetString(s.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := integerValue(obj)
+		if !ok {
+			break
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %s", i, t)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := integerValue(obj)
+		if !ok {
+			break
+		}
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return fmt.Errorf("%d overflows %s", i, t)
+		}
+		v.SetUint(uint64(i))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			v.SetFloat(n.Value)
+			return nil
+		case *object.Integer:
+			v.SetFloat(float64(n.Value))
+			return nil
+		}
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			break
+		}
+		slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, element := range arr.Elements {
+			if err := fromObject(element, slice.Index(i), visiting); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Array:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			break
+		}
+		if len(arr.Elements) != t.Len() {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(arr.Elements), t)
+		}
+		for i, element := range arr.Elements {
+			if err := fromObject(element, v.Index(i), visiting); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			break
+		}
+		m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key := reflect.New(t.Key()).Elem()
+			if err := fromObject(pair.Key, key, visiting); err != nil {
+				return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := fromObject(pair.Value, value, visiting); err != nil {
+				return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			break
+		}
+		fields := structFields(t)
+		for _, pair := range hash.Pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				continue
+			}
+			for _, field := range fields {
+				if strings.EqualFold(field.name, key.Value) {
+					if err := fromObject(pair.Value, v.FieldByIndex(field.index), visiting); err != nil {
+						return fmt.Errorf("field %s: %w", field.name, err)
+					}
+					break
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+}
+
+// integerValue accepts integers and floats without a fractional part.
+func integerValue(obj object.Object) (int64, bool) {
+	switch n := obj.(type) {
+	case *object.Integer:
+		return n.Value, true
+	case *object.Float:
+		if n.Value == math.Trunc(n.Value) && n.Value >= math.MinInt64 && n.Value <= math.MaxInt64 {
+			return int64(n.Value), true
+		}
+	}
+	return 0, false
+}
+
+// timeFromObject accepts RFC 3339 strings and Unix timestamps in seconds.
+func timeFromObject(obj object.Object, v reflect.Value) error {
+	switch value := obj.(type) {
+	case *object.String:
+		parsed, err := time.Parse(time.RFC3339Nano, value.Value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(parsed))
+		return nil
+	case *object.Integer:
+		v.Set(reflect.ValueOf(time.Unix(value.Value, 0)))
+		return nil
+	case *object.Float:
+		sec, frac := math.Modf(value.Value)
+		v.Set(reflect.ValueOf(time.Unix(int64(sec), int64(frac*1e9))))
+		return nil
+	}
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), timeType)
+}
+
+// ToNative converts an object into plain Go values: string, int64, float64,
+// bool, nil, []interface{} and map[string]interface{}.
+func ToNative(obj object.Object) (interface{}, error) {
+	return toNative(obj, map[object.Object]bool{})
+}
+
+func toNative(obj object.Object, visiting map[object.Object]bool) (interface{}, error) {
+	switch obj.(type) {
+	case *object.Array, *object.Hash:
+		if visiting[obj] {
+			return nil, fmt.Errorf("cannot convert cyclic %s to a Go value", obj.Type())
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+	}
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := toNative(element, visiting)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case *object.Hash:
+		values := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			value, err := toNative(pair.Value, visiting)
+			if err != nil {
+				return nil, err
+			}
+			values[pair.Key.Inspect()] = value
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Go value", obj.Type())
+	}
+}
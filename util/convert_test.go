@@ -0,0 +1,225 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SpaceHexagon/ecs/object"
+)
+
+type Stats struct {
+	HP    int     `ecs:"hp"`
+	Speed float64 `ecs:"speed"`
+}
+
+type Player struct {
+	Stats
+	Name      string
+	Tags      []string          `ecs:"tags"`
+	Inventory map[string]int    `ecs:"inventory"`
+	Target    *Player           `ecs:"target"`
+	Joined    time.Time         `ecs:"joined"`
+	Extra     map[string]string `ecs:"-"`
+	secret    string
+}
+
+func TestToObjectStruct(t *testing.T) {
+	joined := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	player := &Player{
+		Stats:     Stats{HP: 50, Speed: 1.5},
+		Name:      "Goblin",
+		Tags:      []string{"enemy"},
+		Inventory: map[string]int{"gold": 3},
+		Joined:    joined,
+		secret:    "hidden",
+	}
+	obj := ToObject(player)
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%+v)", obj, obj)
+	}
+	expected := map[string]string{
+		"hp":        "50",
+		"speed":     "1.500000",
+		"Name":      "Goblin",
+		"tags":      "[enemy]",
+		"inventory": "{gold: 3}",
+		"target":    "null",
+		"joined":    "2024-05-01T12:00:00Z",
+	}
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash has wrong number of pairs. got=%d (%s)", len(hash.Pairs), hash.Inspect())
+	}
+	for key, value := range expected {
+		pair, ok := hash.Pairs[(&object.String{Value: key}).HashKey()]
+		if !ok {
+			t.Errorf("no pair for key %q", key)
+			continue
+		}
+		if pair.Value.Inspect() != value {
+			t.Errorf("wrong value for %q. got=%q, want=%q", key, pair.Value.Inspect(), value)
+		}
+	}
+}
+
+func TestToObjectErrors(t *testing.T) {
+	player := &Player{Name: "Loop"}
+	player.Target = player
+	loop := []interface{}{1, nil}
+	loop[1] = loop
+	nested := map[string]interface{}{}
+	nested["self"] = nested
+	shared := new(int)
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{player, "ERROR: cannot convert cyclic *util.Player to an object"},
+		{loop, "ERROR: cannot convert cyclic []interface {} to an object"},
+		{nested, "ERROR: cannot convert cyclic map[string]interface {} to an object"},
+		{uint64(1) << 63, "ERROR: 9223372036854775808 overflows INTEGER"},
+		{[]*int{shared, shared}, "[0, 0]"},
+	}
+	for _, tt := range tests {
+		if got := ToObject(tt.value).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %T. got=%q, want=%q", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestFromObjectRoundTrip(t *testing.T) {
+	original := Player{
+		Stats:     Stats{HP: 7, Speed: 2},
+		Name:      "Orc",
+		Tags:      []string{"a", "b"},
+		Inventory: map[string]int{"gold": 10},
+		Target:    &Player{Name: "Hero"},
+		Joined:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+	var decoded Player
+	if err := FromObject(ToObject(original), &decoded); err != nil {
+		t.Fatalf("FromObject returned error: %s", err)
+	}
+	if !reflect.DeepEqual(original, decoded) {
+		t.Errorf("round trip mismatch.\ngot=%+v\nwant=%+v", decoded, original)
+	}
+}
+
+func TestFromObjectConversions(t *testing.T) {
+	var i8 int8
+	if err := FromObject(&object.Integer{Value: 300}, &i8); err == nil {
+		t.Errorf("expected overflow error for int8")
+	}
+	var u uint
+	if err := FromObject(&object.Integer{Value: -1}, &u); err == nil {
+		t.Errorf("expected error for negative uint")
+	}
+	var n int
+	if err := FromObject(&object.Float{Value: 3}, &n); err != nil || n != 3 {
+		t.Errorf("integral float not converted. got=%d err=%v", n, err)
+	}
+	var s string
+	if err := FromObject(&object.Integer{Value: 1}, &s); err == nil {
+		t.Errorf("expected type error converting INTEGER to string")
+	}
+	var native interface{}
+	arr := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.String{Value: "x"}, &object.Null{}}}
+	if err := FromObject(arr, &native); err != nil {
+		t.Fatalf("FromObject returned error: %s", err)
+	}
+	if !reflect.DeepEqual(native, []interface{}{int64(1), "x", nil}) {
+		t.Errorf("wrong native value. got=%#v", native)
+	}
+	var obj object.Object
+	if err := FromObject(arr, &obj); err != nil || obj != arr {
+		t.Errorf("object target should receive the object itself. got=%v err=%v", obj, err)
+	}
+	if err := FromObject(arr, native); err == nil {
+		t.Errorf("expected error for non-pointer target")
+	}
+}
+
+func TestFromObjectCycles(t *testing.T) {
+	cyclic := &object.Array{}
+	cyclic.Elements = []object.Object{cyclic}
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	key := &object.String{Value: "self"}
+	hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: hash}
+
+	var native interface{}
+	if err := FromObject(cyclic, &native); err == nil || err.Error() != "cannot convert cyclic ARRAY to a Go value" {
+		t.Errorf("wrong error for a cyclic array. got=%v", err)
+	}
+	var nested [][]interface{}
+	if err := FromObject(cyclic, &nested); err == nil || err.Error() != "index 0: cannot convert cyclic ARRAY to []interface {}" {
+		t.Errorf("wrong error for a cyclic array. got=%v", err)
+	}
+	type node struct {
+		Self *node `ecs:"self"`
+	}
+	var n node
+	if err := FromObject(hash, &n); err == nil || err.Error() != "field self: cannot convert cyclic HASH to util.node" {
+		t.Errorf("wrong error for a cyclic hash. got=%v", err)
+	}
+	if _, err := ToNative(hash); err == nil || err.Error() != "cannot convert cyclic HASH to a Go value" {
+		t.Errorf("wrong error for a cyclic hash. got=%v", err)
+	}
+
+	inspect := ToObject(func(x interface{}) bool { return x != nil }).(*object.Builtin)
+	if result := inspect.Fn(nil, cyclic); result.Inspect() != "ERROR: argument 1: cannot convert cyclic ARRAY to a Go value" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	shared := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}
+	if err := FromObject(&object.Array{Elements: []object.Object{shared, shared}}, &native); err != nil {
+		t.Errorf("shared values are not cycles. got=%s", err)
+	}
+}
+
+func TestToObjectFunc(t *testing.T) {
+	add := ToObject(func(a, b int) int { return a + b })
+	builtin, ok := add.(*object.Builtin)
+	if !ok {
+		t.Fatalf("object is not Builtin. got=%T", add)
+	}
+	result := builtin.Fn(nil, &object.Integer{Value: 2}, &object.Integer{Value: 3})
+	if result.Inspect() != "5" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+	result = builtin.Fn(nil, &object.Integer{Value: 2})
+	if result.Inspect() != "ERROR: wrong number of arguments. got=1, want=2" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	fail := ToObject(func(name string) (string, error) {
+		if name == "" {
+			return "", errors.New("name required")
+		}
+		return "hi " + name, nil
+	}).(*object.Builtin)
+	if result := fail.Fn(nil, &object.String{Value: ""}); result.Inspect() != "ERROR: name required" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+	if result := fail.Fn(nil, &object.String{Value: "bob"}); result.Inspect() != "hi bob" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	sum := ToObject(func(values ...float64) float64 {
+		total := 0.0
+		for _, v := range values {
+			total += v
+		}
+		return total
+	}).(*object.Builtin)
+	if result := sum.Fn(nil, &object.Integer{Value: 1}, &object.Float{Value: 0.5}); result.Inspect() != "1.500000" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	index := ToObject(func(values []int, i int) int { return values[i] }).(*object.Builtin)
+	result = index.Fn(nil, &object.Array{}, &object.Integer{Value: 1})
+	if result.Inspect() != "ERROR: func([]int, int) int panicked: runtime error: index out of range [1] with length 0" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
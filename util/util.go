@@ -4,11 +4,12 @@ import (
 	"github.com/SpaceHexagon/ecs/object"
 )
 
-// CopyObject returns a copy of any primitive object
+// CopyObject returns a copy of any primitive object. Booleans are shared
+// values and are returned as they are.
 func CopyObject(valueNode object.Object) object.Object {
 	switch valueNode.Type() {
 	case "BOOLEAN":
-		return &object.Boolean{Value: valueNode.(*object.Boolean).Value}
+		return valueNode
 	case "INTEGER":
 		return &object.Integer{Value: valueNode.(*object.Integer).Value}
 	case "FLOAT":
@@ -22,9 +23,9 @@ func CopyObject(valueNode object.Object) object.Object {
 	case object.FUNCTION_OBJ:
 	case object.BUILTIN_OBJ:
 	default:
-		return &object.Null{}
+		return object.NULL
 	}
-	return &object.Null{}
+	return object.NULL
 }
 
 // CopyArray returns a deep copy of an existing object.Array
@@ -111,68 +112,27 @@ func MakeBuiltinInterface(methods []StringObjectPair) *object.Hash {
 // 	}});
 // }
 
-func NativeListToArray(items []interface{}) object.Array {
-	var (
-		elements []object.Object
-	)
+// NativeListToArray converts a list of Go values into an array. See
+// ToObject for how the elements are converted.
+func NativeListToArray(items []interface{}) *object.Array {
+	elements := make([]object.Object, 0, len(items))
 	for _, element := range items {
-		switch element.(type) {
-		case string:
-			elements = append(elements, &object.String{Value: element.(string)})
-		case int64:
-			elements = append(elements, &object.Integer{Value: element.(int64)})
-		case float64:
-			elements = append(elements, &object.Float{Value: element.(float64)})
-		case bool:
-			elements = append(elements, &object.Boolean{Value: element.(bool)})
-		// case []interface{}:
-		// 	elements = append(elements, (nativeListToArray(element))
-		// case interface{}:
-		// 	elements = append(elements, nativeObjToMap(element.(map[string]interface{})).(interface{}(object.Object).(type)))
-		default:
-			elements = append(elements, &object.Null{})
-		}
+		elements = append(elements, ToObject(element))
 	}
 
-	return object.Array{Elements: elements} //obj
+	return &object.Array{Elements: elements}
 }
 
-// func nativeObjToMap (obj: {[key: string]: any} = {}): object.Hash => {
-func NativeObjToMap(obj map[string]interface{}) object.Hash {
-	newMap := object.Hash{Pairs: nil}
+// NativeObjToMap converts a map of Go values into a hash. See ToObject for
+// how the values are converted.
+func NativeObjToMap(obj map[string]interface{}) *object.Hash {
+	newMap := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, len(obj))}
 
 	for objectKey, data := range obj {
-		var (
-			value object.Object
-		)
-
-		switch data.(type) {
-		case string:
-			value = &object.String{Value: data.(string)}
-			break
-		case int64:
-			value = &object.Integer{Value: data.(int64)}
-			break
-		case float64:
-			value = &object.Float{Value: data.(float64)}
-		case bool:
-			value = &object.Boolean{Value: data.(bool)}
-			break
-		// case interface{}:
-		// 	value = nativeObjToMap(data)
-		// 	break
-		// case :
-		// 	console.log("native function", data)
-		// 	// need to figure this out
-		// 	// new object.Builtin(builtinFn, contextName)
-		// 	break
-		default:
-
-		}
 		key := &object.String{Value: objectKey}
 		newMap.Pairs[key.HashKey()] = object.HashPair{
 			Key:   key,
-			Value: value,
+			Value: ToObject(data),
 		}
 	}
 